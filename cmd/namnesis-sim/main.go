@@ -0,0 +1,91 @@
+// namnesis-sim runs a fake Stompbox on a TCP port so the gateway and web UI
+// can be developed without the pedal.
+//
+//	go run ./cmd/namnesis-sim -listen 127.0.0.1:5555
+//	STOMPBOX_PORT=5555 go run ./cmd/namnesis-ui-gateway
+package main
+
+import (
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"os/signal"
+	"strings"
+	"syscall"
+
+	"github.com/alscos/Namnesis/internal/stompbox"
+	"github.com/alscos/Namnesis/internal/stompboxtest"
+)
+
+func main() {
+	listen := flag.String("listen", "127.0.0.1:5555", "TCP address to serve the Stompbox protocol on")
+	samples := flag.String("samples", "docs/samples", "directory with dump_config.example.txt and dump_program.example.txt")
+	latency := flag.Duration("latency", 0, "delay added to every response")
+	fail := flag.String("fail", "", `comma-separated VERB=message or "VERB ARG=message" pairs answered with "Error message" (e.g. "SetParam=busy,LoadPreset=nope")`)
+	truncate := flag.Int("truncate-dumps", 0, "cut Dump responses after N bytes and close the connection")
+	disconnect := flag.Int("disconnect-every", 0, "close the connection without answering every Nth command")
+	replay := flag.String("replay", "", "serve a transcript exported from /api/debug/trace/export instead of sample state")
+	flag.Parse()
+
+	var srv *stompboxtest.Server
+	desc := ""
+	if *replay != "" {
+		tr, err := loadTranscript(*replay)
+		if err != nil {
+			log.Fatalf("load transcript: %v", err)
+		}
+		srv = stompboxtest.NewReplayServer(tr)
+		desc = fmt.Sprintf("replaying %d exchanges from %s", len(tr.Entries), *replay)
+	} else {
+		st, err := stompboxtest.LoadStateDir(*samples)
+		if err != nil {
+			log.Fatalf("load samples: %v", err)
+		}
+		srv = stompboxtest.NewServer(st)
+		desc = "presets: " + strings.Join(st.Presets(), " ")
+	}
+
+	srv.SetFaults(stompboxtest.Faults{
+		Latency:         *latency,
+		Errors:          parseFailFlag(*fail),
+		TruncateDumps:   *truncate,
+		DisconnectEvery: *disconnect,
+	})
+	if err := srv.Listen(*listen); err != nil {
+		log.Fatalf("listen: %v", err)
+	}
+
+	log.Printf("namnesis-sim serving %s (%s)", srv.Addr(), desc)
+
+	stop := make(chan os.Signal, 1)
+	signal.Notify(stop, syscall.SIGINT, syscall.SIGTERM)
+	<-stop
+
+	log.Printf("shutdown requested; stopping...")
+	_ = srv.Close()
+}
+
+func loadTranscript(path string) (*stompbox.Transcript, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	return stompbox.ReadTranscript(f)
+}
+
+func parseFailFlag(s string) map[string]string {
+	if strings.TrimSpace(s) == "" {
+		return nil
+	}
+	out := make(map[string]string)
+	for _, kv := range strings.Split(s, ",") {
+		verb, msg, ok := strings.Cut(strings.TrimSpace(kv), "=")
+		if !ok || verb == "" {
+			log.Fatalf("bad -fail entry %q (want VERB=message)", kv)
+		}
+		out[verb] = msg
+	}
+	return out
+}
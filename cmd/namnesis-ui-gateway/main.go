@@ -10,9 +10,13 @@ import (
 	"syscall"
 	"time"
 
+	"github.com/alscos/Namnesis/internal/baseline"
 	"github.com/alscos/Namnesis/internal/config"
+	"github.com/alscos/Namnesis/internal/configcache"
 	"github.com/alscos/Namnesis/internal/httpserver"
 	"github.com/alscos/Namnesis/internal/oled"
+	"github.com/alscos/Namnesis/internal/scheduler"
+	"github.com/alscos/Namnesis/internal/statehub"
 	"github.com/alscos/Namnesis/internal/stompbox"
 )
 
@@ -26,11 +30,39 @@ func main() {
 	sb := stompbox.New(fmt.Sprintf("%s:%d", cfg.StompHost, cfg.StompPort))
 	sb.DialTimeout = cfg.DialTimeout
 	sb.ReadTimeout = cfg.ReadTimeout
+	sb.IdleTimeout = cfg.IdleTimeout
+	sb.BreakerThreshold = cfg.BreakerThreshold
+	sb.BackoffMin = cfg.BackoffMin
+	sb.BackoffMax = cfg.BackoffMax
 	sb.MaxBytes = int(cfg.MaxBytes)
+	sb.MaxSectionBytes = int(cfg.MaxSectionBytes)
+	sb.MaxConfigBytes = int(cfg.MaxConfigBytes)
+	if cfg.TraceSize > 0 {
+		sb.Tracer = stompbox.NewTracer(cfg.TraceSize)
+	}
+
+	// All Stompbox traffic (HTTP + OLED) goes through one scheduler.
+	sched := scheduler.New(sb, cfg.SchedMaxInFlight)
+
+	// Plugin definitions rarely change: serve them from memory.
+	cfgCache := configcache.New(sched.DumpConfigRawCtx)
+	cfgCache.MaxAge = cfg.ConfigRefresh
+
+	// One Dump Program poller shared by HTTP, /api/events and the OLED.
+	programs := statehub.New(sched.DumpProgramCtx)
+	programs.LiveInterval = cfg.StatePollLive
+	programs.IdleInterval = cfg.StatePollIdle
+
+	// Program as loaded, for unsaved-change detection and revert.
+	tracker := baseline.New(programs)
 
 	r, err := httpserver.NewRouter(httpserver.RouterDeps{
-		Config: cfg,
-		SB:     sb,
+		Config:      cfg,
+		SB:          sb,
+		Sched:       sched,
+		ConfigCache: cfgCache,
+		Programs:    programs,
+		Baseline:    tracker,
 	})
 	if err != nil {
 		log.Fatalf("router init: %v", err)
@@ -49,7 +81,30 @@ func main() {
 	// --- OLED bridge (optional) ---
 	// Best: create a udev symlink /dev/ttyNAMNESIS_OLED for stable naming
 	o := oled.NewOLEDSerial("/dev/ttyNAMNESIS_OLED", 115200)
-	go o.Start(ctx, sb.DumpProgram, 400*time.Millisecond)
+	go programs.Run(ctx)
+	go tracker.Run(ctx)
+	oledSnaps, stopOLEDSnaps := programs.Subscribe(false)
+	defer stopOLEDSnaps()
+	go o.Start(ctx, oledSnaps)
+	go cfgCache.Run(ctx, cfg.ConfigRefresh)
+
+	// --- engine up/down: log transitions once instead of every failed poll ---
+	avail, stopAvail := sb.WatchAvailability(8)
+	defer stopAvail()
+	go func() {
+		for a := range avail {
+			if a.Up {
+				log.Printf("stompbox: back online")
+			} else {
+				log.Printf("stompbox: offline (%s); backing off", a.Error)
+			}
+			o.SetEngineUp(a.Up)
+			if a.Up {
+				// Stompbox restarted: plugins or libraries may have changed.
+				cfgCache.Invalidate()
+			}
+		}
+	}()
 
 	// --- graceful shutdown on SIGINT/SIGTERM ---
 	stop := make(chan os.Signal, 1)
@@ -69,6 +124,9 @@ func main() {
 
 		// close serial explicitly (optional; Start() also closes on ctx.Done())
 		o.Close()
+
+		// drop the persistent Stompbox session
+		_ = sb.Close()
 	}()
 
 	log.Printf("namnesis-ui-gateway listening on %s (stompbox %s:%d)\n",
@@ -0,0 +1,199 @@
+// Package baseline remembers the program as it was when the current preset
+// was loaded or saved, so the gateway can tell what has been changed since
+// and put it back.
+//
+// A baseline is captured explicitly after LoadPreset/SavePreset requests and
+// automatically whenever the state hub shows another active preset (a load
+// triggered over MIDI or by another client).
+package baseline
+
+import (
+	"context"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/alscos/Namnesis/internal/statehub"
+	"github.com/alscos/Namnesis/internal/stompbox"
+)
+
+// Reasons a baseline was captured.
+const (
+	ReasonStartup = "startup" // first program seen; may already carry edits
+	ReasonLoad    = "load"    // LoadPreset through the gateway
+	ReasonSave    = "save"    // SavePreset through the gateway
+	ReasonPreset  = "preset"  // active preset changed outside the gateway
+)
+
+// Baseline is the program as stored in its preset. It is never modified
+// after creation.
+type Baseline struct {
+	Preset  string
+	Program *stompbox.Program
+	// Raw is the Dump Program response it was parsed from.
+	Raw        string
+	Revision   uint64 // state hub revision it was taken from
+	CapturedAt time.Time
+	Reason     string
+}
+
+// Tracker holds the baseline of the active preset.
+type Tracker struct {
+	hub *statehub.Hub
+
+	mu      sync.Mutex
+	cur     *Baseline
+	watches []func(*Baseline)
+}
+
+func New(hub *statehub.Hub) *Tracker {
+	return &Tracker{hub: hub}
+}
+
+// Current returns the baseline (nil until the first program is seen).
+func (t *Tracker) Current() *Baseline {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return t.cur
+}
+
+// Capture makes snap the baseline.
+func (t *Tracker) Capture(snap *statehub.Snapshot, reason string) *Baseline {
+	b := &Baseline{
+		Preset:     snap.Program.ActivePreset,
+		Program:    snap.Program,
+		Raw:        snap.Raw,
+		Revision:   snap.Revision,
+		CapturedAt: time.Now(),
+		Reason:     reason,
+	}
+	t.mu.Lock()
+	t.cur = b
+	watches := t.watches
+	t.mu.Unlock()
+	for _, fn := range watches {
+		fn(b)
+	}
+	return b
+}
+
+// OnCapture registers fn to be called with every new baseline, from the
+// goroutine that captured it.
+func (t *Tracker) OnCapture(fn func(*Baseline)) {
+	t.mu.Lock()
+	t.watches = append(t.watches, fn)
+	t.mu.Unlock()
+}
+
+// CaptureNow dumps the program and makes it the baseline. Call it right
+// after a LoadPreset or SavePreset succeeded.
+func (t *Tracker) CaptureNow(ctx context.Context, reason string) (*Baseline, error) {
+	snap, err := t.hub.Refresh(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return t.Capture(snap, reason), nil
+}
+
+// Run watches the state hub until ctx is done and recaptures the baseline
+// whenever the active preset changes.
+func (t *Tracker) Run(ctx context.Context) {
+	snaps, stop := t.hub.Subscribe(false)
+	defer stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case snap := <-snaps:
+			switch cur := t.Current(); {
+			case cur == nil:
+				t.Capture(snap, ReasonStartup)
+			case snap.Revision < cur.Revision:
+				// Older than an explicit capture made meanwhile.
+			case cur.Preset != snap.Program.ActivePreset:
+				t.Capture(snap, ReasonPreset)
+			}
+		}
+	}
+}
+
+// Diff returns what changed from the baseline to prog.
+func (b *Baseline) Diff(prog *stompbox.Program, cfg *stompbox.DumpConfigParsed) *stompbox.ProgramDiff {
+	return stompbox.DiffPrograms(b.Program, prog, cfg)
+}
+
+// Plan lists the commands that turn one program into another, to be sent
+// in field order: chains and slots first so missing instances are created,
+// then params, then releases of instances the target doesn't use.
+type Plan struct {
+	Chains  []ChainSet `json:"chains,omitempty"`
+	Slots   []SlotSet  `json:"slots,omitempty"`
+	Params  []ParamSet `json:"params,omitempty"`
+	Release []string   `json:"release,omitempty"`
+}
+
+type ChainSet struct {
+	Chain   string   `json:"chain"`
+	Plugins []string `json:"plugins"`
+}
+
+type SlotSet struct {
+	Slot   string `json:"slot"`
+	Plugin string `json:"plugin"`
+}
+
+type ParamSet struct {
+	Plugin string `json:"plugin"`
+	Param  string `json:"param"`
+	Value  string `json:"value"`
+}
+
+// Empty reports whether there is nothing to revert.
+func (p *Plan) Empty() bool {
+	return len(p.Chains) == 0 && len(p.Slots) == 0 && len(p.Params) == 0 && len(p.Release) == 0
+}
+
+// RevertPlan returns the commands that restore the baseline from prog
+// without reloading the preset.
+func (b *Baseline) RevertPlan(prog *stompbox.Program) *Plan {
+	return PlanTo(prog, b.Program)
+}
+
+// PlanTo returns the commands that turn the live program from into to.
+// Only what differs is sent.
+func PlanTo(from, to *stompbox.Program) *Plan {
+	d := stompbox.DiffPrograms(from, to, nil)
+	p := &Plan{}
+	for _, c := range d.Chains {
+		p.Chains = append(p.Chains, ChainSet{Chain: c.Chain, Plugins: c.To})
+	}
+	for _, c := range d.Slots {
+		if c.To != "" {
+			p.Slots = append(p.Slots, SlotSet{Slot: c.Slot, Plugin: c.To})
+		}
+	}
+	// Instances the live program lacks come up with default params: set
+	// all of them.
+	for _, inst := range d.AddedPlugins {
+		params := to.Params[inst]
+		for _, name := range sortedKeys(params) {
+			p.Params = append(p.Params, ParamSet{Plugin: inst, Param: name, Value: params[name]})
+		}
+	}
+	for _, c := range d.Params {
+		if c.Change != stompbox.ChangeRemoved {
+			p.Params = append(p.Params, ParamSet{Plugin: c.Plugin, Param: c.Param, Value: c.To})
+		}
+	}
+	p.Release = d.RemovedPlugins
+	return p
+}
+
+func sortedKeys(m map[string]string) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
@@ -0,0 +1,136 @@
+package baseline
+
+import (
+	"context"
+	"reflect"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/alscos/Namnesis/internal/statehub"
+	"github.com/alscos/Namnesis/internal/stompbox"
+)
+
+const loaded = "SetPreset 01_clean\r\n" +
+	"SetChain FxLoop Delay Chorus\r\n" +
+	"SetPluginSlot Amp NAM\r\n" +
+	"SetParam Delay Mix 0.5\r\n" +
+	"SetParam Chorus Rate 1\r\n" +
+	"SetParam NAM Model a.nam\r\n" +
+	"EndProgram\r\nOk\r\n"
+
+const edited = "SetPreset 01_clean\r\n" +
+	"SetChain FxLoop Delay Phaser\r\n" +
+	"SetPluginSlot Amp NAMMulti\r\n" +
+	"SetParam Delay Mix 0.8\r\n" +
+	"SetParam Phaser Rate 2\r\n" +
+	"SetParam NAMMulti Model b.nam\r\n" +
+	"EndProgram\r\nOk\r\n"
+
+func parse(t *testing.T, raw string) *stompbox.Program {
+	t.Helper()
+	p, err := stompbox.ParseDumpProgram(raw)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return p
+}
+
+func TestRevertPlan(t *testing.T) {
+	b := &Baseline{Preset: "01_clean", Program: parse(t, loaded)}
+	if p := b.RevertPlan(parse(t, loaded)); !p.Empty() {
+		t.Fatalf("unchanged program: %+v", p)
+	}
+
+	p := b.RevertPlan(parse(t, edited))
+	if want := []ChainSet{{Chain: "FxLoop", Plugins: []string{"Delay", "Chorus"}}}; !reflect.DeepEqual(p.Chains, want) {
+		t.Fatalf("chains = %+v", p.Chains)
+	}
+	if want := []SlotSet{{Slot: "Amp", Plugin: "NAM"}}; !reflect.DeepEqual(p.Slots, want) {
+		t.Fatalf("slots = %+v", p.Slots)
+	}
+	// Recreated instances get all their params back, then edited ones.
+	want := []ParamSet{
+		{Plugin: "Chorus", Param: "Rate", Value: "1"},
+		{Plugin: "NAM", Param: "Model", Value: "a.nam"},
+		{Plugin: "Delay", Param: "Mix", Value: "0.5"},
+	}
+	if !reflect.DeepEqual(p.Params, want) {
+		t.Fatalf("params = %+v", p.Params)
+	}
+	if !reflect.DeepEqual(p.Release, []string{"NAMMulti", "Phaser"}) {
+		t.Fatalf("release = %v", p.Release)
+	}
+
+	// An empty File value is reverted to "", not left out.
+	cleared := parse(t, strings.Replace(loaded, "Model a.nam", `Model ""`, 1))
+	p = PlanTo(parse(t, loaded), cleared)
+	if want := []ParamSet{{Plugin: "NAM", Param: "Model", Value: ""}}; !reflect.DeepEqual(p.Params, want) {
+		t.Fatalf("empty File value: params = %+v", p.Params)
+	}
+}
+
+type source struct {
+	mu  sync.Mutex
+	raw string
+}
+
+func (s *source) fetch(ctx context.Context) (string, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.raw, nil
+}
+
+func (s *source) set(raw string) {
+	s.mu.Lock()
+	s.raw = raw
+	s.mu.Unlock()
+}
+
+func waitFor(t *testing.T, what string, cond func() bool) {
+	t.Helper()
+	deadline := time.Now().Add(2 * time.Second)
+	for !cond() {
+		if time.Now().After(deadline) {
+			t.Fatal("timed out waiting for " + what)
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+}
+
+func TestTrackerFollowsPresetChanges(t *testing.T) {
+	src := &source{raw: loaded}
+	hub := statehub.New(src.fetch)
+	hub.LiveInterval = 60 * time.Millisecond
+	hub.IdleInterval = 60 * time.Millisecond
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go hub.Run(ctx)
+
+	tr := New(hub)
+	go tr.Run(ctx)
+	waitFor(t, "startup baseline", func() bool { return tr.Current() != nil })
+	if b := tr.Current(); b.Preset != "01_clean" || b.Reason != ReasonStartup {
+		t.Fatalf("startup baseline: %+v", b)
+	}
+
+	// Edits keep the baseline.
+	src.set(edited)
+	waitFor(t, "edit seen", func() bool { return hub.Current() != nil && hub.Current().Revision == 2 })
+	if b := tr.Current(); b.Revision != 1 {
+		t.Fatalf("edit recaptured the baseline: %+v", b)
+	}
+
+	// A preset change from elsewhere (MIDI) recaptures it.
+	src.set("SetPreset 02_lead\r\nSetChain FxLoop Delay\r\nEndProgram\r\nOk\r\n")
+	waitFor(t, "preset change", func() bool { return tr.Current().Preset == "02_lead" })
+	if b := tr.Current(); b.Reason != ReasonPreset {
+		t.Fatalf("preset baseline: %+v", b)
+	}
+
+	b, err := tr.CaptureNow(ctx, ReasonSave)
+	if err != nil || b.Reason != ReasonSave || tr.Current() != b {
+		t.Fatalf("CaptureNow: %+v, %v", b, err)
+	}
+}
@@ -8,28 +8,77 @@ import (
 )
 
 type Config struct {
-	ListenAddr     string
-	StompHost      string
-	StompPort      int
-	DialTimeout    time.Duration
-	ReadTimeout    time.Duration
-	MaxBytes       int64
-	EndMarker      string
-	DumpCommand    string
-	AllowedSubnets []string
+	ListenAddr  string
+	StompHost   string
+	StompPort   int
+	DialTimeout time.Duration
+	ReadTimeout time.Duration
+	IdleTimeout time.Duration
+	// Circuit breaker: failures before Stompbox is marked down, and the
+	// retry backoff range while it is.
+	BreakerThreshold int
+	BackoffMin       time.Duration
+	BackoffMax       time.Duration
+	MaxBytes         int64
+	// MaxSectionBytes caps one Dump Config line (file trees) instead of MaxBytes.
+	MaxSectionBytes int64
+	// MaxConfigBytes caps a whole buffered Dump Config (raw views only).
+	MaxConfigBytes int64
+	TraceSize      int
+	// ConfigRefresh is how often the cached Dump Config is refetched (0 = only on demand).
+	ConfigRefresh time.Duration
+	// StatePollLive and StatePollIdle are the shared Dump Program poll
+	// periods with and without an active client.
+	StatePollLive time.Duration
+	StatePollIdle time.Duration
+	// EventsSystemPoll paces /api/events sysinfo snapshots.
+	EventsSystemPoll time.Duration
+	// DataDir holds gateway state that must survive restarts (preset history).
+	DataDir string
+	// HistoryKeep caps stored versions per preset (0 = keep all).
+	HistoryKeep int
+	// UndoLimit caps undo steps per client session; UndoCoalesce is how
+	// close together sets of one param must be to form a single step.
+	UndoLimit    int
+	UndoCoalesce time.Duration
+	// NudgeCoalesce is how long /api/param/nudge builds on the value it
+	// last sent instead of reading the program again.
+	NudgeCoalesce time.Duration
+	// SchedMaxInFlight caps commands handed to the Stompbox client at once.
+	SchedMaxInFlight int
+	EndMarker        string
+	DumpCommand      string
+	AllowedSubnets   []string
 }
 
 func LoadFromEnv() Config {
 	return Config{
-		ListenAddr:     env("LISTEN_ADDR", "0.0.0.0:3000"),
-		StompHost:      env("STOMPBOX_HOST", "127.0.0.1"),
-		StompPort:      envInt("STOMPBOX_PORT", 0),
-		DialTimeout:    envDuration("DIAL_TIMEOUT", 1*time.Second),
-		ReadTimeout:    envDuration("READ_TIMEOUT", 5*time.Second),
-		MaxBytes:       int64(envInt("MAX_BYTES", 2_000_000)),
-		EndMarker:      env("END_MARKER", "EndConfig"),
-		DumpCommand:    env("DUMP_COMMAND", "Dump Config"),
-		AllowedSubnets: splitCSV(env("ALLOWED_SUBNETS", "")),
+		ListenAddr:       env("LISTEN_ADDR", "0.0.0.0:3000"),
+		StompHost:        env("STOMPBOX_HOST", "127.0.0.1"),
+		StompPort:        envInt("STOMPBOX_PORT", 0),
+		DialTimeout:      envDuration("DIAL_TIMEOUT", 1*time.Second),
+		ReadTimeout:      envDuration("READ_TIMEOUT", 5*time.Second),
+		IdleTimeout:      envDuration("IDLE_TIMEOUT", 30*time.Second),
+		BreakerThreshold: envInt("BREAKER_THRESHOLD", 2),
+		BackoffMin:       envDuration("BACKOFF_MIN", 500*time.Millisecond),
+		BackoffMax:       envDuration("BACKOFF_MAX", 15*time.Second),
+		MaxBytes:         int64(envInt("MAX_BYTES", 2_000_000)),
+		MaxSectionBytes:  int64(envInt("MAX_SECTION_BYTES", 4_000_000)),
+		MaxConfigBytes:   int64(envInt("MAX_CONFIG_BYTES", 16_000_000)),
+		TraceSize:        envInt("TRACE_SIZE", 256),
+		ConfigRefresh:    envDuration("CONFIG_REFRESH", 10*time.Minute),
+		StatePollLive:    envDuration("STATE_POLL_LIVE", 250*time.Millisecond),
+		StatePollIdle:    envDuration("STATE_POLL_IDLE", time.Second),
+		EventsSystemPoll: envDuration("EVENTS_SYSTEM_POLL", time.Second),
+		DataDir:          env("DATA_DIR", "./data"),
+		HistoryKeep:      envInt("HISTORY_KEEP", 50),
+		UndoLimit:        envInt("UNDO_LIMIT", 100),
+		UndoCoalesce:     envDuration("UNDO_COALESCE", time.Second),
+		NudgeCoalesce:    envDuration("NUDGE_COALESCE", 300*time.Millisecond),
+		SchedMaxInFlight: envInt("SCHED_MAX_INFLIGHT", 1),
+		EndMarker:        env("END_MARKER", "EndConfig"),
+		DumpCommand:      env("DUMP_COMMAND", "Dump Config"),
+		AllowedSubnets:   splitCSV(env("ALLOWED_SUBNETS", "")),
 	}
 }
 
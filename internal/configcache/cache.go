@@ -0,0 +1,237 @@
+// Package configcache keeps the last Dump Config in memory so handlers can
+// validate params and look up the plugin catalog without asking Stompbox.
+//
+// Plugin definitions only change when Stompbox restarts or its NAM/IR
+// folders change, so the cache is refreshed on demand (Invalidate, Refresh),
+// on a timer (Run) and when a lookup misses a file that may have just been
+// added (Refresh is rate limited by MinRefresh for that case).
+package configcache
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/json"
+	"sync"
+	"time"
+
+	"github.com/alscos/Namnesis/internal/stompbox"
+)
+
+// Snapshot is one fetched Dump Config. It is never modified after creation.
+type Snapshot struct {
+	Config *stompbox.DumpConfigParsed
+	// Raw is the response text for views that show it; empty when it was
+	// larger than the client's MaxConfigBytes.
+	Raw       string
+	FetchedAt time.Time
+	// Revision increases each time the dump content changes.
+	Revision uint64
+	// Stale is set when the last refresh failed and this older snapshot was served instead.
+	Stale bool
+
+	sum [sha256.Size]byte
+}
+
+// Stats is the introspection view served by /api/debug/config-cache.
+type Stats struct {
+	Loaded      bool      `json:"loaded"`
+	Revision    uint64    `json:"revision"`
+	FetchedAt   time.Time `json:"fetchedAt,omitempty"`
+	Age         string    `json:"age,omitempty"`
+	Plugins     int       `json:"plugins"`
+	Hits        uint64    `json:"hits"`
+	Fetches     uint64    `json:"fetches"`
+	Changes     uint64    `json:"changes"`
+	Invalidated bool      `json:"invalidated"`
+	LastError   string    `json:"lastError,omitempty"`
+}
+
+// Cache holds the current snapshot. The zero value is not usable; call New.
+type Cache struct {
+	// MaxAge makes Get refetch a snapshot older than this (0 = never by age).
+	MaxAge time.Duration
+	// MinRefresh rate-limits Refresh: a snapshot younger than this is
+	// returned as is.
+	MinRefresh time.Duration
+
+	fetch func(context.Context) (*stompbox.DumpConfigParsed, string, error)
+
+	mu         sync.Mutex
+	cur        *Snapshot
+	dirtySince time.Time // zero unless Invalidate was called after cur was fetched
+	inflight   *call
+	hits       uint64
+	fetches    uint64
+	changes    uint64
+	lastErr    string
+}
+
+type call struct {
+	started time.Time
+	done    chan struct{}
+	snap    *Snapshot
+	err     error
+}
+
+// New returns a cache filled by fetch (typically Scheduler.DumpConfigRawCtx,
+// which parses the dump as it streams in).
+func New(fetch func(context.Context) (*stompbox.DumpConfigParsed, string, error)) *Cache {
+	return &Cache{
+		MaxAge:     10 * time.Minute,
+		MinRefresh: 2 * time.Second,
+		fetch:      fetch,
+	}
+}
+
+// Get returns the cached snapshot, fetching it first if there is none, it was
+// invalidated or it is older than MaxAge. If that fetch fails but an older
+// snapshot exists, the older one is returned with Stale set.
+func (c *Cache) Get(ctx context.Context) (*Snapshot, error) {
+	c.mu.Lock()
+	if s := c.cur; s != nil && c.dirtySince.IsZero() && (c.MaxAge <= 0 || time.Since(s.FetchedAt) < c.MaxAge) {
+		c.hits++
+		c.mu.Unlock()
+		return s, nil
+	}
+	notBefore := c.dirtySince
+	c.mu.Unlock()
+	return c.load(ctx, notBefore)
+}
+
+// Refresh fetches a new snapshot unless the current one is younger than
+// MinRefresh. Use it when a lookup misses something that may have just
+// appeared on Stompbox (a new model file).
+func (c *Cache) Refresh(ctx context.Context) (*Snapshot, error) {
+	c.mu.Lock()
+	if s := c.cur; s != nil && c.dirtySince.IsZero() && time.Since(s.FetchedAt) < c.MinRefresh {
+		c.mu.Unlock()
+		return s, nil
+	}
+	c.mu.Unlock()
+	return c.load(ctx, time.Now())
+}
+
+// Invalidate makes the next Get fetch again (e.g. after Stompbox restarted).
+// A fetch already in flight doesn't count: it may predate the change.
+func (c *Cache) Invalidate() {
+	c.mu.Lock()
+	c.dirtySince = time.Now()
+	c.mu.Unlock()
+}
+
+// Run refreshes the snapshot every interval until ctx is done.
+func (c *Cache) Run(ctx context.Context, interval time.Duration) {
+	if interval <= 0 {
+		return
+	}
+	t := time.NewTicker(interval)
+	defer t.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-t.C:
+			c.Invalidate()
+			_, _ = c.Get(ctx)
+		}
+	}
+}
+
+// Stats returns counters and the state of the current snapshot.
+func (c *Cache) Stats() Stats {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	st := Stats{
+		Hits:        c.hits,
+		Fetches:     c.fetches,
+		Changes:     c.changes,
+		Invalidated: !c.dirtySince.IsZero(),
+		LastError:   c.lastErr,
+	}
+	if s := c.cur; s != nil {
+		st.Loaded = true
+		st.Revision = s.Revision
+		st.FetchedAt = s.FetchedAt
+		st.Age = time.Since(s.FetchedAt).Round(time.Second).String()
+		st.Plugins = len(s.Config.Plugins)
+	}
+	return st
+}
+
+// load joins an in-flight fetch started at or after notBefore, or starts one.
+func (c *Cache) load(ctx context.Context, notBefore time.Time) (*Snapshot, error) {
+	for {
+		c.mu.Lock()
+		cl := c.inflight
+		if cl == nil {
+			cl = &call{started: time.Now(), done: make(chan struct{})}
+			c.inflight = cl
+			// Detached from the first caller: others may be waiting on the same result.
+			go c.do(context.WithoutCancel(ctx), cl)
+		}
+		c.mu.Unlock()
+
+		select {
+		case <-cl.done:
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		}
+		if !cl.started.Before(notBefore) {
+			return cl.snap, cl.err
+		}
+		// That fetch began before the invalidation; start another.
+	}
+}
+
+// fetchTimeout bounds a detached fetch.
+const fetchTimeout = 30 * time.Second
+
+func (c *Cache) do(ctx context.Context, cl *call) {
+	ctx, cancel := context.WithTimeout(ctx, fetchTimeout)
+	defer cancel()
+
+	cfg, raw, err := c.fetch(ctx)
+	var sum [sha256.Size]byte
+	if err == nil {
+		// Revisions compare the parsed content; json sorts map keys.
+		var b []byte
+		if b, err = json.Marshal(cfg); err == nil {
+			sum = sha256.Sum256(b)
+		}
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.fetches++
+	c.inflight = nil
+
+	if err != nil {
+		c.lastErr = err.Error()
+		cl.err = err
+		if c.cur != nil {
+			stale := *c.cur
+			stale.Stale = true
+			cl.snap, cl.err = &stale, nil
+		}
+		close(cl.done)
+		return
+	}
+
+	s := &Snapshot{Config: cfg, Raw: raw, FetchedAt: time.Now(), sum: sum}
+	switch {
+	case c.cur == nil:
+		s.Revision = 1
+	case c.cur.sum != s.sum:
+		s.Revision = c.cur.Revision + 1
+		c.changes++
+	default:
+		s.Revision = c.cur.Revision
+	}
+	c.cur = s
+	if !cl.started.Before(c.dirtySince) {
+		c.dirtySince = time.Time{}
+	}
+	c.lastErr = ""
+	cl.snap = s
+	close(cl.done)
+}
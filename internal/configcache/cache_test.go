@@ -0,0 +1,144 @@
+package configcache
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/alscos/Namnesis/internal/stompbox"
+)
+
+const dumpA = "PluginConfig Boost IsUserSelectable 1\r\nParameterConfig Boost Gain Type Knob\r\nEndConfig\r\nOk\r\n"
+const dumpB = "PluginConfig Delay IsUserSelectable 1\r\nEndConfig\r\nOk\r\n"
+
+type fakeSource struct {
+	mu    sync.Mutex
+	raw   string
+	err   error
+	calls atomic.Int32
+	delay time.Duration
+}
+
+func (f *fakeSource) fetch(ctx context.Context) (*stompbox.DumpConfigParsed, string, error) {
+	f.calls.Add(1)
+	f.mu.Lock()
+	raw, err, delay := f.raw, f.err, f.delay
+	f.mu.Unlock()
+	// The dump reflects Stompbox as it was when the fetch started.
+	time.Sleep(delay)
+	if err != nil {
+		return nil, "", err
+	}
+	cfg, err := stompbox.ParseDumpConfig(raw)
+	return cfg, raw, err
+}
+
+func (f *fakeSource) set(raw string, err error) {
+	f.mu.Lock()
+	f.raw, f.err = raw, err
+	f.mu.Unlock()
+}
+
+func TestCacheServesFromMemory(t *testing.T) {
+	src := &fakeSource{raw: dumpA, delay: 20 * time.Millisecond}
+	c := New(src.fetch)
+	ctx := context.Background()
+
+	// Concurrent cold Gets share one fetch.
+	var wg sync.WaitGroup
+	for i := 0; i < 5; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if _, err := c.Get(ctx); err != nil {
+				t.Error(err)
+			}
+		}()
+	}
+	wg.Wait()
+	s, _ := c.Get(ctx)
+	if n := src.calls.Load(); n != 1 {
+		t.Fatalf("fetched %d times; want 1", n)
+	}
+	if s.Revision != 1 || s.Config.Plugins["Boost"] == nil {
+		t.Fatalf("snapshot: rev=%d plugins=%v", s.Revision, s.Config.Order)
+	}
+
+	// Same content after invalidation keeps the revision.
+	c.Invalidate()
+	if s, _ := c.Get(ctx); s.Revision != 1 || src.calls.Load() != 2 {
+		t.Fatalf("after invalidate: rev=%d calls=%d", s.Revision, src.calls.Load())
+	}
+
+	// Refresh is rate limited.
+	if _, err := c.Refresh(ctx); err != nil || src.calls.Load() != 2 {
+		t.Fatalf("Refresh within MinRefresh fetched again (calls=%d, err=%v)", src.calls.Load(), err)
+	}
+	c.MinRefresh = 0
+	src.set(dumpB, nil)
+	if s, _ := c.Refresh(ctx); s.Revision != 2 || s.Config.Plugins["Delay"] == nil {
+		t.Fatalf("changed dump: rev=%d plugins=%v", s.Revision, s.Config.Order)
+	}
+}
+
+func TestCacheServesStaleOnError(t *testing.T) {
+	src := &fakeSource{raw: dumpA}
+	c := New(src.fetch)
+	ctx := context.Background()
+	if _, err := c.Get(ctx); err != nil {
+		t.Fatal(err)
+	}
+
+	src.set("", errors.New("stompbox down"))
+	c.Invalidate()
+	s, err := c.Get(ctx)
+	if err != nil || !s.Stale || s.Config.Plugins["Boost"] == nil {
+		t.Fatalf("want stale snapshot, got %+v, %v", s, err)
+	}
+	if st := c.Stats(); st.LastError == "" || !st.Invalidated {
+		t.Fatalf("stats: %+v", st)
+	}
+
+	empty := New(src.fetch)
+	if _, err := empty.Get(ctx); err == nil {
+		t.Fatal("cold cache should return the fetch error")
+	}
+}
+
+func TestInvalidateDuringFetch(t *testing.T) {
+	src := &fakeSource{raw: dumpA, delay: 30 * time.Millisecond}
+	c := New(src.fetch)
+	ctx := context.Background()
+
+	// A fetch starts, then Stompbox changes and the cache is invalidated
+	// while it is still in flight.
+	first := make(chan *Snapshot)
+	go func() {
+		s, _ := c.Get(ctx)
+		first <- s
+	}()
+	for src.calls.Load() == 0 {
+		time.Sleep(time.Millisecond)
+	}
+	src.set(dumpB, nil)
+	c.Invalidate()
+
+	// The in-flight result predates the change: it must not be joined...
+	s, err := c.Get(ctx)
+	if err != nil || s.Config.Plugins["Delay"] == nil {
+		t.Fatalf("Get after Invalidate returned the older dump: %v %v", s, err)
+	}
+	if s := <-first; s.Config.Plugins["Boost"] == nil {
+		t.Fatalf("first fetch: %v", s.Config.Order)
+	}
+	// ...nor leave the cache marked fresh.
+	if st := c.Stats(); st.Invalidated || src.calls.Load() != 2 {
+		t.Fatalf("stats after refetch: %+v (calls=%d)", st, src.calls.Load())
+	}
+	if s, _ := c.Get(ctx); s.Config.Plugins["Delay"] == nil {
+		t.Fatalf("cached snapshot is the older dump: %v", s.Config.Order)
+	}
+}
@@ -0,0 +1,94 @@
+package httpserver
+
+import (
+	"context"
+	"errors"
+	"math"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/alscos/Namnesis/internal/stompbox"
+)
+
+// Machine-readable error codes, sent in the X-Error-Code header
+// (and in the body for clients that accept JSON).
+const (
+	codeStompboxRejected    = "stompbox_rejected"    // Stompbox answered "Error ..."
+	codeStompboxUnavailable = "stompbox_unavailable" // could not connect, or breaker open
+	codeStompboxTransport   = "stompbox_transport"   // session broke mid-command
+	codeStompboxTimeout     = "stompbox_timeout"     // no answer within ReadTimeout/DialTimeout
+	codeStompboxOversize    = "stompbox_oversize"    // response exceeded MaxBytes
+	codeStompboxIncomplete  = "stompbox_incomplete"  // session closed before the terminator
+	codeRequestTimeout      = "request_timeout"      // HTTP request deadline hit first
+	codeRequestCanceled     = "request_canceled"     // client went away
+	codeUpstream            = "upstream_error"       // anything else from the client
+	codeNoHistory           = "no_history"           // delete refused: nothing to put in the trash
+)
+
+type errorResponse struct {
+	OK      bool   `json:"ok"`
+	Code    string `json:"code"`
+	Error   string `json:"error"`
+	Command string `json:"command,omitempty"`
+}
+
+// sbErrorStatus maps an error returned by stompbox.Client to an HTTP status and error code.
+func sbErrorStatus(err error) (int, string) {
+	var (
+		ue *stompbox.UnavailableError
+		pe *stompbox.ProtocolError
+		te *stompbox.TimeoutError
+		xe *stompbox.TransportError
+		oe *stompbox.OversizeError
+		ie *stompbox.IncompleteResponseError
+	)
+	switch {
+	case errors.As(err, &ue):
+		return http.StatusServiceUnavailable, codeStompboxUnavailable
+	case errors.As(err, &pe):
+		return http.StatusUnprocessableEntity, codeStompboxRejected
+	case errors.As(err, &te):
+		return http.StatusGatewayTimeout, codeStompboxTimeout
+	case errors.Is(err, context.DeadlineExceeded):
+		return http.StatusGatewayTimeout, codeRequestTimeout
+	case errors.Is(err, context.Canceled):
+		return http.StatusServiceUnavailable, codeRequestCanceled
+	case errors.As(err, &xe):
+		if xe.Op == "dial" {
+			return http.StatusServiceUnavailable, codeStompboxUnavailable
+		}
+		return http.StatusBadGateway, codeStompboxTransport
+	case errors.As(err, &oe):
+		return http.StatusBadGateway, codeStompboxOversize
+	case errors.As(err, &ie):
+		return http.StatusBadGateway, codeStompboxIncomplete
+	default:
+		return http.StatusBadGateway, codeUpstream
+	}
+}
+
+// writeSBError reports a stompbox.Client failure. The body stays plain text
+// ("<prefix>: <err>") for the web UI unless the client asks for JSON.
+func writeSBError(w http.ResponseWriter, r *http.Request, prefix string, err error) {
+	status, code := sbErrorStatus(err)
+	w.Header().Set("X-Error-Code", code)
+	var ue *stompbox.UnavailableError
+	if errors.As(err, &ue) {
+		secs := int(math.Ceil(time.Until(ue.RetryAt).Seconds()))
+		w.Header().Set("Retry-After", strconv.Itoa(max(secs, 1)))
+	}
+
+	if strings.Contains(r.Header.Get("Accept"), "application/json") {
+		resp := errorResponse{Code: code, Error: err.Error()}
+		var pe *stompbox.ProtocolError
+		if errors.As(err, &pe) {
+			resp.Error = pe.Message
+			resp.Command = pe.Command
+		}
+		writeJSON(w, status, resp)
+		return
+	}
+	http.Error(w, prefix+": "+err.Error(), status)
+}
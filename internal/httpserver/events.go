@@ -0,0 +1,197 @@
+package httpserver
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/alscos/Namnesis/internal/stompbox"
+)
+
+// Event types pushed on /api/events.
+const (
+	EventPreset = "preset" // active preset changed (everything else may have too)
+	EventChain  = "chain"  // a chain's plugin list changed
+	EventSlot   = "slot"   // a slot holds another plugin
+	EventParam  = "param"  // a param value changed
+	EventEngine = "engine" // Stompbox went up or down
+	EventSystem = "system" // sysinfo snapshot
+)
+
+// Event is one Server-Sent Event. ID increases by one per published event,
+// so a client can tell it missed some (slow subscribers are skipped).
+type Event struct {
+	ID   uint64    `json:"id"`
+	Type string    `json:"type"`
+	Time time.Time `json:"time"`
+	Data any       `json:"data"`
+}
+
+type presetEvent struct {
+	Preset   string `json:"preset"`
+	Previous string `json:"previous"`
+}
+
+type chainEvent struct {
+	Chain   string   `json:"chain"`
+	Plugins []string `json:"plugins"` // nil when the chain disappeared
+}
+
+type slotEvent struct {
+	Slot   string `json:"slot"`
+	Plugin string `json:"plugin"`
+}
+
+type paramEvent struct {
+	Plugin string `json:"plugin"`
+	Param  string `json:"param"`
+	Value  string `json:"value"`
+}
+
+// eventHub fans events out to /api/events subscribers. It only watches the
+// state hub while at least one subscriber is connected, and never runs two
+// watch loops at once.
+type eventHub struct {
+	s *Server
+
+	mu      sync.Mutex
+	seq     uint64 // ID of the last published event; the first is 1
+	subs    map[chan Event]struct{}
+	running bool          // a run loop exists
+	idle    chan struct{} // nudges run to re-check for subscribers
+}
+
+func newEventHub(s *Server) *eventHub {
+	return &eventHub{s: s, subs: make(map[chan Event]struct{}), idle: make(chan struct{}, 1)}
+}
+
+// subscribe registers a subscriber, starts polling for the first one and
+// publishes the current engine state, so the new subscriber's first event
+// has a real ID. Other subscribers see it as a repeated engine event.
+func (h *eventHub) subscribe(buffer int) (<-chan Event, func()) {
+	ch := make(chan Event, buffer)
+	h.mu.Lock()
+	h.subs[ch] = struct{}{}
+	if !h.running {
+		h.running = true
+		go h.run()
+	}
+	h.publishLocked(EventEngine, stompbox.Availability{Up: h.s.sb.Available()})
+	h.mu.Unlock()
+
+	var once sync.Once
+	return ch, func() {
+		once.Do(func() {
+			h.mu.Lock()
+			delete(h.subs, ch)
+			empty := len(h.subs) == 0
+			h.mu.Unlock()
+			if empty {
+				select {
+				case h.idle <- struct{}{}:
+				default:
+				}
+			}
+		})
+	}
+}
+
+func (h *eventHub) publish(typ string, data any) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.publishLocked(typ, data)
+}
+
+func (h *eventHub) publishLocked(typ string, data any) {
+	h.seq++
+	e := Event{ID: h.seq, Type: typ, Time: time.Now(), Data: data}
+	for ch := range h.subs {
+		select {
+		case ch <- e:
+		default:
+		}
+	}
+}
+
+// stopIfIdle ends the run loop when nobody is subscribed. The check and
+// the flag change share the lock with subscribe, so a subscriber arriving
+// now either keeps this loop or starts the next one after it.
+func (h *eventHub) stopIfIdle() bool {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if len(h.subs) > 0 {
+		return false
+	}
+	h.running = false
+	return true
+}
+
+// defaultEventsSystemPoll is used when Config.EventsSystemPoll is zero.
+const defaultEventsSystemPoll = time.Second
+
+// run diffs every program revision from the state hub against the previous
+// one. Being connected is "live mode": the hub polls at its fast rate.
+func (h *eventHub) run() {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	systemEvery := h.s.cfg.EventsSystemPoll
+	if systemEvery <= 0 {
+		systemEvery = defaultEventsSystemPoll
+	}
+
+	snaps, stopSnaps := h.s.programs.Subscribe(true)
+	defer stopSnaps()
+	avail, stopAvail := h.s.sb.WatchAvailability(4)
+	defer stopAvail()
+	systemTick := time.NewTicker(systemEvery)
+	defer systemTick.Stop()
+
+	var prev *stompbox.Program
+	for {
+		select {
+		case <-h.idle:
+			if h.stopIfIdle() {
+				return
+			}
+		case a := <-avail:
+			h.publish(EventEngine, a)
+		case <-systemTick.C:
+			if h.s.sys != nil {
+				h.publish(EventSystem, h.s.sys.Snapshot(ctx))
+			}
+		case snap := <-snaps:
+			if prev != nil {
+				for _, e := range diffProgramEvents(prev, snap.Program) {
+					h.publish(e.Type, e.Data)
+				}
+			}
+			prev = snap.Program
+		}
+	}
+}
+
+// diffProgramEvents turns the difference between two Dump Program results
+// into events. A preset change is reported alone: the whole program changes
+// with it and clients reload everything anyway. Params of new instances are
+// covered by their chain or slot event.
+func diffProgramEvents(prev, cur *stompbox.Program) []Event {
+	if prev.ActivePreset != cur.ActivePreset {
+		return []Event{{Type: EventPreset, Data: presetEvent{Preset: cur.ActivePreset, Previous: prev.ActivePreset}}}
+	}
+
+	d := stompbox.DiffPrograms(prev, cur, nil)
+	var out []Event
+	for _, c := range d.Chains {
+		out = append(out, Event{Type: EventChain, Data: chainEvent{Chain: c.Chain, Plugins: c.To}})
+	}
+	for _, c := range d.Slots {
+		out = append(out, Event{Type: EventSlot, Data: slotEvent{Slot: c.Slot, Plugin: c.To}})
+	}
+	for _, c := range d.Params {
+		if c.Change != stompbox.ChangeRemoved {
+			out = append(out, Event{Type: EventParam, Data: paramEvent{Plugin: c.Plugin, Param: c.Param, Value: c.To}})
+		}
+	}
+	return out
+}
@@ -9,13 +9,13 @@ import (
 )
 
 func (s *Server) handlePresetHuman(w http.ResponseWriter, r *http.Request) {
-	raw, err := s.sb.DumpProgram()
+	snap, err := s.programSnapshot(r)
 	if err != nil {
-		http.Error(w, "program error: "+err.Error(), http.StatusBadGateway)
+		writeSBError(w, r, "program error", err)
 		return
 	}
 
-	num, name, amp, fx := oled.HumanizeFromDumpProgram(raw)
+	num, name, amp, fx := oled.HumanizeFromDumpProgram(snap.Raw)
 
 	resp := struct {
 		Loaded bool   `json:"loaded"`
@@ -0,0 +1,166 @@
+package httpserver
+
+import (
+	"context"
+	"errors"
+	"log"
+	"net/http"
+	"time"
+
+	"github.com/alscos/Namnesis/internal/baseline"
+	"github.com/alscos/Namnesis/internal/stompbox"
+)
+
+type baselineInfo struct {
+	Preset     string    `json:"preset"`
+	Reason     string    `json:"reason"`
+	Revision   uint64    `json:"revision"`
+	CapturedAt time.Time `json:"capturedAt"`
+}
+
+func newBaselineInfo(b *baseline.Baseline) baselineInfo {
+	return baselineInfo{Preset: b.Preset, Reason: b.Reason, Revision: b.Revision, CapturedAt: b.CapturedAt}
+}
+
+// captureBaseline records the program right after a preset load or save.
+// The request already succeeded, so a failed dump is only logged (and nil
+// returned): the tracker still picks up a preset change on its next snapshot.
+func (s *Server) captureBaseline(r *http.Request, reason string) *baseline.Baseline {
+	b, err := s.baseline.CaptureNow(r.Context(), reason)
+	if err != nil {
+		log.Printf("baseline: capture after %s: %v", reason, err)
+	}
+	return b
+}
+
+// GET /api/preset/dirty
+// What changed since the active preset was loaded or last saved.
+func (s *Server) handlePresetDirty(w http.ResponseWriter, r *http.Request) {
+	psnap, err := s.programSnapshot(r)
+	if err != nil {
+		writeSBError(w, r, "program error", err)
+		return
+	}
+	base := s.baseline.Current()
+	if base == nil {
+		http.Error(w, "no baseline yet", http.StatusServiceUnavailable)
+		return
+	}
+
+	d := base.Diff(psnap.Program, s.diffConfig(r))
+	writeJSON(w, http.StatusOK, map[string]any{
+		"preset":   psnap.Program.ActivePreset,
+		"dirty":    !d.Empty(),
+		"revision": psnap.Revision,
+		"baseline": newBaselineInfo(base),
+		"diff":     d,
+		"summary":  diffSummary(d),
+	})
+}
+
+// POST /api/preset/revert
+// Puts chains, slots and params back as they were in the baseline without
+// reloading the preset (no audio gap, no re-read of model files).
+func (s *Server) handlePresetRevert(w http.ResponseWriter, r *http.Request) {
+	s.editMu.Lock()
+	defer s.editMu.Unlock()
+
+	cur, err := s.freshProgram(r)
+	if err != nil {
+		writeSBError(w, r, "program error", err)
+		return
+	}
+	base := s.baseline.Current()
+	if base == nil {
+		http.Error(w, "no baseline yet", http.StatusServiceUnavailable)
+		return
+	}
+	if base.Preset != cur.ActivePreset {
+		http.Error(w, "active preset changed since the baseline was taken; load the preset instead", http.StatusConflict)
+		return
+	}
+
+	// A long plan must not be cut off by the request timeout and leave the
+	// program half reverted.
+	ctx, cancel := detachedContext(r)
+	defer cancel()
+
+	plan := base.RevertPlan(cur)
+	res, err := s.applyPlan(ctx, plan)
+	if err != nil {
+		writeSBError(w, r, "revert error", err)
+		return
+	}
+
+	snap, err := s.programs.Refresh(ctx)
+	if err != nil {
+		writeSBError(w, r, "program error", err)
+		return
+	}
+	after := snap.Program
+	writeJSON(w, http.StatusOK, map[string]any{
+		"ok":       true,
+		"preset":   base.Preset,
+		"applied":  res.Applied,
+		"released": res.Released,
+		"skipped":  res.Skipped,
+		"plan":     plan,
+		"dirty":    !base.Diff(after, nil).Empty(),
+	})
+}
+
+// planTimeout bounds a multi-command edit run past the request timeout.
+const planTimeout = 30 * time.Second
+
+// detachedContext returns a context for edits that must run to completion
+// once started: it ignores the request's cancellation and timeout and has
+// its own bound, planTimeout.
+func detachedContext(r *http.Request) (context.Context, context.CancelFunc) {
+	return context.WithTimeout(context.WithoutCancel(r.Context()), planTimeout)
+}
+
+type planResult struct {
+	Applied  int      `json:"applied"`
+	Released []string `json:"released"`
+	Skipped  []string `json:"skipped"`
+}
+
+// applyPlan sends a baseline.Plan. A param Stompbox rejects (e.g. one a
+// plugin update dropped) is skipped so one stale value doesn't leave the
+// program half changed; releases only free memory, so their failures are
+// logged. Any other error stops at the failing command.
+func (s *Server) applyPlan(ctx context.Context, plan *baseline.Plan) (planResult, error) {
+	res := planResult{Released: []string{}, Skipped: []string{}}
+	for _, c := range plan.Chains {
+		if err := s.sched.SetChainCtx(ctx, c.Chain, c.Plugins); err != nil {
+			return res, err
+		}
+		res.Applied++
+	}
+	for _, c := range plan.Slots {
+		if err := s.sched.SetPluginSlotCtx(ctx, c.Slot, c.Plugin); err != nil {
+			return res, err
+		}
+		res.Applied++
+	}
+	for _, p := range plan.Params {
+		err := s.sched.SetParamCtx(ctx, p.Plugin, p.Param, p.Value)
+		var pe *stompbox.ProtocolError
+		switch {
+		case errors.As(err, &pe):
+			res.Skipped = append(res.Skipped, p.Plugin+"."+p.Param+": "+pe.Error())
+			continue
+		case err != nil:
+			return res, err
+		}
+		res.Applied++
+	}
+	for _, inst := range plan.Release {
+		if err := s.sched.ReleasePluginCtx(ctx, inst); err != nil {
+			log.Printf("apply plan: release %s: %v", inst, err)
+			continue
+		}
+		res.Released = append(res.Released, inst)
+	}
+	return res, nil
+}
@@ -2,9 +2,12 @@ package httpserver
 
 import (
 	"encoding/json"
+	"fmt"
 	"net/http"
+	"slices"
 	"strings"
 
+	"github.com/alscos/Namnesis/internal/stompbox"
 	"github.com/go-chi/chi/v5"
 )
 
@@ -36,10 +39,14 @@ func (s *Server) handleChainSet(w http.ResponseWriter, r *http.Request) {
 		clean = append(clean, t)
 	}
 
-	if err := s.sb.SetChain(chain, clean); err != nil {
-		http.Error(w, "setchain error: "+err.Error(), http.StatusBadGateway)
+	sess := editSession(w, r)
+	step := s.chainUndoStep(r, chain, clean)
+
+	if err := s.sched.SetChainCtx(r.Context(), chain, clean); err != nil {
+		writeSBError(w, r, "setchain error", err)
 		return
 	}
+	s.recordEdit(sess, step, chainPlan(chain, clean))
 
 	w.Header().Set("Content-Type", "application/json; charset=utf-8")
 	_ = json.NewEncoder(w).Encode(map[string]any{
@@ -57,8 +64,8 @@ func (s *Server) handlePluginRelease(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	if err := s.sb.ReleasePlugin(plugin); err != nil {
-		http.Error(w, "releaseplugin error: "+err.Error(), http.StatusBadGateway)
+	if err := s.sched.ReleasePluginCtx(r.Context(), plugin); err != nil {
+		writeSBError(w, r, "releaseplugin error", err)
 		return
 	}
 
@@ -68,3 +75,126 @@ func (s *Server) handlePluginRelease(w http.ResponseWriter, r *http.Request) {
 		"plugin": plugin,
 	})
 }
+
+type chainAddPluginRequest struct {
+	Type string `json:"type"`
+	// Index is the insert position in the chain; omitted means append.
+	Index *int `json:"index,omitempty"`
+}
+
+// POST /api/chains/{chain}/plugins
+// Body: {"type":"Delay","index":2}
+// Inserts a new instance of a plugin type and returns the instance Stompbox
+// created, read back from a follow-up Dump Program.
+func (s *Server) handleChainAddPlugin(w http.ResponseWriter, r *http.Request) {
+	chain := strings.TrimSpace(chi.URLParam(r, "chain"))
+	if chain == "" {
+		http.Error(w, "missing chain", http.StatusBadRequest)
+		return
+	}
+
+	var req chainAddPluginRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "bad json", http.StatusBadRequest)
+		return
+	}
+	typ := strings.TrimSpace(req.Type)
+	if typ == "" {
+		http.Error(w, "missing type", http.StatusBadRequest)
+		return
+	}
+
+	// 1) The type must be in the catalog and selectable by the user.
+	snap, err := s.configSnapshot(r)
+	if err != nil {
+		writeSBError(w, r, "dumpconfig error", err)
+		return
+	}
+	def := snap.Config.Plugins[typ]
+	if def == nil {
+		http.Error(w, "unknown plugin type: "+typ, http.StatusBadRequest)
+		return
+	}
+	if def.IsUserSelectable != nil && !*def.IsUserSelectable {
+		http.Error(w, "plugin type is not user selectable: "+typ, http.StatusBadRequest)
+		return
+	}
+
+	// Read-modify-write of the chain: keep concurrent inserts from losing each other.
+	s.editMu.Lock()
+	defer s.editMu.Unlock()
+
+	// 2) Insert the bare type at the requested position; Stompbox names
+	// the new instance itself.
+	before, err := s.freshProgram(r)
+	if err != nil {
+		writeSBError(w, r, "program error", err)
+		return
+	}
+	current, ok := before.Chains[chain]
+	if !ok {
+		http.Error(w, "unknown chain: "+chain, http.StatusNotFound)
+		return
+	}
+	idx := len(current)
+	if req.Index != nil {
+		idx = *req.Index
+	}
+	if idx < 0 || idx > len(current) {
+		http.Error(w, fmt.Sprintf("index out of range: %d (chain has %d plugins)", idx, len(current)), http.StatusBadRequest)
+		return
+	}
+
+	plugins := make([]string, 0, len(current)+1)
+	plugins = append(plugins, current[:idx]...)
+	plugins = append(plugins, typ)
+	plugins = append(plugins, current[idx:]...)
+
+	if err := s.sched.SetChainCtx(r.Context(), chain, plugins); err != nil {
+		writeSBError(w, r, "setchain error", err)
+		return
+	}
+
+	// 3) Read back what Stompbox actually created: the one entry the
+	// chain gained.
+	after, err := s.freshProgram(r)
+	if err != nil {
+		writeSBError(w, r, "program error", err)
+		return
+	}
+	got := after.Chains[chain]
+	instance, at, ok := insertedEntry(current, got)
+	if !ok || stompbox.PluginType(instance) != typ {
+		http.Error(w, fmt.Sprintf("chain %s did not gain one %s: %v -> %v", chain, typ, current, got), http.StatusBadGateway)
+		return
+	}
+	idx = at
+	s.undo.Record(editSession(w, r), chainAddUndoStep(chain, current, got, instance))
+
+	w.Header().Set("Content-Type", "application/json; charset=utf-8")
+	_ = json.NewEncoder(w).Encode(map[string]any{
+		"ok":       true,
+		"chain":    chain,
+		"type":     typ,
+		"instance": instance,
+		"index":    idx,
+		"plugins":  got,
+		"params":   after.Params[instance],
+	})
+}
+
+// insertedEntry finds the single entry after has over before, the lists
+// being otherwise equal and in the same order.
+func insertedEntry(before, after []string) (string, int, bool) {
+	if len(after) != len(before)+1 {
+		return "", 0, false
+	}
+	i := 0
+	for i < len(before) && after[i] == before[i] {
+		i++
+	}
+	if !slices.Equal(after[i+1:], before[i:]) {
+		return "", 0, false
+	}
+	return after[i], i, true
+}
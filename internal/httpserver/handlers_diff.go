@@ -0,0 +1,153 @@
+package httpserver
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/alscos/Namnesis/internal/stompbox"
+)
+
+// maxDiffBody caps POST /api/diff: two program scripts plus JSON overhead.
+const maxDiffBody = 4 << 20
+
+type diffRequest struct {
+	// An omitted side is the current program.
+	From *diffSource `json:"from"`
+	To   *diffSource `json:"to"`
+}
+
+// diffSource is one side of a diff: a program script (as exported by
+// /api/program/export) or raw Dump Program given as a JSON string, or a
+// captured snapshot given as an object:
+//
+//	{"source":"current"}
+//	{"source":"baseline"}
+//	{"source":"history","preset":"Clean","seq":3} (seq omitted = newest)
+type diffSource struct {
+	Script *string `json:"-"`
+	Source string  `json:"source"`
+	Preset string  `json:"preset"`
+	Seq    int     `json:"seq"`
+}
+
+func (d *diffSource) UnmarshalJSON(b []byte) error {
+	if bytes.HasPrefix(bytes.TrimSpace(b), []byte(`"`)) {
+		return json.Unmarshal(b, &d.Script)
+	}
+	type plain diffSource
+	return json.Unmarshal(b, (*plain)(d))
+}
+
+type diffResponse struct {
+	Empty   bool                  `json:"empty"`
+	Diff    *stompbox.ProgramDiff `json:"diff"`
+	Summary []string              `json:"summary"`
+}
+
+// POST /api/diff
+// Body: {"from":"SetPreset ...\r\n...","to":{"source":"baseline"}}
+func (s *Server) handleDiff(w http.ResponseWriter, r *http.Request) {
+	var req diffRequest
+	if err := json.NewDecoder(http.MaxBytesReader(w, r.Body, maxDiffBody)).Decode(&req); err != nil {
+		http.Error(w, "bad json", http.StatusBadRequest)
+		return
+	}
+	if req.From == nil && req.To == nil {
+		http.Error(w, "missing from/to: give at least one program script or snapshot", http.StatusBadRequest)
+		return
+	}
+
+	from, ok := s.diffSide(w, r, "from", req.From)
+	if !ok {
+		return
+	}
+	to, ok := s.diffSide(w, r, "to", req.To)
+	if !ok {
+		return
+	}
+
+	d := stompbox.DiffPrograms(from, to, s.diffConfig(r))
+	writeJSON(w, http.StatusOK, diffResponse{Empty: d.Empty(), Diff: d, Summary: diffSummary(d)})
+}
+
+// diffSide resolves one side of a diff, writing the error response if it
+// can't.
+func (s *Server) diffSide(w http.ResponseWriter, r *http.Request, name string, src *diffSource) (*stompbox.Program, bool) {
+	if src != nil && src.Script != nil {
+		p, err := stompbox.ParseDumpProgram(*src.Script)
+		if err != nil {
+			http.Error(w, name+": "+err.Error(), http.StatusBadRequest)
+			return nil, false
+		}
+		return p, true
+	}
+	source := "current"
+	if src != nil && src.Source != "" {
+		source = src.Source
+	}
+	switch source {
+	case "current":
+		snap, err := s.programSnapshot(r)
+		if err != nil {
+			writeSBError(w, r, "program error", err)
+			return nil, false
+		}
+		return snap.Program, true
+	case "baseline":
+		base := s.baseline.Current()
+		if base == nil {
+			http.Error(w, name+": no baseline yet", http.StatusServiceUnavailable)
+			return nil, false
+		}
+		return base.Program, true
+	case "history":
+		preset := strings.TrimSpace(src.Preset)
+		if err := validatePresetName(preset); err != nil {
+			http.Error(w, name+": invalid preset name: "+err.Error(), http.StatusBadRequest)
+			return nil, false
+		}
+		var (
+			script string
+			err    error
+		)
+		if src.Seq == 0 {
+			_, script, err = s.history.Latest(preset)
+		} else {
+			_, script, err = s.history.Get(preset, src.Seq)
+		}
+		if err != nil {
+			writeHistoryError(w, err)
+			return nil, false
+		}
+		p, err := stompbox.ParseDumpProgram(script)
+		if err != nil {
+			http.Error(w, "stored version unreadable: "+err.Error(), http.StatusInternalServerError)
+			return nil, false
+		}
+		return p, true
+	default:
+		http.Error(w, fmt.Sprintf("%s: unknown source %q (current, baseline or history)", name, source), http.StatusBadRequest)
+		return nil, false
+	}
+}
+
+// diffSummary is d.String() as one entry per change.
+func diffSummary(d *stompbox.ProgramDiff) []string {
+	text := strings.TrimSuffix(d.String(), "\n")
+	if text == "" {
+		return []string{}
+	}
+	return strings.Split(text, "\n")
+}
+
+// diffConfig returns Dump Config for typing diffs, or nil: a diff is still
+// useful without it.
+func (s *Server) diffConfig(r *http.Request) *stompbox.DumpConfigParsed {
+	if snap, err := s.cfgCache.Get(r.Context()); err == nil {
+		return snap.Config
+	}
+	return nil
+}
@@ -2,14 +2,18 @@ package httpserver
 
 import (
 	"encoding/json"
-	"github.com/alscos/Namnesis/internal/stompbox"
 	"net/http"
+	"strings"
+
+	"github.com/alscos/Namnesis/internal/configcache"
+	"github.com/alscos/Namnesis/internal/statehub"
+	"github.com/alscos/Namnesis/internal/stompbox"
 )
 
 func (s *Server) handleDumpConfigRaw(w http.ResponseWriter, r *http.Request) {
-	out, err := s.sb.DumpConfig()
+	out, err := s.sched.DumpConfigCtx(r.Context())
 	if err != nil {
-		http.Error(w, "dumpconfig error: "+err.Error(), http.StatusBadGateway)
+		writeSBError(w, r, "dumpconfig error", err)
 		return
 	}
 	w.Header().Set("Content-Type", "text/plain; charset=utf-8")
@@ -17,49 +21,131 @@ func (s *Server) handleDumpConfigRaw(w http.ResponseWriter, r *http.Request) {
 }
 
 func (s *Server) handleProgramRaw(w http.ResponseWriter, r *http.Request) {
-	out, err := s.sb.DumpProgram()
+	snap, err := s.programSnapshot(r)
 	if err != nil {
-		http.Error(w, "program error: "+err.Error(), http.StatusBadGateway)
+		writeSBError(w, r, "program error", err)
 		return
 	}
 	w.Header().Set("Content-Type", "text/plain; charset=utf-8")
-	_, _ = w.Write([]byte(out))
+	_, _ = w.Write([]byte(snap.Raw))
 }
 
 func (s *Server) handleConfigParsedDebug(w http.ResponseWriter, r *http.Request) {
-	raw, err := s.sb.DumpConfig()
+	snap, err := s.configSnapshot(r)
 	if err != nil {
-		http.Error(w, "dumpconfig error: "+err.Error(), http.StatusBadGateway)
+		writeSBError(w, r, "dumpconfig error", err)
 		return
 	}
 
-	parsed, err := stompbox.ParseDumpConfig(raw)
+	parsed := snap.Config
+	writeParsed(w, r, parsed.Diagnostics, parsed)
+}
+
+func (s *Server) handleProgramParsedDebug(w http.ResponseWriter, r *http.Request) {
+	snap, err := s.programSnapshot(r)
 	if err != nil {
-		http.Error(w, "parse error: "+err.Error(), http.StatusInternalServerError)
+		writeSBError(w, r, "program error", err)
 		return
 	}
 
-	w.Header().Set("Content-Type", "application/json; charset=utf-8")
-	enc := json.NewEncoder(w)
-	enc.SetIndent("", "  ")
-	_ = enc.Encode(parsed)
+	parsed := snap.Program
+	writeParsed(w, r, parsed.Diagnostics, parsed)
 }
 
-func (s *Server) handleProgramParsedDebug(w http.ResponseWriter, r *http.Request) {
-	raw, err := s.sb.DumpProgram()
+// GET /api/debug/program-script
+// Ordered, lossless program lines with SetParam values typed from Dump Config.
+func (s *Server) handleProgramScriptDebug(w http.ResponseWriter, r *http.Request) {
+	snap, err := s.programSnapshot(r)
+	if err != nil {
+		writeSBError(w, r, "program error", err)
+		return
+	}
+	script, err := stompbox.ParseProgramScript(snap.Raw)
 	if err != nil {
-		http.Error(w, "program error: "+err.Error(), http.StatusBadGateway)
+		http.Error(w, "parse error: "+err.Error(), http.StatusInternalServerError)
 		return
 	}
 
-	parsed, err := stompbox.ParseDumpProgram(raw)
+	// Typing is best effort: the lines are still useful without Dump Config.
+	if snap, err := s.cfgCache.Get(r.Context()); err == nil {
+		script.ApplyConfig(snap.Config)
+	}
+
+	writeParsed(w, r, script.Diagnostics, script)
+}
+
+// GET /api/program/export
+// The current program as a replayable script (Dump Program without its framing).
+func (s *Server) handleProgramExport(w http.ResponseWriter, r *http.Request) {
+	snap, err := s.programSnapshot(r)
+	if err != nil {
+		writeSBError(w, r, "program error", err)
+		return
+	}
+	script, err := stompbox.ParseProgramScript(snap.Raw)
 	if err != nil {
 		http.Error(w, "parse error: "+err.Error(), http.StatusInternalServerError)
 		return
 	}
 
+	name := strings.ReplaceAll(script.ActivePreset(), `"`, "")
+	if name == "" {
+		name = "program"
+	}
+	w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+	w.Header().Set("Content-Disposition", `attachment; filename="`+name+`.txt"`)
+	_, _ = w.Write([]byte(script.Serialize()))
+}
+
+// writeParsed encodes a parse result. With ?strict=1 any parser diagnostic
+// turns the response into a 422, for CI checks against a real Stompbox.
+func writeParsed(w http.ResponseWriter, r *http.Request, diags stompbox.Diagnostics, v any) {
+	status := http.StatusOK
+	if r.URL.Query().Get("strict") == "1" && len(diags) > 0 {
+		status = http.StatusUnprocessableEntity
+	}
 	w.Header().Set("Content-Type", "application/json; charset=utf-8")
+	w.WriteHeader(status)
 	enc := json.NewEncoder(w)
 	enc.SetIndent("", "  ")
-	_ = enc.Encode(parsed)
+	_ = enc.Encode(v)
+}
+
+// configSnapshot returns the cached Dump Config; ?refresh=1 fetches it again.
+func (s *Server) configSnapshot(r *http.Request) (*configcache.Snapshot, error) {
+	if r.URL.Query().Get("refresh") == "1" {
+		s.cfgCache.Invalidate()
+	}
+	return s.cfgCache.Get(r.Context())
+}
+
+// programSnapshot returns the shared Dump Program snapshot (?refresh=1 dumps again).
+func (s *Server) programSnapshot(r *http.Request) (*statehub.Snapshot, error) {
+	if r.URL.Query().Get("refresh") == "1" {
+		return s.programs.Refresh(r.Context())
+	}
+	return s.programs.Get(r.Context())
+}
+
+// freshProgram dumps the program now, for read-modify-write edits and for
+// reading back a change just made.
+func (s *Server) freshProgram(r *http.Request) (*stompbox.Program, error) {
+	snap, err := s.programs.Refresh(r.Context())
+	if err != nil {
+		return nil, err
+	}
+	return snap.Program, nil
+}
+
+// invalidateProgram marks the program snapshot stale after every request
+// that may have changed Stompbox state, so follow-up reads see the change.
+func (s *Server) invalidateProgram(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		defer func() {
+			if r.Method != http.MethodGet && r.Method != http.MethodHead {
+				s.programs.Invalidate()
+			}
+		}()
+		next.ServeHTTP(w, r)
+	})
 }
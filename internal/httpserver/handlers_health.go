@@ -0,0 +1,29 @@
+package httpserver
+
+import (
+	"net/http"
+)
+
+// GET /api/stompbox/health
+// Reports the state of the persistent Stompbox session (no command is sent).
+func (s *Server) handleStompboxHealth(w http.ResponseWriter, r *http.Request) {
+	writeJSON(w, http.StatusOK, s.sb.Health())
+}
+
+// GET /api/debug/scheduler
+// Queue depth and wait times per priority class.
+func (s *Server) handleSchedulerStats(w http.ResponseWriter, r *http.Request) {
+	writeJSON(w, http.StatusOK, s.sched.Stats())
+}
+
+// GET /api/debug/config-cache
+// Revision, age and hit counters of the cached Dump Config.
+func (s *Server) handleConfigCacheStats(w http.ResponseWriter, r *http.Request) {
+	writeJSON(w, http.StatusOK, s.cfgCache.Stats())
+}
+
+// GET /api/debug/state-hub
+// Revision, poll mode and counters of the shared Dump Program snapshot.
+func (s *Server) handleStateHubStats(w http.ResponseWriter, r *http.Request) {
+	writeJSON(w, http.StatusOK, s.programs.Stats())
+}
@@ -0,0 +1,276 @@
+package httpserver
+
+import (
+	"errors"
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/alscos/Namnesis/internal/baseline"
+	"github.com/alscos/Namnesis/internal/presethistory"
+	"github.com/alscos/Namnesis/internal/stompbox"
+	"github.com/go-chi/chi/v5"
+)
+
+// programScriptText turns a Dump Program response into the script stored
+// in the history (the /api/program/export form).
+func programScriptText(raw string) (string, error) {
+	script, err := stompbox.ParseProgramScript(raw)
+	if err != nil {
+		return "", err
+	}
+	return script.Serialize(), nil
+}
+
+// recordVersion stores the program of b as a version of preset. History is
+// a safety net: failures are logged, never returned to the client.
+func (s *Server) recordVersion(preset, action string, b *baseline.Baseline, from int) {
+	if b == nil {
+		log.Printf("history: %s %s: no program to record", action, preset)
+		return
+	}
+	script, err := programScriptText(b.Raw)
+	if err == nil {
+		_, _, err = s.history.Record(preset, action, script, from)
+	}
+	if err != nil {
+		log.Printf("history: %s %s: %v", action, preset, err)
+	}
+}
+
+// recordLoad stores the content of a preset the first time it is seen
+// loaded, so presets saved before the gateway ran can still be restored
+// after a delete or an overwrite. The program found at startup may carry
+// unsaved edits: it is kept as an observed version, not as stored content.
+func (s *Server) recordLoad(b *baseline.Baseline) {
+	switch b.Reason {
+	case baseline.ReasonLoad, baseline.ReasonPreset:
+		s.recordVersion(b.Preset, presethistory.ActionLoad, b, 0)
+	case baseline.ReasonStartup:
+		s.recordVersion(b.Preset, presethistory.ActionObserved, b, 0)
+	}
+}
+
+// storedScript is the last known stored content of preset, for the trash.
+// Observed versions count only when there is nothing else (observed reports
+// that): they may hold edits that were never saved.
+func (s *Server) storedScript(preset string) (script string, observed, ok bool) {
+	versions, err := s.history.List(preset)
+	if err != nil || len(versions) == 0 || versions[0].Action == presethistory.ActionDelete {
+		return "", false, false
+	}
+	pick := versions[0]
+	for _, v := range versions {
+		if v.Action == presethistory.ActionDelete {
+			break
+		}
+		if v.Action != presethistory.ActionObserved {
+			pick = v
+			break
+		}
+	}
+	_, script, err = s.history.Get(preset, pick.Seq)
+	if err != nil {
+		return "", false, false
+	}
+	return script, pick.Action == presethistory.ActionObserved, true
+}
+
+func historyParams(w http.ResponseWriter, r *http.Request) (string, bool) {
+	name := strings.TrimSpace(chi.URLParam(r, "name"))
+	if err := validatePresetName(name); err != nil {
+		http.Error(w, "invalid preset name: "+err.Error(), http.StatusBadRequest)
+		return "", false
+	}
+	return name, true
+}
+
+func parseSeq(w http.ResponseWriter, field, value string) (int, bool) {
+	seq, err := strconv.Atoi(value)
+	if err != nil || seq < 1 {
+		http.Error(w, "invalid "+field+": "+value, http.StatusBadRequest)
+		return 0, false
+	}
+	return seq, true
+}
+
+func writeHistoryError(w http.ResponseWriter, err error) {
+	if errors.Is(err, presethistory.ErrNotFound) {
+		http.Error(w, err.Error(), http.StatusNotFound)
+		return
+	}
+	http.Error(w, "history error: "+err.Error(), http.StatusInternalServerError)
+}
+
+// GET /api/presets/{name}/history
+func (s *Server) handleHistoryList(w http.ResponseWriter, r *http.Request) {
+	name, ok := historyParams(w, r)
+	if !ok {
+		return
+	}
+	versions, err := s.history.List(name)
+	if err != nil {
+		writeHistoryError(w, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, map[string]any{"preset": name, "versions": versions})
+}
+
+// GET /api/presets/{name}/history/{seq}
+func (s *Server) handleHistoryGet(w http.ResponseWriter, r *http.Request) {
+	name, ok := historyParams(w, r)
+	if !ok {
+		return
+	}
+	seq, ok := parseSeq(w, "version", chi.URLParam(r, "seq"))
+	if !ok {
+		return
+	}
+	v, script, err := s.history.Get(name, seq)
+	if err != nil {
+		writeHistoryError(w, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, map[string]any{"version": v, "script": script})
+}
+
+// GET /api/presets/{name}/history/diff?from=2&to=5
+// to defaults to the newest version.
+func (s *Server) handleHistoryDiff(w http.ResponseWriter, r *http.Request) {
+	name, ok := historyParams(w, r)
+	if !ok {
+		return
+	}
+	q := r.URL.Query()
+	fromSeq, ok := parseSeq(w, "from", q.Get("from"))
+	if !ok {
+		return
+	}
+
+	_, fromScript, err := s.history.Get(name, fromSeq)
+	if err != nil {
+		writeHistoryError(w, err)
+		return
+	}
+	var toScript string
+	if q.Get("to") == "" {
+		_, toScript, err = s.history.Latest(name)
+	} else {
+		toSeq, ok := parseSeq(w, "to", q.Get("to"))
+		if !ok {
+			return
+		}
+		_, toScript, err = s.history.Get(name, toSeq)
+	}
+	if err != nil {
+		writeHistoryError(w, err)
+		return
+	}
+
+	from, err := stompbox.ParseDumpProgram(fromScript)
+	if err != nil {
+		http.Error(w, "stored version unreadable: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+	to, err := stompbox.ParseDumpProgram(toScript)
+	if err != nil {
+		http.Error(w, "stored version unreadable: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+	d := stompbox.DiffPrograms(from, to, s.diffConfig(r))
+	writeJSON(w, http.StatusOK, diffResponse{Empty: d.Empty(), Diff: d, Summary: diffSummary(d)})
+}
+
+// POST /api/presets/{name}/history/{seq}/restore
+func (s *Server) handleHistoryRestore(w http.ResponseWriter, r *http.Request) {
+	name, ok := historyParams(w, r)
+	if !ok {
+		return
+	}
+	seq, ok := parseSeq(w, "version", chi.URLParam(r, "seq"))
+	if !ok {
+		return
+	}
+	v, script, err := s.history.Get(name, seq)
+	if err != nil {
+		writeHistoryError(w, err)
+		return
+	}
+	s.restoreVersion(w, r, v, script)
+}
+
+// GET /api/trash
+// Presets deleted through the gateway and not saved again since.
+func (s *Server) handleTrashList(w http.ResponseWriter, r *http.Request) {
+	trash, err := s.history.Trash()
+	if err != nil {
+		writeHistoryError(w, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, map[string]any{"presets": trash})
+}
+
+// POST /api/trash/{name}/restore
+func (s *Server) handleTrashRestore(w http.ResponseWriter, r *http.Request) {
+	name, ok := historyParams(w, r)
+	if !ok {
+		return
+	}
+	v, script, err := s.history.Latest(name)
+	if err != nil {
+		writeHistoryError(w, err)
+		return
+	}
+	if v.Action != presethistory.ActionDelete {
+		http.Error(w, "preset is not in the trash: "+name, http.StatusConflict)
+		return
+	}
+	s.restoreVersion(w, r, v, script)
+}
+
+// restoreVersion applies a stored script to the live program (only what
+// differs is sent) and saves it as v.Preset. This replaces the live
+// program, including unsaved edits of the active preset.
+func (s *Server) restoreVersion(w http.ResponseWriter, r *http.Request, v presethistory.Version, script string) {
+	target, err := stompbox.ParseDumpProgram(script)
+	if err != nil {
+		http.Error(w, "stored version unreadable: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	s.editMu.Lock()
+	defer s.editMu.Unlock()
+
+	cur, err := s.freshProgram(r)
+	if err != nil {
+		writeSBError(w, r, "program error", err)
+		return
+	}
+
+	// Once started, the plan and the save run to the end: cut off by the
+	// request timeout they would leave the preset half applied and unsaved.
+	ctx, cancel := detachedContext(r)
+	defer cancel()
+
+	res, err := s.applyPlan(ctx, baseline.PlanTo(cur, target))
+	if err != nil {
+		writeSBError(w, r, "restore error", err)
+		return
+	}
+	if err := s.sched.SavePresetCtx(ctx, v.Preset); err != nil {
+		writeSBError(w, r, "SavePreset failed", err)
+		return
+	}
+	b := s.captureBaseline(r.WithContext(ctx), baseline.ReasonSave)
+	s.recordVersion(v.Preset, presethistory.ActionRestore, b, v.Seq)
+
+	writeJSON(w, http.StatusOK, map[string]any{
+		"ok":       true,
+		"preset":   v.Preset,
+		"restored": v,
+		"applied":  res.Applied,
+		"released": res.Released,
+		"skipped":  res.Skipped,
+	})
+}
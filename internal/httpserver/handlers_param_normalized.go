@@ -0,0 +1,179 @@
+package httpserver
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/alscos/Namnesis/internal/stompbox"
+)
+
+type paramNormalizedRequest struct {
+	Plugin   string   `json:"plugin"`
+	Param    string   `json:"param"`
+	Position *float64 `json:"position"` // 0..1
+	// Clamp pulls a position outside 0..1 to the nearest end instead of
+	// rejecting it.
+	Clamp bool `json:"clamp"`
+}
+
+// paramPosition is a param value with its 0..1 control position.
+type paramPosition struct {
+	Plugin     string   `json:"plugin"`
+	Param      string   `json:"param"`
+	Type       string   `json:"type,omitempty"`
+	Value      string   `json:"value"`
+	Position   *float64 `json:"position,omitempty"` // nil: no range (File params) or not a number
+	Min        *float64 `json:"min,omitempty"`
+	Max        *float64 `json:"max,omitempty"`
+	RangePower *float64 `json:"rangePower,omitempty"`
+}
+
+// POST /api/param/set-normalized
+// Body: {"plugin":"HighLow_1","param":"High","position":0.5}
+// Maps the position to a value on the param's RangePower curve, as the
+// Stompbox dials do, and sets it like /api/param/set.
+func (s *Server) handleParamSetNormalized(w http.ResponseWriter, r *http.Request) {
+	var req paramNormalizedRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "invalid json body", http.StatusBadRequest)
+		return
+	}
+	req.Plugin = strings.TrimSpace(req.Plugin)
+	req.Param = strings.TrimSpace(req.Param)
+	if req.Plugin == "" || req.Param == "" || req.Position == nil {
+		http.Error(w, "missing fields: plugin, param and position are required", http.StatusBadRequest)
+		return
+	}
+	pos := *req.Position
+	if (pos < 0 || pos > 1) && !req.Clamp {
+		http.Error(w, fmt.Sprintf("position %s is out of range 0..1 (send \"clamp\":true to clamp)",
+			stompbox.FormatParamValue(pos)), http.StatusBadRequest)
+		return
+	}
+
+	def, err := s.paramDef(r, req.Plugin, req.Param)
+	if err != nil {
+		writeParamDefError(w, r, err)
+		return
+	}
+	if def.IsOutput != nil && *def.IsOutput {
+		http.Error(w, fmt.Sprintf("%s.%s is an output (read-only)", req.Plugin, req.Param), http.StatusBadRequest)
+		return
+	}
+	x, err := def.Denormalize(pos)
+	if err != nil {
+		http.Error(w, fmt.Sprintf("%s.%s: %v", req.Plugin, req.Param, err), http.StatusBadRequest)
+		return
+	}
+
+	// The value is in range already; clamp only absorbs rounding.
+	cv, ok := s.applyParamSet(w, r, paramSetRequest{Plugin: req.Plugin, Param: req.Param, Value: x, Clamp: true})
+	if !ok {
+		return
+	}
+	sent, _ := strconv.ParseFloat(cv.Value, 64)
+	actual, _ := def.Normalize(sent) // Int/Enum/Bool values are rounded
+	writeJSON(w, http.StatusOK, map[string]any{
+		"ok":       true,
+		"plugin":   req.Plugin,
+		"param":    req.Param,
+		"value":    cv.Value,
+		"position": actual,
+	})
+}
+
+// GET /api/param/normalized?plugin=HighLow_1[&param=High]
+// The current value and 0..1 position of one param, or of every param of
+// the plugin instance.
+func (s *Server) handleParamNormalized(w http.ResponseWriter, r *http.Request) {
+	plugin := strings.TrimSpace(r.URL.Query().Get("plugin"))
+	param := strings.TrimSpace(r.URL.Query().Get("param"))
+	if plugin == "" {
+		http.Error(w, "missing plugin", http.StatusBadRequest)
+		return
+	}
+
+	cfg, err := s.configSnapshot(r)
+	if err != nil {
+		writeSBError(w, r, "dumpconfig error", err)
+		return
+	}
+	snap, err := s.programSnapshot(r)
+	if err != nil {
+		writeSBError(w, r, "program error", err)
+		return
+	}
+	values, ok := snap.Program.Params[plugin]
+	if !ok {
+		http.Error(w, "plugin instance not in the program: "+plugin, http.StatusNotFound)
+		return
+	}
+
+	if param != "" {
+		v, ok := values[param]
+		if !ok {
+			http.Error(w, fmt.Sprintf("param not in the program: %s.%s", plugin, param), http.StatusNotFound)
+			return
+		}
+		writeJSON(w, http.StatusOK, positionOf(cfg.Config, plugin, param, v))
+		return
+	}
+
+	names := make([]string, 0, len(values))
+	for name := range values {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	out := make([]paramPosition, 0, len(names))
+	for _, name := range names {
+		out = append(out, positionOf(cfg.Config, plugin, name, values[name]))
+	}
+	writeJSON(w, http.StatusOK, map[string]any{"plugin": plugin, "params": out})
+}
+
+// positionOf describes value v of plugin.param; Position stays nil when the
+// param has no range or v isn't a number.
+func positionOf(cfg *stompbox.DumpConfigParsed, plugin, param, v string) paramPosition {
+	p := paramPosition{Plugin: plugin, Param: param, Value: v}
+	def, err := lookupParamDef(cfg, plugin, param)
+	if err != nil {
+		return p
+	}
+	p.Type, p.Min, p.Max, p.RangePower = def.Type, def.MinValue, def.MaxValue, def.RangePower
+	x, err := strconv.ParseFloat(strings.TrimSpace(v), 64)
+	if err != nil {
+		return p
+	}
+	if pos, err := def.Normalize(x); err == nil {
+		p.Position = &pos
+	}
+	return p
+}
+
+// paramDef looks plugin.param up in Dump Config, refreshing once on a miss.
+func (s *Server) paramDef(r *http.Request, plugin, param string) (*stompbox.ParamDef, error) {
+	snap, err := s.cfgCache.Get(r.Context())
+	if err != nil {
+		return nil, err
+	}
+	def, err := lookupParamDef(snap.Config, plugin, param)
+	if errors.Is(err, errUnknownParam) {
+		if snap, rerr := s.cfgCache.Refresh(r.Context()); rerr == nil {
+			def, err = lookupParamDef(snap.Config, plugin, param)
+		}
+	}
+	return def, err
+}
+
+func writeParamDefError(w http.ResponseWriter, r *http.Request, err error) {
+	if errors.Is(err, errUnknownParam) {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	writeSBError(w, r, "dumpconfig error", err)
+}
@@ -0,0 +1,235 @@
+package httpserver
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/alscos/Namnesis/internal/stompbox"
+)
+
+// nudgeTravel is the share of the control travel one nudge step moves.
+const nudgeTravel = 0.01
+
+type paramNudgeRequest struct {
+	Plugin string `json:"plugin"`
+	Param  string `json:"param"`
+	// Exactly one of Steps (encoder detents, 1% of the travel each) and
+	// Percent (of the travel); negative values turn down.
+	Steps   *float64 `json:"steps"`
+	Percent *float64 `json:"percent"`
+}
+
+// nudger keeps the last value nudged into each plugin.param, so quick
+// repeated nudges build on it instead of dumping the program again, and
+// folds nudges that arrive while one is being sent into the next SetParam.
+type nudger struct {
+	window time.Duration
+
+	mu     sync.Mutex
+	params map[string]*nudgeState
+}
+
+type nudgeState struct {
+	send sync.Mutex // held while the param is nudged
+
+	// Guarded by nudger.mu.
+	pending float64   // position delta not sent yet
+	pos     float64   // position after the last send
+	value   string    // token last sent
+	at      time.Time // when it was sent; zero = read the program
+}
+
+func newNudger(window time.Duration) *nudger {
+	return &nudger{window: window, params: map[string]*nudgeState{}}
+}
+
+// add queues delta for key and returns its state.
+func (n *nudger) add(key string, delta float64) *nudgeState {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	st, ok := n.params[key]
+	if !ok {
+		st = &nudgeState{}
+		n.params[key] = st
+	}
+	st.pending += delta
+	return st
+}
+
+// take returns and clears the queued delta, with the last sent position and
+// value if they are recent enough to build on (ok).
+func (n *nudger) take(st *nudgeState) (delta, pos float64, value string, ok bool) {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	delta, st.pending = st.pending, 0
+	ok = !st.at.IsZero() && time.Since(st.at) < n.window
+	return delta, st.pos, st.value, ok
+}
+
+// sent records the result of a nudge; a failed one (value "") makes the
+// next nudge read the program again.
+func (n *nudger) sent(st *nudgeState, pos float64, value string) {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	st.pos, st.value, st.at = pos, value, time.Now()
+	if value == "" {
+		st.at = time.Time{}
+	}
+}
+
+func (n *nudger) last(st *nudgeState) (pos float64, value string) {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	return st.pos, st.value
+}
+
+// POST /api/param/nudge
+// Body: {"plugin":"Delay_2","param":"Mix","steps":1} or {...,"percent":-5}
+// Moves the param relative to its current value along the same 0..1
+// travel as /api/param/set-normalized, rounded to the ValueFormat
+// precision and clamped to MinValue..MaxValue.
+func (s *Server) handleParamNudge(w http.ResponseWriter, r *http.Request) {
+	var req paramNudgeRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "invalid json body", http.StatusBadRequest)
+		return
+	}
+	req.Plugin = strings.TrimSpace(req.Plugin)
+	req.Param = strings.TrimSpace(req.Param)
+	if req.Plugin == "" || req.Param == "" {
+		http.Error(w, "missing fields: plugin and param are required", http.StatusBadRequest)
+		return
+	}
+	var delta float64
+	switch {
+	case req.Steps != nil && req.Percent != nil:
+		http.Error(w, "send either steps or percent, not both", http.StatusBadRequest)
+		return
+	case req.Steps != nil:
+		delta = *req.Steps * nudgeTravel
+	case req.Percent != nil:
+		delta = *req.Percent / 100
+	}
+	if delta == 0 || math.IsNaN(delta) || math.IsInf(delta, 0) {
+		http.Error(w, "missing fields: steps or percent must be a non-zero number", http.StatusBadRequest)
+		return
+	}
+
+	def, err := s.paramDef(r, req.Plugin, req.Param)
+	if err != nil {
+		writeParamDefError(w, r, err)
+		return
+	}
+	if def.IsOutput != nil && *def.IsOutput {
+		http.Error(w, fmt.Sprintf("%s.%s is an output (read-only)", req.Plugin, req.Param), http.StatusBadRequest)
+		return
+	}
+	if _, err := def.Denormalize(0); err != nil {
+		http.Error(w, fmt.Sprintf("%s.%s: %v", req.Plugin, req.Param, err), http.StatusBadRequest)
+		return
+	}
+
+	st := s.nudges.add(req.Plugin+"."+req.Param, delta)
+	st.send.Lock()
+	defer st.send.Unlock()
+
+	delta, pos, prev, recent := s.nudges.take(st)
+	if delta == 0 {
+		// An earlier request sent our delta along with its own.
+		pos, value := s.nudges.last(st)
+		if value == "" {
+			http.Error(w, "nudge failed: the request it was merged into failed", http.StatusBadGateway)
+			return
+		}
+		writeJSON(w, http.StatusOK, map[string]any{
+			"ok": true, "plugin": req.Plugin, "param": req.Param,
+			"value": value, "position": pos, "coalesced": true,
+		})
+		return
+	}
+	if !recent {
+		snap, err := s.programs.Get(r.Context())
+		if err != nil {
+			s.nudges.sent(st, 0, "")
+			writeSBError(w, r, "program error", err)
+			return
+		}
+		v, ok := snap.Program.Params[req.Plugin][req.Param]
+		if !ok {
+			s.nudges.sent(st, 0, "")
+			http.Error(w, fmt.Sprintf("param not in the program: %s.%s", req.Plugin, req.Param), http.StatusNotFound)
+			return
+		}
+		prev = strings.TrimSpace(v)
+	}
+	cur, err := strconv.ParseFloat(prev, 64)
+	if err != nil {
+		s.nudges.sent(st, 0, "")
+		http.Error(w, fmt.Sprintf("%s.%s: current value %q is not a number", req.Plugin, req.Param, prev), http.StatusConflict)
+		return
+	}
+	if !recent {
+		pos, _ = def.Normalize(cur)
+	}
+
+	next, nextPos := nudgeValue(def, cur, pos, delta)
+	if next == cur {
+		// Already at the end of the range.
+		s.nudges.sent(st, nextPos, prev)
+		writeJSON(w, http.StatusOK, map[string]any{
+			"ok": true, "plugin": req.Plugin, "param": req.Param,
+			"previous": prev, "value": prev, "position": nextPos, "limit": true,
+		})
+		return
+	}
+
+	cv, ok := s.applyParamSet(w, r, paramSetRequest{Plugin: req.Plugin, Param: req.Param, Value: next, Clamp: true})
+	if !ok {
+		s.nudges.sent(st, 0, "")
+		return
+	}
+	s.nudges.sent(st, nextPos, cv.Value)
+	writeJSON(w, http.StatusOK, map[string]any{
+		"ok": true, "plugin": req.Plugin, "param": req.Param,
+		"previous": prev, "value": cv.Value, "position": nextPos,
+		"limit": nextPos == 0 || nextPos == 1,
+	})
+}
+
+// nudgeValue moves value cur, at control position pos, by delta of the
+// travel. The result is rounded to the param's resolution and moves at
+// least one resolution step, so small nudges of coarse params aren't lost.
+func nudgeValue(def *stompbox.ParamDef, cur, pos, delta float64) (float64, float64) {
+	pos = math.Min(math.Max(pos+delta, 0), 1)
+	next, err := def.Denormalize(pos)
+	if err != nil {
+		return cur, pos
+	}
+	res := def.Resolution()
+	if res <= 0 {
+		return next, pos
+	}
+	next = roundTo(next, res)
+	if next == cur {
+		next = roundTo(cur+math.Copysign(res, delta), res)
+		lo, hi := *def.MinValue, *def.MaxValue
+		next = math.Min(math.Max(next, lo), hi)
+		pos, _ = def.Normalize(next)
+	}
+	return next, pos
+}
+
+// roundTo rounds x to a multiple of res, which is 1 or a power of ten.
+func roundTo(x, res float64) float64 {
+	if res >= 1 {
+		return math.Round(x/res) * res
+	}
+	scale := math.Round(1 / res)
+	return math.Round(x*scale) / scale
+}
@@ -2,18 +2,23 @@ package httpserver
 
 import (
 	"encoding/json"
+	"errors"
 	"fmt"
 	"math"
 	"net/http"
 	"strconv"
 	"strings"
-	"unicode"
+
+	"github.com/alscos/Namnesis/internal/stompbox"
 )
 
 type paramSetRequest struct {
 	Plugin string      `json:"plugin"`
 	Param  string      `json:"param"`
 	Value  interface{} `json:"value"`
+	// Clamp pulls a value outside MinValue..MaxValue to the nearest bound
+	// instead of rejecting it.
+	Clamp bool `json:"clamp"`
 }
 
 func (s *Server) handleParamSet(w http.ResponseWriter, r *http.Request) {
@@ -33,81 +38,202 @@ func (s *Server) handleParamSet(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	val, err := toStompboxValue(req.Value)
-	if err != nil {
-		http.Error(w, "invalid value: "+err.Error(), http.StatusBadRequest)
+	cv, ok := s.applyParamSet(w, r, req)
+	if !ok {
 		return
 	}
 
+	// Return JSON ack
+	out := map[string]any{
+		"ok":      true,
+		"plugin":  req.Plugin,
+		"param":   req.Param,
+		"value":   cv.Value, // final token sent to Stompbox
+		"clamped": cv.Clamped,
+	}
+	if cv.Unchecked {
+		out["unchecked"] = true
+	}
+	w.Header().Set("Content-Type", "application/json; charset=utf-8")
+	_ = json.NewEncoder(w).Encode(out)
+}
+
+// applyParamSet validates req, sends it and records the undo step. On
+// failure it writes the error response and returns false.
+func (s *Server) applyParamSet(w http.ResponseWriter, r *http.Request, req paramSetRequest) (checkedValue, bool) {
+	// Validate against the cached DumpConfig; a miss may mean Stompbox
+	// restarted with other plugins: refresh once and retry. Without Dump
+	// Config the value is sent unchecked and Stompbox has the last word.
+	var cv checkedValue
+	snap, err := s.cfgCache.Get(r.Context())
+	if err == nil {
+		cv, err = checkParamValue(snap.Config, req.Plugin, req.Param, req.Value, req.Clamp)
+		if errors.Is(err, errUnknownParam) {
+			if snap, rerr := s.cfgCache.Refresh(r.Context()); rerr == nil {
+				cv, err = checkParamValue(snap.Config, req.Plugin, req.Param, req.Value, req.Clamp)
+			} else {
+				cv, err = uncheckedParamValue(req.Value)
+			}
+		}
+	} else {
+		cv, err = uncheckedParamValue(req.Value)
+	}
+	if err != nil {
+		var re *stompbox.RangeError
+		switch {
+		case errors.As(err, &re):
+			http.Error(w, err.Error()+` (send "clamp":true to clamp)`, http.StatusBadRequest)
+		case errors.Is(err, errUnknownParam):
+			http.Error(w, err.Error(), http.StatusBadRequest)
+		default:
+			http.Error(w, "invalid value: "+err.Error(), http.StatusBadRequest)
+		}
+		return checkedValue{}, false
+	}
+
+	// Knob drags send many sets: they coalesce into one undo step.
+	sess := editSession(w, r)
+	step := s.paramUndoStep(r, sess, req.Plugin+"."+req.Param, req.Plugin, req.Param)
+
 	// Apply
-	if err := s.sb.SetParam(req.Plugin, req.Param, val); err != nil {
-		http.Error(w, "setparam error: "+err.Error(), http.StatusBadGateway)
-		return
+	if err := s.sched.SetParamCtx(r.Context(), req.Plugin, req.Param, cv.Value); err != nil {
+		writeSBError(w, r, "setparam error", err)
+		return checkedValue{}, false
 	}
+	s.recordEdit(sess, step, paramPlan(req.Plugin, req.Param, cv.Value))
+	return cv, true
+}
 
-	// Return JSON ack
-	w.Header().Set("Content-Type", "application/json; charset=utf-8")
-	_ = json.NewEncoder(w).Encode(map[string]any{
-		"ok":     true,
-		"plugin": req.Plugin,
-		"param":  req.Param,
-		"value":  val, // final token sent to Stompbox
-	})
+// errUnknownParam marks lookups that a Dump Config refresh might fix.
+var errUnknownParam = errors.New("unknown")
+
+// lookupParamDef finds the ParamDef of plugin.param, including the implied
+// Enabled switch.
+func lookupParamDef(cfg *stompbox.DumpConfigParsed, plugin, param string) (*stompbox.ParamDef, error) {
+	if plugin == "" || param == "" {
+		return nil, fmt.Errorf("plugin and param are required")
+	}
+	if _, ok := cfg.Resolve(plugin); !ok {
+		return nil, fmt.Errorf("%w plugin %s", errUnknownParam, plugin)
+	}
+	if param == "Enabled" {
+		return stompbox.EnabledParam, nil
+	}
+	def, ok := cfg.ResolveParam(plugin, param)
+	if !ok {
+		return nil, fmt.Errorf("%w param %s.%s", errUnknownParam, plugin, param)
+	}
+	return def, nil
 }
 
-// JSON numbers come as float64; we convert to a Stompbox token.
-// - bool -> 0/1
-// - number -> decimal (no scientific notation)
-// - string -> quoted if it contains whitespace (Stompbox supports std::quoted)
-func toStompboxValue(v interface{}) (string, error) {
-	switch t := v.(type) {
-	case bool:
-		if t {
-			return "1", nil
+// checkedValue is a param value ready for SetParam.
+type checkedValue struct {
+	Value     string // token sent to Stompbox
+	Clamped   bool   // pulled into MinValue..MaxValue
+	Unchecked bool   // sent without a ParamDef to check it against
+}
+
+// checkParamValue checks plugin.param against Dump Config and converts v
+// (number, bool or string) to the token to send. The param must exist and
+// not be an output; File params take a file from the tree; Bool params are
+// coerced to 0/1, Int/Enum params rounded and Enum value names mapped
+// through EnumValues; a value outside
+// MinValue..MaxValue is a *stompbox.RangeError unless clamp is set.
+func checkParamValue(cfg *stompbox.DumpConfigParsed, plugin, param string, v any, clamp bool) (checkedValue, error) {
+	def, err := lookupParamDef(cfg, plugin, param)
+	if err != nil {
+		return checkedValue{}, err
+	}
+	if def.IsOutput != nil && *def.IsOutput {
+		return checkedValue{}, fmt.Errorf("%s.%s is an output (read-only)", plugin, param)
+	}
+
+	if def.Type == stompbox.ParamFile {
+		str, ok := v.(string)
+		if !ok {
+			return checkedValue{}, fmt.Errorf("%s.%s is a File param: value must be a string", plugin, param)
 		}
-		return "0", nil
+		return checkedValue{Value: str}, validateFileParam(cfg, plugin, param, str)
+	}
 
-	case json.Number:
-		// Prefer integer if it parses cleanly
-		if i64, err := t.Int64(); err == nil {
-			return strconv.FormatInt(i64, 10), nil
+	if str, ok := v.(string); ok && def.Type == stompbox.ParamEnum {
+		if x, ok := def.EnumValue(str); ok {
+			v = x
 		}
-		f64, err := t.Float64()
-		if err != nil {
-			return "", fmt.Errorf("invalid number")
+	}
+	x, err := paramNumber(v, def.IsBool())
+	if err != nil {
+		if len(def.EnumValues) > 0 {
+			err = fmt.Errorf("%w or one of %s", err, strings.Join(def.EnumValues, ", "))
 		}
-		if math.IsNaN(f64) || math.IsInf(f64, 0) {
-			return "", fmt.Errorf("invalid number (nan/inf)")
+		return checkedValue{}, fmt.Errorf("%s.%s is a %s param: %w", plugin, param, def.Type, err)
+	}
+	x, clamped, err := def.CheckValue(x, clamp)
+	if err != nil {
+		var re *stompbox.RangeError
+		if errors.As(err, &re) {
+			re.Param = plugin + "." + param
 		}
-		return strconv.FormatFloat(f64, 'f', -1, 64), nil
+		return checkedValue{}, err
+	}
+	return checkedValue{Value: stompbox.FormatParamValue(x), Clamped: clamped}, nil
+}
 
-	case float64:
-		// Fallback (in case some caller bypasses UseNumber)
-		if math.IsNaN(t) || math.IsInf(t, 0) {
-			return "", fmt.Errorf("invalid number (nan/inf)")
+// uncheckedParamValue converts v to a SetParam token without a ParamDef:
+// bools become 0/1, numbers and numeric strings plain decimals, and other
+// strings are sent as given.
+func uncheckedParamValue(v any) (checkedValue, error) {
+	if str, ok := v.(string); ok {
+		str = strings.TrimSpace(str)
+		if str == "" {
+			return checkedValue{}, fmt.Errorf("empty string")
+		}
+		if _, err := paramNumber(str, false); err != nil {
+			return checkedValue{Value: str, Unchecked: true}, nil
 		}
-		return strconv.FormatFloat(t, 'f', -1, 64), nil
+	}
+	x, err := paramNumber(v, false)
+	if err != nil {
+		return checkedValue{}, err
+	}
+	if math.IsNaN(x) || math.IsInf(x, 0) {
+		return checkedValue{}, fmt.Errorf("invalid number (nan/inf)")
+	}
+	return checkedValue{Value: stompbox.FormatParamValue(x), Unchecked: true}, nil
+}
 
-	case string:
-		s := strings.TrimSpace(t)
-		if s == "" {
-			return "", fmt.Errorf("empty string")
+// paramNumber reads a JSON value as a number: numbers, numeric strings
+// (comma decimals accepted) and bools; for Bool params also on/off,
+// true/false and yes/no.
+func paramNumber(v any, isBool bool) (float64, error) {
+	switch t := v.(type) {
+	case bool:
+		if t {
+			return 1, nil
 		}
-		// Allow numeric strings (optional: accept comma decimal)
-		ns := strings.ReplaceAll(s, ",", ".")
-		if f, err := strconv.ParseFloat(ns, 64); err == nil {
-			if math.IsNaN(f) || math.IsInf(f, 0) {
-				return "", fmt.Errorf("invalid number (nan/inf)")
+		return 0, nil
+	case json.Number:
+		return t.Float64()
+	case float64:
+		return t, nil
+	case string:
+		s := strings.ToLower(strings.TrimSpace(t))
+		if isBool {
+			switch s {
+			case "true", "on", "yes":
+				return 1, nil
+			case "false", "off", "no":
+				return 0, nil
 			}
-			return strconv.FormatFloat(f, 'f', -1, 64), nil
 		}
-		// Quote if it has spaces/tabs/etc. (safe for enum stems / IR names)
-		if strings.IndexFunc(s, unicode.IsSpace) >= 0 {
-			return strconv.Quote(s), nil
+		f, err := strconv.ParseFloat(strings.ReplaceAll(s, ",", "."), 64)
+		if err != nil {
+			return 0, fmt.Errorf("value must be a number, got %q", t)
 		}
-		return s, nil
-
+		return f, nil
+	case nil:
+		return 0, fmt.Errorf("missing value")
 	default:
-		return "", fmt.Errorf("unsupported type %T (use number, bool or string)", v)
+		return 0, fmt.Errorf("unsupported type %T (use number, bool or string)", v)
 	}
 }
@@ -0,0 +1,202 @@
+package httpserver
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/alscos/Namnesis/internal/baseline"
+	"github.com/alscos/Namnesis/internal/undo"
+)
+
+// maxBatchEntries caps one POST /api/params/batch.
+const maxBatchEntries = 256
+
+// Entry statuses in a batch response.
+const (
+	batchInvalid    = "invalid"     // failed validation; nothing was sent
+	batchApplied    = "applied"     // sent and accepted
+	batchFailed     = "failed"      // Stompbox or the session failed on it
+	batchNotApplied = "not_applied" // after a failed entry
+	batchRolledBack = "rolled_back" // applied, then set back to Previous
+)
+
+type paramsBatchRequest struct {
+	Params []paramSetRequest `json:"params"`
+	// Clamp pulls out-of-range values to MinValue/MaxValue instead of
+	// rejecting the batch; an entry's own clamp does it for that entry.
+	Clamp bool `json:"clamp"`
+	// Rollback sets already applied entries back to their previous values
+	// when one fails.
+	Rollback bool `json:"rollback"`
+}
+
+type batchResult struct {
+	Index    int     `json:"index"`
+	Plugin   string  `json:"plugin"`
+	Param    string  `json:"param"`
+	Value    string  `json:"value,omitempty"`
+	Clamped  bool    `json:"clamped,omitempty"`
+	Previous *string `json:"previous,omitempty"`
+	Status   string  `json:"status"`
+	Error    string  `json:"error,omitempty"`
+	// RollbackError says why an applied entry could not be set back.
+	RollbackError string `json:"rollbackError,omitempty"`
+}
+
+type paramsBatchResponse struct {
+	OK         bool          `json:"ok"`
+	Applied    int           `json:"applied"`
+	RolledBack bool          `json:"rolledBack,omitempty"`
+	Results    []batchResult `json:"results"`
+}
+
+// POST /api/params/batch
+// Body: {"params":[{"plugin":"Delay_2","param":"Mix","value":0.4},...],"rollback":true}
+// Validates every entry against Dump Config and the live program, then
+// applies them in order. The whole batch is one undo step.
+func (s *Server) handleParamsBatch(w http.ResponseWriter, r *http.Request) {
+	var req paramsBatchRequest
+	dec := json.NewDecoder(r.Body)
+	dec.UseNumber()
+	if err := dec.Decode(&req); err != nil {
+		http.Error(w, "invalid json body", http.StatusBadRequest)
+		return
+	}
+	if len(req.Params) == 0 {
+		http.Error(w, "missing params", http.StatusBadRequest)
+		return
+	}
+	if len(req.Params) > maxBatchEntries {
+		http.Error(w, fmt.Sprintf("too many params: %d (max %d)", len(req.Params), maxBatchEntries), http.StatusBadRequest)
+		return
+	}
+
+	snap, err := s.configSnapshot(r)
+	if err != nil {
+		writeSBError(w, r, "dumpconfig error", err)
+		return
+	}
+
+	s.editMu.Lock()
+	defer s.editMu.Unlock()
+
+	// Previous values come from one dump taken before anything is sent.
+	before, err := s.freshProgram(r)
+	if err != nil {
+		writeSBError(w, r, "program error", err)
+		return
+	}
+
+	// 1) Validate everything first: a bad entry rejects the whole batch.
+	resp := paramsBatchResponse{Results: make([]batchResult, len(req.Params))}
+	valid := true
+	for i, p := range req.Params {
+		res := batchResult{Index: i, Plugin: strings.TrimSpace(p.Plugin), Param: strings.TrimSpace(p.Param)}
+		var cv checkedValue
+		cv, err = checkParamValue(snap.Config, res.Plugin, res.Param, p.Value, p.Clamp || req.Clamp)
+		res.Value, res.Clamped = cv.Value, cv.Clamped
+		if err == nil {
+			if params, ok := before.Params[res.Plugin]; !ok {
+				err = fmt.Errorf("plugin instance not in the program: %s", res.Plugin)
+			} else if prev, ok := params[res.Param]; ok {
+				res.Previous = &prev
+			}
+		}
+		if err != nil {
+			res.Status, res.Error = batchInvalid, err.Error()
+			valid = false
+		}
+		resp.Results[i] = res
+	}
+	if !valid {
+		for i := range resp.Results {
+			if resp.Results[i].Status == "" {
+				resp.Results[i].Status = batchNotApplied
+			}
+		}
+		writeJSON(w, http.StatusBadRequest, resp)
+		return
+	}
+
+	// 2) Apply in order; stop at the first failure. The batch runs detached
+	// from the request, so a client going away mid-batch does not leave it
+	// half applied and only device errors trigger rollback.
+	ctx, cancel := detachedContext(r)
+	defer cancel()
+	var applyErr error
+	for i := range resp.Results {
+		res := &resp.Results[i]
+		if applyErr != nil {
+			res.Status = batchNotApplied
+			continue
+		}
+		if err := s.sched.SetParamCtx(ctx, res.Plugin, res.Param, res.Value); err != nil {
+			applyErr = err
+			res.Status, res.Error = batchFailed, err.Error()
+			continue
+		}
+		res.Status = batchApplied
+		resp.Applied++
+	}
+
+	// 3) Roll back in reverse order to the values captured in step 1, with
+	// its own deadline in case the apply used up the first one.
+	if applyErr != nil && req.Rollback {
+		resp.RolledBack = true
+		rbCtx, rbCancel := detachedContext(r)
+		defer rbCancel()
+		for i := len(resp.Results) - 1; i >= 0; i-- {
+			res := &resp.Results[i]
+			if res.Status != batchApplied {
+				continue
+			}
+			if res.Previous == nil {
+				res.RollbackError = "previous value unknown"
+				continue
+			}
+			if err := s.sched.SetParamCtx(rbCtx, res.Plugin, res.Param, *res.Previous); err != nil {
+				res.RollbackError = err.Error()
+				continue
+			}
+			res.Status = batchRolledBack
+			resp.Applied--
+		}
+	}
+
+	s.recordBatch(editSession(w, r), resp.Results)
+
+	if applyErr != nil {
+		status, code := sbErrorStatus(applyErr)
+		w.Header().Set("X-Error-Code", code)
+		writeJSON(w, status, resp)
+		return
+	}
+	resp.OK = true
+	writeJSON(w, http.StatusOK, resp)
+}
+
+// recordBatch pushes the applied entries as one undo step.
+func (s *Server) recordBatch(sess string, results []batchResult) {
+	undoPlan, redoPlan := &baseline.Plan{}, &baseline.Plan{}
+	for i := len(results) - 1; i >= 0; i-- {
+		res := results[i]
+		if res.Status != batchApplied {
+			continue
+		}
+		if res.Previous == nil {
+			return // not undoable as a whole
+		}
+		undoPlan.Params = append(undoPlan.Params, baseline.ParamSet{Plugin: res.Plugin, Param: res.Param, Value: *res.Previous})
+	}
+	for _, res := range results {
+		if res.Status == batchApplied {
+			redoPlan.Params = append(redoPlan.Params, baseline.ParamSet{Plugin: res.Plugin, Param: res.Param, Value: res.Value})
+		}
+	}
+	if len(redoPlan.Params) == 0 {
+		return
+	}
+	s.undo.Record(sess, &undo.Step{Label: fmt.Sprintf("batch of %d params", len(redoPlan.Params)), Undo: undoPlan, Redo: redoPlan})
+}
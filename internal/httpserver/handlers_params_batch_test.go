@@ -0,0 +1,52 @@
+package httpserver
+
+import (
+	"net/http"
+	"testing"
+
+	"github.com/alscos/Namnesis/internal/stompboxtest"
+)
+
+func TestBatchRollsBackOnFailure(t *testing.T) {
+	g := newTestGateway(t)
+	g.sb.SetFaults(stompboxtest.Faults{Errors: map[string]string{"SetParam Boost_2": "injected"}})
+
+	rec := g.do("POST", "/api/params/batch", `{"rollback":true,"params":[
+		{"plugin":"NoiseGate_2","param":"Thresh","value":-50},
+		{"plugin":"Compressor_2","param":"Ratio","value":4},
+		{"plugin":"Boost_2","param":"Gain","value":15},
+		{"plugin":"NoiseGate_2","param":"Attack","value":20}]}`)
+	if rec.Code != http.StatusUnprocessableEntity || rec.Header().Get("X-Error-Code") != codeStompboxRejected {
+		t.Fatalf("batch = %d %s: %s", rec.Code, rec.Header().Get("X-Error-Code"), rec.Body)
+	}
+	var resp paramsBatchResponse
+	decodeBody(t, rec, &resp)
+
+	want := []string{batchRolledBack, batchRolledBack, batchFailed, batchNotApplied}
+	for i, res := range resp.Results {
+		if res.Status != want[i] || res.RollbackError != "" {
+			t.Errorf("result %d = %+v; want %s", i, res, want[i])
+		}
+	}
+	if resp.OK || !resp.RolledBack || resp.Applied != 0 {
+		t.Errorf("batch response = %+v", resp)
+	}
+
+	g.sb.SetFaults(stompboxtest.Faults{})
+	prog := g.program()
+	for _, p := range []struct{ plugin, param, want string }{
+		{"NoiseGate_2", "Thresh", "-70.000000"},
+		{"Compressor_2", "Ratio", "2.000000"},
+		{"Boost_2", "Gain", "10.000000"},
+		{"NoiseGate_2", "Attack", "10.000000"},
+	} {
+		if got := prog.Params[p.plugin][p.param]; got != p.want {
+			t.Errorf("%s.%s = %s after rollback; want %s", p.plugin, p.param, got, p.want)
+		}
+	}
+
+	// Nothing stayed applied, so there is nothing to undo.
+	if rec := g.do("POST", "/api/undo", ""); rec.Code != http.StatusConflict {
+		t.Errorf("undo after a rolled back batch = %d", rec.Code)
+	}
+}
@@ -2,12 +2,11 @@ package httpserver
 
 import (
 	"encoding/json"
+	"fmt"
 	"net/http"
-	"regexp"
-
-	"github.com/go-chi/chi/v5"
 
 	"github.com/alscos/Namnesis/internal/stompbox"
+	"github.com/go-chi/chi/v5"
 )
 
 type pluginEnabledReq struct {
@@ -37,11 +36,15 @@ func (s *Server) handlePluginEnabled(w http.ResponseWriter, r *http.Request) {
 		val = "1"
 	}
 
+	sess := editSession(w, r)
+	step := s.paramUndoStep(r, sess, "", plugin, "Enabled")
+
 	// Use your existing Stompbox client abstraction (same style as handleSetFileParam)
-	if err := s.sb.SetParam(plugin, "Enabled", val); err != nil {
-		http.Error(w, "setparam error: "+err.Error(), http.StatusBadGateway)
+	if err := s.sched.SetParamCtx(r.Context(), plugin, "Enabled", val); err != nil {
+		writeSBError(w, r, "setparam error", err)
 		return
 	}
+	s.recordEdit(sess, step, paramPlan(plugin, "Enabled", val))
 
 	// Return JSON in the same style as your other handlers
 	w.Header().Set("Content-Type", "application/json; charset=utf-8")
@@ -63,55 +66,33 @@ func (s *Server) handleSetFileParam(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	// 1) Validate against DumpConfig-parsed (authoritative config metadata)
-	raw, err := s.sb.DumpConfig()
-	if err != nil {
-		http.Error(w, "dumpconfig error: "+err.Error(), http.StatusBadGateway)
-		return
-	}
-	cfg, err := stompbox.ParseDumpConfig(raw)
-	if err != nil {
-		http.Error(w, "parse dumpconfig error: "+err.Error(), http.StatusInternalServerError)
-		return
-	}
-
-	// IMPORTANT: DumpConfig is keyed by *base plugin type* (e.g. "ConvoReverb"),
-	// while runtime params can reference instances (e.g. "ConvoReverb_2").
+	// 1) Validate against the cached DumpConfig (authoritative config metadata).
+	// A miss may mean the file was just added on Stompbox: refresh once and retry.
 	pluginInstance := req.Plugin
-	base := regexp.MustCompile(`_\d+$`).ReplaceAllString(pluginInstance, "")
-
-	p, ok := cfg.Plugins[base]
-	if !ok {
-		http.Error(w, "unknown plugin: "+pluginInstance, http.StatusBadRequest)
-		return
-	}
-
-	paramDef, ok := p.Params[req.Param]
-	if !ok {
-		http.Error(w, "unknown param for plugin: "+pluginInstance+"."+req.Param, http.StatusBadRequest)
-		return
-	}
-	if paramDef.Type != "File" {
-		http.Error(w, "param is not a File type: "+pluginInstance+"."+req.Param, http.StatusBadRequest)
+	snap, err := s.cfgCache.Get(r.Context())
+	if err != nil {
+		writeSBError(w, r, "dumpconfig error", err)
 		return
 	}
-
-	// If we have a file tree for this param, ensure the value is valid.
-	// If ParseDumpConfig didn't build a tree for this param, we allow setting anyway.
-	if p.FileTrees != nil {
-		if ft, ok := p.FileTrees[req.Param]; ok && ft != nil {
-			if !fileTreeContains(ft, req.Value) {
-				http.Error(w, "value not present in file tree: "+req.Value, http.StatusBadRequest)
-				return
-			}
+	if err := validateFileParam(snap.Config, pluginInstance, req.Param, req.Value); err != nil {
+		if snap, rerr := s.cfgCache.Refresh(r.Context()); rerr == nil {
+			err = validateFileParam(snap.Config, pluginInstance, req.Param, req.Value)
+		}
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
 		}
 	}
 
+	sess := editSession(w, r)
+	step := s.paramUndoStep(r, sess, "", pluginInstance, req.Param)
+
 	// 2) Apply to running Stompbox (apply to the *instance*, not the base type)
-	if err := s.sb.SetParam(pluginInstance, req.Param, req.Value); err != nil {
-		http.Error(w, "setparam error: "+err.Error(), http.StatusBadGateway)
+	if err := s.sched.SetParamCtx(r.Context(), pluginInstance, req.Param, req.Value); err != nil {
+		writeSBError(w, r, "setparam error", err)
 		return
 	}
+	s.recordEdit(sess, step, paramPlan(pluginInstance, req.Param, req.Value))
 
 	// 3) Return OK
 	w.Header().Set("Content-Type", "application/json; charset=utf-8")
@@ -122,3 +103,32 @@ func (s *Server) handleSetFileParam(w http.ResponseWriter, r *http.Request) {
 		"value":  req.Value,
 	})
 }
+
+// validateFileParam checks that instance.param is a File param and, when its
+// file tree is known in full, that value is one of its files.
+func validateFileParam(cfg *stompbox.DumpConfigParsed, instance, param, value string) error {
+	// DumpConfig is keyed by plugin type (e.g. "ConvoReverb"), while runtime
+	// params reference instances (e.g. "ConvoReverb_2").
+	p, ok := cfg.Resolve(instance)
+	if !ok {
+		return fmt.Errorf("unknown plugin: %s", instance)
+	}
+
+	paramDef, ok := p.Params[param]
+	if !ok {
+		return fmt.Errorf("unknown param for plugin: %s.%s", instance, param)
+	}
+	if paramDef.Type != "File" {
+		return fmt.Errorf("param is not a File type: %s.%s", instance, param)
+	}
+
+	// If we have a file tree for this param, ensure the value is valid.
+	// If ParseDumpConfig didn't build a tree for this param (or only part of it,
+	// past the section size limit), we allow setting anyway.
+	if ft, ok := p.FileTrees[param]; ok && ft != nil && !ft.Truncated {
+		if !fileTreeContains(ft, value) {
+			return fmt.Errorf("value not present in file tree: %s", value)
+		}
+	}
+	return nil
+}
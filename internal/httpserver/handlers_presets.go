@@ -2,16 +2,28 @@ package httpserver
 
 import (
 	"encoding/json"
+	"log"
 	"net/http"
 	"strings"
 
+	"github.com/alscos/Namnesis/internal/baseline"
+	"github.com/alscos/Namnesis/internal/presethistory"
 	"github.com/alscos/Namnesis/internal/stompbox"
 )
 
 type presetCurrentResponse struct {
 	CurrentPreset string `json:"currentPreset"`
 	Error         string `json:"error,omitempty"`
+	Code          string `json:"code,omitempty"`
 }
+type presetsV2Response struct {
+	Presets []stompbox.Preset `json:"presets"`
+	Active  string            `json:"active"`
+	// Set when the active preset could not be read; Presets is still valid.
+	ActiveError string `json:"activeError,omitempty"`
+	ActiveCode  string `json:"activeCode,omitempty"`
+}
+
 type presetLoadRequest struct {
 	Name string `json:"name"`
 }
@@ -21,28 +33,67 @@ type savePresetRequest struct {
 type presetNameRequest struct {
 	Name string `json:"name"`
 }
+type presetDeleteRequest struct {
+	Name string `json:"name"`
+	// Force deletes a preset the gateway has no copy of (it can't be
+	// restored from the trash).
+	Force bool `json:"force"`
+}
 type loadPresetRequest struct {
 	Name string `json:"name"`
 }
 
 func (s *Server) handlePresetsRaw(w http.ResponseWriter, r *http.Request) {
-	out, err := s.sb.ListPresets()
+	out, err := s.sched.ListPresetsCtx(r.Context())
 	if err != nil {
-		http.Error(w, "presets error: "+err.Error(), http.StatusBadGateway)
+		writeSBError(w, r, "presets error", err)
 		return
 	}
 	w.Header().Set("Content-Type", "text/plain; charset=utf-8")
 	_, _ = w.Write([]byte(out))
 }
+
+// GET /api/v2/presets
+// Parsed preset list with numeric prefix, display name and the active flag.
+func (s *Server) handlePresetsV2(w http.ResponseWriter, r *http.Request) {
+	out, err := s.sched.ListPresetsCtx(r.Context())
+	if err != nil {
+		writeSBError(w, r, "presets error", err)
+		return
+	}
+	presets, err := stompbox.ParsePresetList(out)
+	if err != nil {
+		writeSBError(w, r, "presets parse error", err)
+		return
+	}
+
+	resp := presetsV2Response{Presets: presets}
+	if snap, err := s.programSnapshot(r); err != nil {
+		resp.ActiveError = err.Error()
+		_, resp.ActiveCode = sbErrorStatus(err)
+	} else {
+		prog := snap.Program
+		resp.Active = prog.ActivePreset
+		for i := range resp.Presets {
+			resp.Presets[i].Active = resp.Presets[i].Name == prog.ActivePreset
+		}
+	}
+	if resp.Presets == nil {
+		resp.Presets = []stompbox.Preset{}
+	}
+	writeJSON(w, http.StatusOK, resp)
+}
+
 func (s *Server) handlePresetCurrent(w http.ResponseWriter, r *http.Request) {
-	out, err := s.sb.DumpProgram()
+	snap, err := s.programSnapshot(r)
 	if err != nil {
-		writeJSON(w, http.StatusOK, presetCurrentResponse{CurrentPreset: "", Error: err.Error()})
+		_, code := sbErrorStatus(err)
+		writeJSON(w, http.StatusOK, presetCurrentResponse{CurrentPreset: "", Error: err.Error(), Code: code})
 		return
 	}
 
 	preset := ""
-	for _, line := range strings.Split(out, "\n") {
+	for _, line := range strings.Split(snap.Raw, "\n") {
 		line = strings.TrimSpace(line)
 		if strings.HasPrefix(line, "SetPreset ") {
 			preset = strings.TrimSpace(strings.TrimPrefix(line, "SetPreset "))
@@ -65,10 +116,11 @@ func (s *Server) handlePresetLoad(w http.ResponseWriter, r *http.Request) {
 	}
 
 	// This method should send the TCP command: LoadPreset <name>
-	if err := s.sb.LoadPreset(req.Name); err != nil {
-		http.Error(w, "load preset error: "+err.Error(), http.StatusBadGateway)
+	if err := s.sched.LoadPresetCtx(r.Context(), req.Name); err != nil {
+		writeSBError(w, r, "load preset error", err)
 		return
 	}
+	s.captureBaseline(r, baseline.ReasonLoad)
 
 	w.Header().Set("Content-Type", "application/json; charset=utf-8")
 	_ = json.NewEncoder(w).Encode(map[string]any{
@@ -89,10 +141,11 @@ func (s *Server) handlePresetSaveAs(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	if err := s.sb.SavePreset(name); err != nil {
-		http.Error(w, "SavePreset failed: "+err.Error(), http.StatusBadGateway)
+	if err := s.sched.SavePresetCtx(r.Context(), name); err != nil {
+		writeSBError(w, r, "SavePreset failed", err)
 		return
 	}
+	s.recordVersion(name, presethistory.ActionSaveAs, s.captureBaseline(r, baseline.ReasonSave), 0)
 
 	w.Header().Set("Content-Type", "application/json; charset=utf-8")
 	_ = json.NewEncoder(w).Encode(map[string]any{
@@ -109,15 +162,9 @@ func (s *Server) handlePresetSave(w http.ResponseWriter, r *http.Request) {
 
 	// If no name provided, save "current preset" (from DumpProgram parse)
 	if name == "" {
-		raw, err := s.sb.DumpProgram()
-		if err != nil {
-			http.Error(w, "DumpProgram failed: "+err.Error(), http.StatusBadGateway)
-			return
-		}
-
-		parsed, err := stompbox.ParseDumpProgram(raw)
+		parsed, err := s.freshProgram(r)
 		if err != nil {
-			http.Error(w, "ParseDumpProgram failed: "+err.Error(), http.StatusInternalServerError)
+			writeSBError(w, r, "DumpProgram failed", err)
 			return
 		}
 
@@ -128,10 +175,11 @@ func (s *Server) handlePresetSave(w http.ResponseWriter, r *http.Request) {
 		}
 	}
 
-	if err := s.sb.SavePreset(name); err != nil {
-		http.Error(w, "SavePreset failed: "+err.Error(), http.StatusBadGateway)
+	if err := s.sched.SavePresetCtx(r.Context(), name); err != nil {
+		writeSBError(w, r, "SavePreset failed", err)
 		return
 	}
+	s.recordVersion(name, presethistory.ActionSave, s.captureBaseline(r, baseline.ReasonSave), 0)
 
 	w.Header().Set("Content-Type", "application/json; charset=utf-8")
 	_ = json.NewEncoder(w).Encode(map[string]any{
@@ -141,7 +189,7 @@ func (s *Server) handlePresetSave(w http.ResponseWriter, r *http.Request) {
 }
 
 func (s *Server) handlePresetDelete(w http.ResponseWriter, r *http.Request) {
-	var req presetNameRequest
+	var req presetDeleteRequest
 	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
 		http.Error(w, "bad json", http.StatusBadRequest)
 		return
@@ -153,15 +201,40 @@ func (s *Server) handlePresetDelete(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	if err := s.sb.DeletePreset(name); err != nil {
-		http.Error(w, "DeletePreset failed: "+err.Error(), http.StatusBadGateway)
+	// Stompbox can't dump a preset that isn't loaded: the trash copy is the
+	// last version the history saw, or the active preset's baseline.
+	script, observed, known := s.storedScript(name)
+	if !known || observed {
+		if base := s.baseline.Current(); base != nil && base.Preset == name {
+			if bs, err := programScriptText(base.Raw); err == nil {
+				script, known = bs, true
+			}
+		}
+	}
+	if !known && !req.Force {
+		w.Header().Set("X-Error-Code", codeNoHistory)
+		http.Error(w, "no stored copy of "+name+" to put in the trash; load it once first, or delete with force", http.StatusConflict)
 		return
 	}
 
+	if err := s.sched.DeletePresetCtx(r.Context(), name); err != nil {
+		writeSBError(w, r, "DeletePreset failed", err)
+		return
+	}
+	trashed := false
+	if known {
+		if _, _, err := s.history.Record(name, presethistory.ActionDelete, script, 0); err != nil {
+			log.Printf("history: delete %s: %v", name, err)
+		} else {
+			trashed = true
+		}
+	}
+
 	w.Header().Set("Content-Type", "application/json; charset=utf-8")
 	_ = json.NewEncoder(w).Encode(map[string]any{
-		"ok":     true,
-		"preset": name,
+		"ok":      true,
+		"preset":  name,
+		"trashed": trashed,
 	})
 }
 func (s *Server) handleLoadPreset(w http.ResponseWriter, r *http.Request) {
@@ -171,10 +244,11 @@ func (s *Server) handleLoadPreset(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	if err := s.sb.LoadPreset(req.Name); err != nil {
-		http.Error(w, "loadpreset error: "+err.Error(), http.StatusBadGateway)
+	if err := s.sched.LoadPresetCtx(r.Context(), req.Name); err != nil {
+		writeSBError(w, r, "loadpreset error", err)
 		return
 	}
+	s.captureBaseline(r, baseline.ReasonLoad)
 
 	// Return OK; UI will refresh via /api/state
 	w.Header().Set("Content-Type", "application/json; charset=utf-8")
@@ -0,0 +1,78 @@
+package httpserver
+
+import (
+	"net/http"
+	"slices"
+	"testing"
+
+	"github.com/alscos/Namnesis/internal/stompboxtest"
+)
+
+const samplePreset = "05_weel_placed_rvb"
+
+func TestDeleteTrashRestore(t *testing.T) {
+	g := newTestGateway(t)
+
+	// A preset the gateway has seen saved, then another one loaded.
+	g.mustDo("POST", "/api/param/set", `{"plugin":"NoiseGate_2","param":"Thresh","value":-50}`, nil)
+	g.mustDo("POST", "/api/preset/save-as", `{"name":"Clean"}`, nil)
+	g.mustDo("POST", "/api/preset/load", `{"name":"`+samplePreset+`"}`, nil)
+	if got := g.param("NoiseGate_2", "Thresh"); got != "-70.000000" {
+		t.Fatalf("Thresh after load = %s", got)
+	}
+
+	var del struct {
+		Trashed bool `json:"trashed"`
+	}
+	g.mustDo("POST", "/api/preset/delete", `{"name":"Clean"}`, &del)
+	if !del.Trashed {
+		t.Fatalf("delete of a saved preset did not trash it")
+	}
+	if slices.Contains(g.presets(), "Clean") {
+		t.Fatalf("Clean still on Stompbox after delete")
+	}
+
+	var trash struct {
+		Presets []struct {
+			Preset string `json:"preset"`
+		} `json:"presets"`
+	}
+	g.mustDo("GET", "/api/trash", "", &trash)
+	if len(trash.Presets) != 1 || trash.Presets[0].Preset != "Clean" {
+		t.Fatalf("trash = %+v", trash.Presets)
+	}
+
+	g.mustDo("POST", "/api/trash/Clean/restore", "", nil)
+	if !slices.Contains(g.presets(), "Clean") {
+		t.Fatalf("Clean not back on Stompbox after restore")
+	}
+	prog := g.program()
+	if prog.ActivePreset != "Clean" || prog.Params["NoiseGate_2"]["Thresh"] != "-50.000000" {
+		t.Fatalf("restored program: preset %s, Thresh %s", prog.ActivePreset, prog.Params["NoiseGate_2"]["Thresh"])
+	}
+	g.mustDo("GET", "/api/trash", "", &trash)
+	if len(trash.Presets) != 0 {
+		t.Fatalf("trash after restore = %+v", trash.Presets)
+	}
+}
+
+func TestDeleteWithoutCopyNeedsForce(t *testing.T) {
+	g := newTestGateway(t)
+	g.sb.Do(func(st *stompboxtest.State) { st.AddPreset("Unseen", "SetPreset Unseen\n") })
+
+	rec := g.do("POST", "/api/preset/delete", `{"name":"Unseen"}`)
+	if rec.Code != http.StatusConflict || rec.Header().Get("X-Error-Code") != codeNoHistory {
+		t.Fatalf("delete without a copy = %d %s", rec.Code, rec.Header().Get("X-Error-Code"))
+	}
+	if !slices.Contains(g.presets(), "Unseen") {
+		t.Fatalf("refused delete removed the preset")
+	}
+
+	var del struct {
+		Trashed bool `json:"trashed"`
+	}
+	g.mustDo("POST", "/api/preset/delete", `{"name":"Unseen","force":true}`, &del)
+	if del.Trashed || slices.Contains(g.presets(), "Unseen") {
+		t.Fatalf("forced delete: trashed=%v, presets %v", del.Trashed, g.presets())
+	}
+}
@@ -0,0 +1,215 @@
+package httpserver
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"sort"
+	"strings"
+
+	"github.com/alscos/Namnesis/internal/stompbox"
+	"github.com/go-chi/chi/v5"
+)
+
+// slotFamilies lists the plugin types that can replace each other in a slot.
+// Dump Config has no slot metadata; slots not listed here accept any
+// user-selectable type.
+var slotFamilies = map[string][]string{
+	"Amp":       {"NAM", "NAMMulti"},
+	"Tonestack": {"EQ-7", "BEQ-7", "HighLow"},
+	"Cabinet":   {"Cabinet"},
+}
+
+type slotCandidate struct {
+	Type        string `json:"type"`
+	Description string `json:"description,omitempty"`
+	Current     bool   `json:"current"`
+}
+
+// slotCandidates returns the catalog types allowed in slot, sorted by name.
+func slotCandidates(cfg *stompbox.DumpConfigParsed, slot, current string) []slotCandidate {
+	types, ok := slotFamilies[slot]
+	if !ok {
+		for name := range cfg.Plugins {
+			types = append(types, name)
+		}
+		sort.Strings(types)
+	}
+	out := make([]slotCandidate, 0, len(types))
+	for _, typ := range types {
+		def := cfg.Plugins[typ]
+		if def == nil || (def.IsUserSelectable != nil && !*def.IsUserSelectable) {
+			continue
+		}
+		out = append(out, slotCandidate{Type: typ, Description: def.Description, Current: typ == current})
+	}
+	return out
+}
+
+// GET /api/slots/{slot}
+// The plugin in a slot, its params and the types it can be swapped for.
+func (s *Server) handleSlotGet(w http.ResponseWriter, r *http.Request) {
+	slot := strings.TrimSpace(chi.URLParam(r, "slot"))
+	if slot == "" {
+		http.Error(w, "missing slot", http.StatusBadRequest)
+		return
+	}
+
+	psnap, err := s.programSnapshot(r)
+	if err != nil {
+		writeSBError(w, r, "program error", err)
+		return
+	}
+	prog := psnap.Program
+	instance, ok := prog.Slots[slot]
+	if !ok {
+		http.Error(w, "unknown slot: "+slot, http.StatusNotFound)
+		return
+	}
+	snap, err := s.configSnapshot(r)
+	if err != nil {
+		writeSBError(w, r, "dumpconfig error", err)
+		return
+	}
+
+	typ := stompbox.PluginType(instance)
+	if def, ok := snap.Config.Resolve(instance); ok {
+		typ = def.Name
+	}
+
+	w.Header().Set("Content-Type", "application/json; charset=utf-8")
+	_ = json.NewEncoder(w).Encode(map[string]any{
+		"slot":       slot,
+		"instance":   instance,
+		"type":       typ,
+		"params":     prog.Params[instance],
+		"candidates": slotCandidates(snap.Config, slot, typ),
+	})
+}
+
+type slotSetRequest struct {
+	// Plugin is a type (NAMMulti) for a new instance, or an existing instance name.
+	Plugin string `json:"plugin"`
+	// Release frees the replaced instance once nothing else uses it.
+	Release bool `json:"release"`
+}
+
+// POST /api/slots/{slot}
+// Body: {"plugin":"NAMMulti","release":true}
+func (s *Server) handleSlotSet(w http.ResponseWriter, r *http.Request) {
+	slot := strings.TrimSpace(chi.URLParam(r, "slot"))
+	if slot == "" {
+		http.Error(w, "missing slot", http.StatusBadRequest)
+		return
+	}
+
+	var req slotSetRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "bad json", http.StatusBadRequest)
+		return
+	}
+	plugin := strings.TrimSpace(req.Plugin)
+	if plugin == "" {
+		http.Error(w, "missing plugin", http.StatusBadRequest)
+		return
+	}
+
+	// 1) Validate the candidate against the catalog and the slot family.
+	snap, err := s.configSnapshot(r)
+	if err != nil {
+		writeSBError(w, r, "dumpconfig error", err)
+		return
+	}
+	def, ok := snap.Config.Resolve(plugin)
+	if !ok {
+		http.Error(w, "unknown plugin: "+plugin, http.StatusBadRequest)
+		return
+	}
+	allowed := false
+	for _, c := range slotCandidates(snap.Config, slot, "") {
+		allowed = allowed || c.Type == def.Name
+	}
+	if !allowed {
+		http.Error(w, fmt.Sprintf("plugin type %s is not allowed in slot %s", def.Name, slot), http.StatusBadRequest)
+		return
+	}
+
+	s.editMu.Lock()
+	defer s.editMu.Unlock()
+
+	before, err := s.freshProgram(r)
+	if err != nil {
+		writeSBError(w, r, "program error", err)
+		return
+	}
+	previous, ok := before.Slots[slot]
+	if !ok {
+		http.Error(w, "unknown slot: "+slot, http.StatusNotFound)
+		return
+	}
+
+	// 2) Send the name as given: Stompbox names a new instance of a bare
+	// type itself, so the instance is read back from the program.
+	if err := s.sched.SetPluginSlotCtx(r.Context(), slot, plugin); err != nil {
+		writeSBError(w, r, "setpluginslot error", err)
+		return
+	}
+
+	after, err := s.freshProgram(r)
+	if err != nil {
+		writeSBError(w, r, "program error", err)
+		return
+	}
+	instance := after.Slots[slot]
+	if stompbox.PluginType(instance) != def.Name {
+		http.Error(w, fmt.Sprintf("slot %s holds %q after setting %s", slot, instance, plugin), http.StatusBadGateway)
+		return
+	}
+
+	// 3) Release the replaced instance only if no chain or slot still uses it.
+	released := false
+	keptBy := ""
+	if req.Release && previous != instance {
+		keptBy = instanceUser(after, previous)
+		if keptBy == "" {
+			if err := s.sched.ReleasePluginCtx(r.Context(), previous); err != nil {
+				s.undo.Record(editSession(w, r), slotUndoStep(before, slot, previous, instance, false))
+				writeSBError(w, r, "releaseplugin error", err)
+				return
+			}
+			released = true
+		}
+	}
+	if previous != instance {
+		s.undo.Record(editSession(w, r), slotUndoStep(before, slot, previous, instance, released))
+	}
+
+	w.Header().Set("Content-Type", "application/json; charset=utf-8")
+	_ = json.NewEncoder(w).Encode(map[string]any{
+		"ok":       true,
+		"slot":     slot,
+		"type":     def.Name,
+		"instance": instance,
+		"params":   after.Params[instance],
+		"previous": previous,
+		"released": released,
+		"keptBy":   keptBy,
+	})
+}
+
+// instanceUser names the chain or slot that still references instance ("" if none).
+func instanceUser(p *stompbox.Program, instance string) string {
+	for chain, plugins := range p.Chains {
+		for _, name := range plugins {
+			if name == instance {
+				return "chain " + chain
+			}
+		}
+	}
+	for slot, name := range p.Slots {
+		if name == instance {
+			return "slot " + slot
+		}
+	}
+	return ""
+}
@@ -8,25 +8,31 @@ import (
 
 type stateResponse struct {
 	Meta struct {
-		Now string `json:"now"`
+		Now      string `json:"now"`
+		EngineUp bool   `json:"engineUp"` // false while the client's breaker is open
 	} `json:"meta"`
 
 	DumpConfig struct {
 		Raw      string `json:"raw,omitempty"`
 		Duration string `json:"duration"`
 		Error    string `json:"error,omitempty"`
+		Code     string `json:"code,omitempty"`
+		Revision uint64 `json:"revision,omitempty"` // config cache revision
 	} `json:"dumpConfig"`
 
 	Program struct {
 		Raw      string `json:"raw,omitempty"`
 		Duration string `json:"duration"`
 		Error    string `json:"error,omitempty"`
+		Code     string `json:"code,omitempty"`
+		Revision uint64 `json:"revision,omitempty"` // state hub revision
 	} `json:"program"`
 
 	Presets struct {
 		Raw      string `json:"raw,omitempty"`
 		Duration string `json:"duration"`
 		Error    string `json:"error,omitempty"`
+		Code     string `json:"code,omitempty"`
 	} `json:"presets"`
 }
 
@@ -34,36 +40,43 @@ func (s *Server) handleState(w http.ResponseWriter, r *http.Request) {
 	var resp stateResponse
 	resp.Meta.Now = time.Now().Format(time.RFC3339)
 
-	// Dump Config
+	// Dump Config (cached; dumped again only when invalidated or old)
 	t0 := time.Now()
-	out, err := s.sb.DumpConfig()
+	cfg, err := s.cfgCache.Get(r.Context())
 	resp.DumpConfig.Duration = time.Since(t0).String()
 	if err != nil {
 		resp.DumpConfig.Error = err.Error()
+		_, resp.DumpConfig.Code = sbErrorStatus(err)
 	} else {
-		resp.DumpConfig.Raw = out
+		resp.DumpConfig.Raw = cfg.Raw
+		resp.DumpConfig.Revision = cfg.Revision
 	}
 
-	// Dump Program
+	// Dump Program (shared snapshot; ?refresh=1 dumps again)
 	t1 := time.Now()
-	out, err = s.sb.DumpProgram()
+	prog, err := s.programSnapshot(r)
 	resp.Program.Duration = time.Since(t1).String()
 	if err != nil {
 		resp.Program.Error = err.Error()
+		_, resp.Program.Code = sbErrorStatus(err)
 	} else {
-		resp.Program.Raw = out
+		resp.Program.Raw = prog.Raw
+		resp.Program.Revision = prog.Revision
 	}
 
 	// List Presets
 	t2 := time.Now()
-	out, err = s.sb.ListPresets()
+	out, err := s.sched.ListPresetsCtx(r.Context())
 	resp.Presets.Duration = time.Since(t2).String()
 	if err != nil {
 		resp.Presets.Error = err.Error()
+		_, resp.Presets.Code = sbErrorStatus(err)
 	} else {
 		resp.Presets.Raw = out
 	}
 
+	resp.Meta.EngineUp = s.sb.Available()
+
 	// Decide HTTP status
 	status := http.StatusOK
 	allFailed := resp.DumpConfig.Error != "" &&
@@ -72,6 +85,9 @@ func (s *Server) handleState(w http.ResponseWriter, r *http.Request) {
 
 	if allFailed {
 		status = http.StatusBadGateway
+		if !resp.Meta.EngineUp {
+			status = http.StatusServiceUnavailable
+		}
 	}
 
 	// Write headers + status ONCE
@@ -0,0 +1,79 @@
+package httpserver
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/alscos/Namnesis/internal/stompbox"
+)
+
+// tracer returns the client tracer, answering 404 when tracing is disabled.
+func (s *Server) tracer(w http.ResponseWriter) *stompbox.Tracer {
+	if s.sb.Tracer == nil {
+		http.Error(w, "protocol tracing is disabled (TRACE_SIZE=0)", http.StatusNotFound)
+		return nil
+	}
+	return s.sb.Tracer
+}
+
+// GET /api/debug/trace
+// Returns the buffered exchanges as a transcript document.
+func (s *Server) handleTrace(w http.ResponseWriter, r *http.Request) {
+	t := s.tracer(w)
+	if t == nil {
+		return
+	}
+	w.Header().Set("Content-Type", "application/json; charset=utf-8")
+	_ = stompbox.WriteTranscript(w, s.sb.Addr, t.Entries())
+}
+
+// GET /api/debug/trace/export
+// Same document as /api/debug/trace, as a download that namnesis-sim -replay accepts.
+func (s *Server) handleTraceExport(w http.ResponseWriter, r *http.Request) {
+	t := s.tracer(w)
+	if t == nil {
+		return
+	}
+	name := fmt.Sprintf("namnesis-trace-%s.json", time.Now().Format("20060102-150405"))
+	w.Header().Set("Content-Type", "application/json; charset=utf-8")
+	w.Header().Set("Content-Disposition", `attachment; filename="`+name+`"`)
+	_ = stompbox.WriteTranscript(w, s.sb.Addr, t.Entries())
+}
+
+// GET /api/debug/trace/live
+// Server-Sent Events: one "trace" event per exchange, as it happens.
+func (s *Server) handleTraceLive(w http.ResponseWriter, r *http.Request) {
+	t := s.tracer(w)
+	if t == nil {
+		return
+	}
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		http.Error(w, "streaming unsupported", http.StatusInternalServerError)
+		return
+	}
+
+	ch, cancel := t.Subscribe(64)
+	defer cancel()
+
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.WriteHeader(http.StatusOK)
+	flusher.Flush()
+
+	for {
+		select {
+		case <-r.Context().Done():
+			return
+		case e := <-ch:
+			b, err := json.Marshal(e)
+			if err != nil {
+				continue
+			}
+			_, _ = fmt.Fprintf(w, "event: trace\ndata: %s\n\n", b)
+			flusher.Flush()
+		}
+	}
+}
@@ -0,0 +1,208 @@
+package httpserver
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"net/http"
+	"slices"
+	"sort"
+	"strings"
+
+	"github.com/alscos/Namnesis/internal/baseline"
+	"github.com/alscos/Namnesis/internal/stompbox"
+	"github.com/alscos/Namnesis/internal/undo"
+)
+
+const sessionCookie = "namnesis_session"
+
+// editSession identifies the client whose undo stack an edit goes to: the
+// X-Session-ID header for API clients, else a cookie set on first use.
+func editSession(w http.ResponseWriter, r *http.Request) string {
+	if id := strings.TrimSpace(r.Header.Get("X-Session-ID")); id != "" {
+		return id
+	}
+	if c, err := r.Cookie(sessionCookie); err == nil && c.Value != "" {
+		return c.Value
+	}
+	b := make([]byte, 16)
+	_, _ = rand.Read(b)
+	id := hex.EncodeToString(b)
+	http.SetCookie(w, &http.Cookie{Name: sessionCookie, Value: id, Path: "/", HttpOnly: true, SameSite: http.SameSiteLaxMode})
+	return id
+}
+
+func paramPlan(plugin, param, value string) *baseline.Plan {
+	return &baseline.Plan{Params: []baseline.ParamSet{{Plugin: plugin, Param: param, Value: value}}}
+}
+
+func chainPlan(chain string, plugins []string) *baseline.Plan {
+	return &baseline.Plan{Chains: []baseline.ChainSet{{Chain: chain, Plugins: plugins}}}
+}
+
+// paramUndoStep prepares the undo step of a plugin.param change. Call it
+// before sending the change, then recordEdit once it succeeded. key merges
+// the step into a running drag on the same param ("" = never); in that case
+// the previous value isn't needed and no dump is made. Otherwise the
+// previous value comes from a fresh dump: a cached snapshot may predate an
+// edit made just before. Returns nil when the previous value is unknown:
+// the edit then can't be undone.
+func (s *Server) paramUndoStep(r *http.Request, sess, key, plugin, param string) *undo.Step {
+	step := &undo.Step{Key: key, Label: plugin + "." + param}
+	if key != "" && s.undo.Merges(sess, key) {
+		return step
+	}
+	before, err := s.freshProgram(r)
+	if err != nil {
+		return nil
+	}
+	old, ok := before.Params[plugin][param]
+	if !ok {
+		return nil
+	}
+	step.Undo = paramPlan(plugin, param, old)
+	return step
+}
+
+// chainUndoStep prepares the undo step of SetChain chain next: put the old
+// list back, restore the params of instances next drops (Stompbox may free
+// them) and release the instances next creates. Like paramUndoStep it
+// reads the program fresh.
+func (s *Server) chainUndoStep(r *http.Request, chain string, next []string) *undo.Step {
+	before, err := s.freshProgram(r)
+	if err != nil {
+		return nil
+	}
+	old := before.Chains[chain]
+	plan := &baseline.Plan{Chains: []baseline.ChainSet{{Chain: chain, Plugins: old}}}
+	for _, inst := range old {
+		if slices.Contains(next, inst) {
+			continue
+		}
+		plan.Params = append(plan.Params, instanceParams(before, inst)...)
+	}
+	for _, inst := range next {
+		if _, ok := before.Params[inst]; !ok && instanceUser(before, inst) == "" {
+			plan.Release = append(plan.Release, inst)
+		}
+	}
+	return &undo.Step{Label: "chain " + chain, Undo: plan}
+}
+
+// chainAddUndoStep is the step of inserting a new instance into chain:
+// undo puts the old list back and releases the instance, redo inserts it
+// again under the same name.
+func chainAddUndoStep(chain string, old, next []string, instance string) *undo.Step {
+	return &undo.Step{
+		Label: "chain " + chain,
+		Undo:  &baseline.Plan{Chains: []baseline.ChainSet{{Chain: chain, Plugins: old}}, Release: []string{instance}},
+		Redo:  chainPlan(chain, next),
+	}
+}
+
+// slotUndoStep is the step of putting instance in slot in place of
+// previous; before is the program dumped ahead of the change. A released
+// previous instance comes back with its params, and an instance the change
+// created is released again.
+func slotUndoStep(before *stompbox.Program, slot, previous, instance string, released bool) *undo.Step {
+	undoPlan := &baseline.Plan{Slots: []baseline.SlotSet{{Slot: slot, Plugin: previous}}}
+	redoPlan := &baseline.Plan{Slots: []baseline.SlotSet{{Slot: slot, Plugin: instance}}}
+	if released {
+		undoPlan.Params = instanceParams(before, previous)
+		redoPlan.Release = []string{previous}
+	}
+	if _, ok := before.Params[instance]; !ok {
+		undoPlan.Release = []string{instance}
+	}
+	return &undo.Step{Label: "slot " + slot, Undo: undoPlan, Redo: redoPlan}
+}
+
+// instanceParams lists the params of inst in prog as ParamSets, by name.
+func instanceParams(prog *stompbox.Program, inst string) []baseline.ParamSet {
+	params := prog.Params[inst]
+	names := make([]string, 0, len(params))
+	for name := range params {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	out := make([]baseline.ParamSet, 0, len(names))
+	for _, name := range names {
+		out = append(out, baseline.ParamSet{Plugin: inst, Param: name, Value: params[name]})
+	}
+	return out
+}
+
+// recordEdit completes a step from paramUndoStep/chainUndoStep with the
+// forward commands and pushes it. A nil step is ignored.
+func (s *Server) recordEdit(sess string, step *undo.Step, redo *baseline.Plan) {
+	if step == nil {
+		return
+	}
+	step.Redo = redo
+	s.undo.Record(sess, step)
+}
+
+// GET /api/undo
+// The caller's undo and redo stacks, newest last.
+func (s *Server) handleUndoStacks(w http.ResponseWriter, r *http.Request) {
+	undoSteps, redoSteps := s.undo.Stacks(editSession(w, r))
+	writeJSON(w, http.StatusOK, map[string]any{
+		"undo": stepsOrEmpty(undoSteps),
+		"redo": stepsOrEmpty(redoSteps),
+	})
+}
+
+func stepsOrEmpty(steps []undo.Step) []undo.Step {
+	if steps == nil {
+		return []undo.Step{}
+	}
+	return steps
+}
+
+// POST /api/undo
+func (s *Server) handleUndo(w http.ResponseWriter, r *http.Request) {
+	s.replayStep(w, r, "undo", s.undo.Undo)
+}
+
+// POST /api/redo
+func (s *Server) handleRedo(w http.ResponseWriter, r *http.Request) {
+	s.replayStep(w, r, "redo", s.undo.Redo)
+}
+
+func (s *Server) replayStep(w http.ResponseWriter, r *http.Request, what string,
+	replay func(string, func(*baseline.Plan) error) (*undo.Step, error)) {
+	sess := editSession(w, r)
+
+	s.editMu.Lock()
+	defer s.editMu.Unlock()
+
+	// The plan runs to the end even past the request timeout; the step
+	// moves between the stacks only once it applied.
+	ctx, cancel := detachedContext(r)
+	defer cancel()
+
+	var res planResult
+	step, err := replay(sess, func(p *baseline.Plan) error {
+		var err error
+		res, err = s.applyPlan(ctx, p)
+		return err
+	})
+	switch {
+	case errors.Is(err, undo.ErrEmpty):
+		http.Error(w, "nothing to "+what, http.StatusConflict)
+		return
+	case err != nil:
+		writeSBError(w, r, what+" error", err)
+		return
+	}
+
+	undoSteps, redoSteps := s.undo.Stacks(sess)
+	writeJSON(w, http.StatusOK, map[string]any{
+		"ok":      true,
+		"step":    step,
+		"applied": res.Applied,
+		"skipped": res.Skipped,
+		"canUndo": len(undoSteps) > 0,
+		"canRedo": len(redoSteps) > 0,
+	})
+}
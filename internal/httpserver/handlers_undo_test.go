@@ -0,0 +1,65 @@
+package httpserver
+
+import (
+	"net/http"
+	"slices"
+	"strings"
+	"testing"
+)
+
+func TestUndoRedoParam(t *testing.T) {
+	g := newTestGateway(t)
+
+	g.mustDo("POST", "/api/param/set", `{"plugin":"NoiseGate_2","param":"Thresh","value":-50}`, nil)
+	g.mustDo("POST", "/api/undo", "", nil)
+	if got := g.param("NoiseGate_2", "Thresh"); got != "-70.000000" {
+		t.Fatalf("Thresh after undo = %s", got)
+	}
+	g.mustDo("POST", "/api/redo", "", nil)
+	if got := g.param("NoiseGate_2", "Thresh"); got != "-50.000000" {
+		t.Fatalf("Thresh after redo = %s", got)
+	}
+	if rec := g.do("POST", "/api/redo", ""); rec.Code != http.StatusConflict {
+		t.Fatalf("second redo = %d; want 409", rec.Code)
+	}
+}
+
+func TestUndoRedoChain(t *testing.T) {
+	g := newTestGateway(t)
+	orig := g.program().Chains["Input"]
+
+	// Drop Boost_2: undo puts it back with its params.
+	g.mustDo("POST", "/api/param/set", `{"plugin":"Boost_2","param":"Gain","value":15}`, nil)
+	next := slices.DeleteFunc(slices.Clone(orig), func(s string) bool { return s == "Boost_2" })
+	g.mustDo("POST", "/api/chains/Input/set", `{"plugins":["`+strings.Join(next, `","`)+`"]}`, nil)
+	if got := g.program().Chains["Input"]; !slices.Equal(got, next) {
+		t.Fatalf("chain after set = %v", got)
+	}
+
+	// Insert a new instance: undo releases it, redo brings it back.
+	var added struct {
+		Instance string `json:"instance"`
+	}
+	g.mustDo("POST", "/api/chains/Input/plugins", `{"type":"Delay","index":1}`, &added)
+
+	g.mustDo("POST", "/api/undo", "", nil)
+	prog := g.program()
+	if !slices.Equal(prog.Chains["Input"], next) {
+		t.Fatalf("chain after undoing the insert = %v; want %v", prog.Chains["Input"], next)
+	}
+	if _, ok := prog.Params[added.Instance]; ok {
+		t.Fatalf("%s still loaded after undoing its insert", added.Instance)
+	}
+
+	g.mustDo("POST", "/api/undo", "", nil)
+	prog = g.program()
+	if !slices.Equal(prog.Chains["Input"], orig) || prog.Params["Boost_2"]["Gain"] != "15.000000" {
+		t.Fatalf("after undoing the set: chain %v, Boost_2.Gain %s", prog.Chains["Input"], prog.Params["Boost_2"]["Gain"])
+	}
+
+	g.mustDo("POST", "/api/redo", "", nil)
+	g.mustDo("POST", "/api/redo", "", nil)
+	if got := g.program().Chains["Input"]; len(got) != len(next)+1 || got[1] != added.Instance {
+		t.Fatalf("chain after redoing both = %v", got)
+	}
+}
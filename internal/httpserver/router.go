@@ -1,14 +1,22 @@
 package httpserver
 
 import (
+	"context"
 	"html/template"
 	"net/http"
 	"path/filepath"
+	"sync"
 	"time"
 
+	"github.com/alscos/Namnesis/internal/baseline"
 	"github.com/alscos/Namnesis/internal/config"
+	"github.com/alscos/Namnesis/internal/configcache"
+	"github.com/alscos/Namnesis/internal/presethistory"
+	"github.com/alscos/Namnesis/internal/scheduler"
+	"github.com/alscos/Namnesis/internal/statehub"
 	"github.com/alscos/Namnesis/internal/stompbox"
 	"github.com/alscos/Namnesis/internal/sysinfo"
+	"github.com/alscos/Namnesis/internal/undo"
 
 	"github.com/go-chi/chi/v5"
 	"github.com/go-chi/chi/v5/middleware"
@@ -17,20 +25,71 @@ import (
 type RouterDeps struct {
 	Config config.Config
 	SB     *stompbox.Client
+	// Sched orders commands sent to SB. Optional: one is created if nil,
+	// but share it with other SB users (OLED) so they are ordered too.
+	Sched *scheduler.Scheduler
+	// ConfigCache serves Dump Config lookups from memory. Optional: one
+	// backed by Sched is created if nil.
+	ConfigCache *configcache.Cache
+	// Programs shares one polled Dump Program between handlers, event
+	// streams and the OLED. Optional: one backed by Sched is created and
+	// run if nil.
+	Programs *statehub.Hub
+	// Baseline remembers the program of the loaded preset for unsaved-change
+	// detection. Optional: one watching Programs is created and run if nil.
+	Baseline *baseline.Tracker
 }
 
 type Server struct {
-	cfg config.Config
-	sb  *stompbox.Client
-	tpl *template.Template
-	sys *sysinfo.Collector
+	cfg      config.Config
+	sb       *stompbox.Client
+	sched    *scheduler.Scheduler
+	cfgCache *configcache.Cache
+	programs *statehub.Hub
+	baseline *baseline.Tracker
+	history  *presethistory.Store
+	undo     *undo.History
+	nudges   *nudger
+	tpl      *template.Template
+	sys      *sysinfo.Collector
+	events   *eventHub
+
+	// editMu serializes read-modify-write edits of chains and slots.
+	editMu sync.Mutex
 }
 
 func NewRouter(deps RouterDeps) (http.Handler, error) {
 	s := &Server{
-		cfg: deps.Config,
-		sb:  deps.SB,
-		sys: sysinfo.NewCollector(),
+		cfg:      deps.Config,
+		sb:       deps.SB,
+		sched:    deps.Sched,
+		cfgCache: deps.ConfigCache,
+		programs: deps.Programs,
+		baseline: deps.Baseline,
+		sys:      sysinfo.NewCollector(),
+	}
+	if s.sched == nil {
+		s.sched = scheduler.New(s.sb, s.cfg.SchedMaxInFlight)
+	}
+	if s.programs == nil {
+		s.programs = statehub.New(s.sched.DumpProgramCtx)
+		go s.programs.Run(context.Background())
+	}
+	if s.baseline == nil {
+		s.baseline = baseline.New(s.programs)
+		go s.baseline.Run(context.Background())
+	}
+	history, err := presethistory.Open(filepath.Join(s.cfg.DataDir, "history"), s.cfg.HistoryKeep)
+	if err != nil {
+		return nil, err
+	}
+	s.history = history
+	s.baseline.OnCapture(s.recordLoad)
+	s.undo = undo.New(s.cfg.UndoLimit, s.cfg.UndoCoalesce)
+	s.nudges = newNudger(s.cfg.NudgeCoalesce)
+	s.events = newEventHub(s)
+	if s.cfgCache == nil {
+		s.cfgCache = configcache.New(s.sched.DumpConfigRawCtx)
 	}
 
 	tplPath := filepath.Join("web", "templates", "*.html")
@@ -44,7 +103,6 @@ func NewRouter(deps RouterDeps) (http.Handler, error) {
 	r.Use(middleware.RequestID)
 	r.Use(middleware.RealIP)
 	r.Use(middleware.Recoverer)
-	r.Use(middleware.Timeout(3 * time.Second))
 
 	if len(s.cfg.AllowedSubnets) > 0 {
 		allow, err := newCIDRAllowlist(s.cfg.AllowedSubnets)
@@ -54,36 +112,73 @@ func NewRouter(deps RouterDeps) (http.Handler, error) {
 		r.Use(allow.middleware)
 	}
 
-	fs := http.FileServer(http.Dir(filepath.Join("web", "static")))
-	r.Handle("/static/*", http.StripPrefix("/static/", fs))
+	// Long-lived streams: must not run under the request timeout below.
+	r.Get("/api/debug/trace/live", s.handleTraceLive)
+	r.Get("/api/events", s.handleEvents)
 
-	r.Get("/", func(w http.ResponseWriter, r *http.Request) {
-		http.Redirect(w, r, "/dumpconfig", http.StatusFound)
-	})
+	r.Group(func(r chi.Router) {
+		r.Use(middleware.Timeout(3 * time.Second))
+		r.Use(s.invalidateProgram)
+
+		fs := http.FileServer(http.Dir(filepath.Join("web", "static")))
+		r.Handle("/static/*", http.StripPrefix("/static/", fs))
 
-	// Raw API endpoints (plain text)
-	r.Get("/api/dumpconfig", s.handleDumpConfigRaw)
-	r.Get("/api/program", s.handleProgramRaw)
-	r.Get("/api/debug/program-parsed", s.handleProgramParsedDebug)
-	r.Get("/api/presets", s.handlePresetsRaw)
-	r.Get("/api/state", s.handleState)
-	r.Get("/api/system", s.handleSystem)
-	r.Get("/ui", s.handleUIPage)
-	r.Get("/api/preset/current", s.handlePresetCurrent)
-	r.Get("/api/preset/human", s.handlePresetHuman)
-	r.Post("/api/preset/load", s.handlePresetLoad)
-	r.Post("/api/preset/save-as", s.handlePresetSaveAs)
-	r.Post("/api/preset/delete", s.handlePresetDelete)
-	r.Get("/api/debug/config-parsed", s.handleConfigParsedDebug)
-	r.Post("/api/param/file", s.handleSetFileParam)
-	r.Post("/api/preset/save", s.handlePresetSave)
-	r.Post("/api/plugins/{plugin}/enabled", s.handlePluginEnabled)
-	r.Post("/api/param/set", s.handleParamSet)
-	r.Post("/api/chains/{chain}/set", s.handleChainSet)
-	r.Post("/api/plugins/{plugin}/release", s.handlePluginRelease)
-
-	// HTML page
-	r.Get("/dumpconfig", s.handleDumpConfigPage)
+		r.Get("/", func(w http.ResponseWriter, r *http.Request) {
+			http.Redirect(w, r, "/dumpconfig", http.StatusFound)
+		})
+
+		// Raw API endpoints (plain text)
+		r.Get("/api/dumpconfig", s.handleDumpConfigRaw)
+		r.Get("/api/program", s.handleProgramRaw)
+		r.Get("/api/debug/program-parsed", s.handleProgramParsedDebug)
+		r.Get("/api/debug/program-script", s.handleProgramScriptDebug)
+		r.Get("/api/program/export", s.handleProgramExport)
+		r.Post("/api/diff", s.handleDiff)
+		r.Get("/api/presets", s.handlePresetsRaw)
+		r.Get("/api/v2/presets", s.handlePresetsV2)
+		r.Get("/api/state", s.handleState)
+		r.Get("/api/system", s.handleSystem)
+		r.Get("/api/stompbox/health", s.handleStompboxHealth)
+		r.Get("/ui", s.handleUIPage)
+		r.Get("/api/preset/current", s.handlePresetCurrent)
+		r.Get("/api/preset/human", s.handlePresetHuman)
+		r.Post("/api/preset/load", s.handlePresetLoad)
+		r.Post("/api/preset/save-as", s.handlePresetSaveAs)
+		r.Post("/api/preset/delete", s.handlePresetDelete)
+		r.Get("/api/preset/dirty", s.handlePresetDirty)
+		r.Post("/api/preset/revert", s.handlePresetRevert)
+		r.Get("/api/presets/{name}/history", s.handleHistoryList)
+		r.Get("/api/presets/{name}/history/diff", s.handleHistoryDiff)
+		r.Get("/api/presets/{name}/history/{seq}", s.handleHistoryGet)
+		r.Post("/api/presets/{name}/history/{seq}/restore", s.handleHistoryRestore)
+		r.Get("/api/param/normalized", s.handleParamNormalized)
+		r.Get("/api/undo", s.handleUndoStacks)
+		r.Post("/api/undo", s.handleUndo)
+		r.Post("/api/redo", s.handleRedo)
+		r.Get("/api/trash", s.handleTrashList)
+		r.Post("/api/trash/{name}/restore", s.handleTrashRestore)
+		r.Get("/api/debug/config-parsed", s.handleConfigParsedDebug)
+		r.Get("/api/debug/trace", s.handleTrace)
+		r.Get("/api/debug/scheduler", s.handleSchedulerStats)
+		r.Get("/api/debug/config-cache", s.handleConfigCacheStats)
+		r.Get("/api/debug/state-hub", s.handleStateHubStats)
+		r.Get("/api/debug/trace/export", s.handleTraceExport)
+		r.Post("/api/param/file", s.handleSetFileParam)
+		r.Post("/api/preset/save", s.handlePresetSave)
+		r.Post("/api/plugins/{plugin}/enabled", s.handlePluginEnabled)
+		r.Post("/api/param/set", s.handleParamSet)
+		r.Post("/api/param/set-normalized", s.handleParamSetNormalized)
+		r.Post("/api/param/nudge", s.handleParamNudge)
+		r.Post("/api/params/batch", s.handleParamsBatch)
+		r.Post("/api/chains/{chain}/set", s.handleChainSet)
+		r.Post("/api/chains/{chain}/plugins", s.handleChainAddPlugin)
+		r.Post("/api/plugins/{plugin}/release", s.handlePluginRelease)
+		r.Get("/api/slots/{slot}", s.handleSlotGet)
+		r.Post("/api/slots/{slot}", s.handleSlotSet)
+
+		// HTML page
+		r.Get("/dumpconfig", s.handleDumpConfigPage)
+	})
 
 	return r, nil
 }
@@ -0,0 +1,123 @@
+package httpserver
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/alscos/Namnesis/internal/baseline"
+	"github.com/alscos/Namnesis/internal/config"
+	"github.com/alscos/Namnesis/internal/scheduler"
+	"github.com/alscos/Namnesis/internal/statehub"
+	"github.com/alscos/Namnesis/internal/stompbox"
+	"github.com/alscos/Namnesis/internal/stompboxtest"
+)
+
+// testGateway is a router in front of a fake Stompbox loaded with
+// docs/samples, plus a client of its own to inspect the fake.
+type testGateway struct {
+	t   *testing.T
+	sb  *stompboxtest.Server
+	h   http.Handler
+	raw *stompbox.Client
+}
+
+func newTestGateway(t *testing.T) *testGateway {
+	t.Helper()
+	// Templates and samples are found relative to the repo root.
+	t.Chdir("../..")
+
+	st, err := stompboxtest.LoadStateDir("docs/samples")
+	if err != nil {
+		t.Fatalf("LoadStateDir: %v", err)
+	}
+	fake := stompboxtest.NewServer(st)
+	if err := fake.Listen("127.0.0.1:0"); err != nil {
+		t.Fatalf("Listen: %v", err)
+	}
+	t.Cleanup(func() { fake.Close() })
+
+	sb := stompbox.New(fake.Addr())
+	sb.ReadTimeout = time.Second
+	t.Cleanup(func() { sb.Close() })
+	raw := stompbox.New(fake.Addr())
+	raw.ReadTimeout = time.Second
+	t.Cleanup(func() { raw.Close() })
+
+	cfg := config.LoadFromEnv()
+	cfg.DataDir = t.TempDir()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	t.Cleanup(cancel)
+	sched := scheduler.New(sb, cfg.SchedMaxInFlight)
+	programs := statehub.New(sched.DumpProgramCtx)
+	tracker := baseline.New(programs)
+	go programs.Run(ctx)
+	go tracker.Run(ctx)
+
+	h, err := NewRouter(RouterDeps{Config: cfg, SB: sb, Sched: sched, Programs: programs, Baseline: tracker})
+	if err != nil {
+		t.Fatalf("NewRouter: %v", err)
+	}
+	return &testGateway{t: t, sb: fake, h: h, raw: raw}
+}
+
+// do sends a request with a fixed session and returns the recorded response.
+func (g *testGateway) do(method, path, body string) *httptest.ResponseRecorder {
+	g.t.Helper()
+	req := httptest.NewRequest(method, path, strings.NewReader(body))
+	req.Header.Set("X-Session-ID", "test")
+	rec := httptest.NewRecorder()
+	g.h.ServeHTTP(rec, req)
+	return rec
+}
+
+// mustDo is do for requests expected to answer 200; it decodes the JSON body into out (if not nil).
+func (g *testGateway) mustDo(method, path, body string, out any) {
+	g.t.Helper()
+	rec := g.do(method, path, body)
+	if rec.Code != http.StatusOK {
+		g.t.Fatalf("%s %s = %d: %s", method, path, rec.Code, rec.Body)
+	}
+	if out != nil {
+		decodeBody(g.t, rec, out)
+	}
+}
+
+func decodeBody(t *testing.T, rec *httptest.ResponseRecorder, out any) {
+	t.Helper()
+	if err := json.Unmarshal(rec.Body.Bytes(), out); err != nil {
+		t.Fatalf("decode %s: %v", rec.Body, err)
+	}
+}
+
+// program dumps the fake's live program, bypassing the gateway.
+func (g *testGateway) program() *stompbox.Program {
+	g.t.Helper()
+	raw, err := g.raw.DumpProgram()
+	if err != nil {
+		g.t.Fatalf("DumpProgram: %v", err)
+	}
+	p, err := stompbox.ParseDumpProgram(raw)
+	if err != nil {
+		g.t.Fatalf("ParseDumpProgram: %v", err)
+	}
+	return p
+}
+
+// param reads plugin.param from the live program.
+func (g *testGateway) param(plugin, param string) string {
+	g.t.Helper()
+	return g.program().Params[plugin][param]
+}
+
+// presets lists the presets stored on the fake.
+func (g *testGateway) presets() []string {
+	var out []string
+	g.sb.Do(func(st *stompboxtest.State) { out = st.Presets() })
+	return out
+}
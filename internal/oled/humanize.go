@@ -5,6 +5,8 @@ import (
 	"path"
 	"regexp"
 	"strings"
+
+	"github.com/alscos/Namnesis/internal/stompbox"
 )
 
 // -------------------------
@@ -27,7 +29,7 @@ func HumanizeFromDumpProgram(raw string) (num, name, amp, fx string) {
 		// Preset line
 		if strings.HasPrefix(line, "SetPreset ") {
 			token := strings.TrimSpace(strings.TrimPrefix(line, "SetPreset "))
-			num, name = splitPresetNumName(token)
+			num, name = stompbox.SplitPresetName(token)
 			continue
 		}
 
@@ -48,7 +50,7 @@ func HumanizeFromDumpProgram(raw string) (num, name, amp, fx string) {
 			}
 
 			// NAM model
-			if blk == "NAM" && key == "model" && namModelRaw == "" {
+			if stompbox.PluginType(blk) == "NAM" && key == "model" && namModelRaw == "" {
 				if q := extractQuoted(line); q != "" {
 					namModelRaw = q
 				} else {
@@ -58,7 +60,7 @@ func HumanizeFromDumpProgram(raw string) (num, name, amp, fx string) {
 			}
 
 			// Cabinet impulse
-			if strings.EqualFold(blk, "Cabinet") && key == "impulse" && cabImpulseRaw == "" {
+			if strings.EqualFold(stompbox.PluginType(blk), "Cabinet") && key == "impulse" && cabImpulseRaw == "" {
 				if q := extractQuoted(line); q != "" {
 					cabImpulseRaw = q
 				} else {
@@ -87,7 +89,7 @@ func HumanizeFromDumpProgram(raw string) (num, name, amp, fx string) {
 	// Canonical order: GATE COMP BST OD FUZZ WAH MOD DLY REV NAM CAB EQ
 	var tokens []string
 
-	// Helper: enabled by base-name (strip "_2", "_3"...)
+	// Helper: enabled by plugin type (any instance: "Delay", "Delay_2", ...)
 	isEnabledBase := func(base string) bool {
 		base = strings.TrimSpace(base)
 		if base == "" {
@@ -97,7 +99,7 @@ func HumanizeFromDumpProgram(raw string) (num, name, amp, fx string) {
 			if !v {
 				continue
 			}
-			if strings.EqualFold(stripInstanceSuffix(k), base) {
+			if strings.EqualFold(stompbox.PluginType(k), base) {
 				return true
 			}
 		}
@@ -116,10 +118,8 @@ func HumanizeFromDumpProgram(raw string) (num, name, amp, fx string) {
 	hasDly := isEnabledBase("Delay")
 	hasRev := isEnabledBase("Reverb") || isEnabledBase("ConvoReverb")
 	hasEQ := isEnabledBase("EQ-7") || isEnabledBase("BEQ-7") || isEnabledBase("HighLow") || isEnabledBase("EQ")
-
-	// NAM/CAB are special: they appear as "NAM" and "Cabinet"
-	hasNAM := enabled["NAM"]
-	hasCAB := enabled["Cabinet"]
+	hasNAM := isEnabledBase("NAM")
+	hasCAB := isEnabledBase("Cabinet")
 
 	if hasGate {
 		tokens = append(tokens, "GATE")
@@ -357,27 +357,6 @@ func filterParts(in []string, keep func(string) bool) []string {
 // Small helpers
 // -------------------------
 
-var rePresetNumName = regexp.MustCompile(`^\s*(\d+)\s*[_\-\s]+\s*(.+?)\s*$`)
-
-func splitPresetNumName(token string) (num, name string) {
-	t := strings.TrimSpace(token)
-	t = strings.Trim(t, "\"")
-	if t == "" {
-		return "", ""
-	}
-
-	if m := rePresetNumName.FindStringSubmatch(t); len(m) == 3 {
-		num = strings.TrimSpace(m[1])
-		name = strings.TrimSpace(m[2])
-	} else {
-		name = t
-	}
-
-	name = strings.ReplaceAll(name, "_", " ")
-	name = strings.TrimSpace(name)
-	return num, name
-}
-
 func extractQuoted(line string) string {
 	i := strings.IndexByte(line, '"')
 	if i < 0 {
@@ -389,32 +368,3 @@ func extractQuoted(line string) string {
 	}
 	return strings.TrimSpace(line[i+1 : j])
 }
-
-func stripInstanceSuffix(block string) string {
-	b := strings.TrimSpace(block)
-	if b == "" {
-		return ""
-	}
-	if strings.Contains(b, "_") {
-		parts := strings.Split(b, "_")
-		if len(parts) >= 2 {
-			last := parts[len(parts)-1]
-			if isAllDigits(last) {
-				return strings.Join(parts[:len(parts)-1], "_")
-			}
-		}
-	}
-	return b
-}
-
-func isAllDigits(s string) bool {
-	if s == "" {
-		return false
-	}
-	for _, r := range s {
-		if r < '0' || r > '9' {
-			return false
-		}
-	}
-	return true
-}
@@ -7,8 +7,8 @@ import (
 	"os/exec"
 	"strings"
 	"sync"
-	"time"
 
+	"github.com/alscos/Namnesis/internal/statehub"
 	"go.bug.st/serial"
 )
 
@@ -19,10 +19,15 @@ type OLEDSerial struct {
 	portName string
 	baud     int
 
-	port serial.Port
-	last string // last committed payload (normalized)
+	port    serial.Port
+	last    string // last committed payload (normalized)
+	offline bool   // Stompbox down: hold the offline screen
+	lastRaw string // last Dump Program painted or held while offline
 }
 
+// offlinePayload replaces the preset screen while Stompbox is unreachable.
+var offlinePayload = formatOLEDLines("", "ENGINE OFFLINE", "", "")
+
 // NewOLEDSerial creates the bridge. portName can be "/dev/ttyNAMNESIS_OLED" (recommended via udev).
 func NewOLEDSerial(portName string, baud int) *OLEDSerial {
 	if baud <= 0 {
@@ -34,70 +39,86 @@ func NewOLEDSerial(portName string, baud int) *OLEDSerial {
 	}
 }
 
-// Start polls dump() on an interval, humanizes it, and writes it to Arduino.
-// dump should be something like: sb.DumpProgram
-func (o *OLEDSerial) Start(ctx context.Context, dump func() (string, error), interval time.Duration) {
-	if interval <= 0 {
-		interval = 400 * time.Millisecond
-	}
-
-	t := time.NewTicker(interval)
-	defer t.Stop()
-
+// Start paints every program snapshot published by the state hub until ctx
+// is done or updates is closed. Subscribe passively (Hub.Subscribe(false)):
+// the front panel should not keep the hub in live mode on its own.
+func (o *OLEDSerial) Start(ctx context.Context, updates <-chan *statehub.Snapshot) {
+	defer o.Close()
 	for {
 		select {
 		case <-ctx.Done():
-			o.Close()
 			return
-		case <-t.C:
-			raw, err := dump()
-			if err != nil {
-				continue
-			}
-
-			num, name, amp, fx := HumanizeFromDumpProgram(raw)
-			if num == "" && name == "" && amp == "" && fx == "" {
-				continue
-			}
-
-			payload := formatOLEDLines(num, name, amp, fx)
-			if !o.shouldSend(payload) {
-				continue
-			}
-
-			if err := o.send(payload); err != nil {
-				// Important: without this we don't see permission/open failures.
-				log.Printf("oled: send failed (%s): %v", o.portName, err)
-				o.dropPort()
+		case snap, ok := <-updates:
+			if !ok {
+				return
 			}
+			o.paint(snap.Raw)
 		}
 	}
 }
 
-func (o *OLEDSerial) Close() {
+// paint humanizes a Dump Program and sends it unless Stompbox is offline.
+func (o *OLEDSerial) paint(raw string) {
 	o.mu.Lock()
 	defer o.mu.Unlock()
-	if o.port != nil {
-		_ = o.port.Close()
-		o.port = nil
+	o.lastRaw = raw
+	o.paintLocked()
+}
+
+// paintLocked sends the screen for lastRaw. The offline check and the send
+// happen under one lock, so a preset screen can't overwrite "ENGINE OFFLINE"
+// set meanwhile.
+func (o *OLEDSerial) paintLocked() {
+	if o.offline {
+		return
+	}
+	num, name, amp, fx := HumanizeFromDumpProgram(o.lastRaw)
+	if num == "" && name == "" && amp == "" && fx == "" {
+		return
 	}
+	o.commitLocked(formatOLEDLines(num, name, amp, fx))
 }
 
-func (o *OLEDSerial) shouldSend(payload string) bool {
-	n := normalizePayload(payload)
+// SetEngineUp shows "ENGINE OFFLINE" while Stompbox is down. When it comes
+// back the last program is repainted; the hub publishes a newer one if the
+// program changed meanwhile.
+func (o *OLEDSerial) SetEngineUp(up bool) {
 	o.mu.Lock()
 	defer o.mu.Unlock()
+	o.offline = !up
+	if !up {
+		o.commitLocked(offlinePayload)
+		return
+	}
+	if o.lastRaw != "" {
+		o.paintLocked()
+	}
+}
+
+// commitLocked sends payload unless it is what the display already shows.
+func (o *OLEDSerial) commitLocked(payload string) {
+	n := normalizePayload(payload)
 	if n == o.last {
-		return false
+		return
 	}
 	o.last = n
-	return true
+	if err := o.sendLocked(payload); err != nil {
+		// Important: without this we don't see permission/open failures.
+		log.Printf("oled: send failed (%s): %v", o.portName, err)
+		o.dropPort()
+	}
 }
 
-func (o *OLEDSerial) send(payload string) error {
+func (o *OLEDSerial) Close() {
 	o.mu.Lock()
 	defer o.mu.Unlock()
+	if o.port != nil {
+		_ = o.port.Close()
+		o.port = nil
+	}
+}
 
+func (o *OLEDSerial) sendLocked(payload string) error {
 	if o.port == nil {
 		mode := &serial.Mode{BaudRate: o.baud}
 		p, err := serial.Open(o.portName, mode)
@@ -0,0 +1,263 @@
+// Package presethistory keeps versions of preset program scripts on disk.
+//
+// Stompbox overwrites a preset on SavePreset and forgets it on DeletePreset,
+// so the gateway records the script of every save, save-as, delete and
+// restore (and the stored content the first time a preset is loaded). A
+// preset whose newest version is a delete is in the trash.
+//
+// Layout: <dir>/<preset>/<seq>.json, one file per version, seq counting
+// from 1 per preset.
+package presethistory
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/url"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Actions that produce a version.
+const (
+	ActionLoad     = "load"     // stored content seen when the preset was loaded
+	ActionObserved = "observed" // program seen at startup; may carry unsaved edits
+	ActionSave     = "save"     // SavePreset over the same name
+	ActionSaveAs   = "save-as"  // SavePreset under a new name
+	ActionDelete   = "delete"   // content at DeletePreset time
+	ActionRestore  = "restore"  // an older version saved back
+)
+
+// ErrNotFound is returned for an unknown preset or version.
+var ErrNotFound = errors.New("version not found")
+
+// Version describes one stored script.
+type Version struct {
+	Preset string    `json:"preset"`
+	Seq    int       `json:"seq"`
+	Action string    `json:"action"`
+	Time   time.Time `json:"time"`
+	Bytes  int       `json:"bytes"`
+	// From is the version a restore re-applied.
+	From int `json:"from,omitempty"`
+}
+
+type record struct {
+	Version
+	Script string `json:"script"`
+}
+
+// Store is safe for concurrent use.
+type Store struct {
+	dir string
+	// keep caps versions per preset; the oldest are pruned (0 = keep all).
+	keep int
+
+	mu sync.Mutex
+}
+
+// Open creates dir if needed.
+func Open(dir string, keep int) (*Store, error) {
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return nil, err
+	}
+	return &Store{dir: dir, keep: keep}, nil
+}
+
+// Record stores script as the newest version of preset. A load, save or
+// observation whose script matches the newest version is not stored again
+// (a load or save still is after an observation); the existing version is
+// returned with added=false.
+func (s *Store) Record(preset, action, script string, from int) (v Version, added bool, err error) {
+	if preset == "" {
+		return Version{}, false, errors.New("empty preset name")
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	seqs, err := s.seqs(preset)
+	if err != nil {
+		return Version{}, false, err
+	}
+	next := 1
+	if n := len(seqs); n > 0 {
+		last, err := s.read(preset, seqs[n-1])
+		if err != nil {
+			return Version{}, false, err
+		}
+		var dup bool
+		switch action {
+		case ActionLoad, ActionSave:
+			// An observed version doesn't vouch for the stored content.
+			dup = last.Action != ActionDelete && last.Action != ActionObserved
+		case ActionObserved:
+			dup = last.Action != ActionDelete
+		}
+		if dup && last.Script == script {
+			return last.Version, false, nil
+		}
+		next = seqs[n-1] + 1
+	}
+
+	rec := record{
+		Version: Version{Preset: preset, Seq: next, Action: action, Time: time.Now().UTC(), Bytes: len(script), From: from},
+		Script:  script,
+	}
+	if err := s.write(rec); err != nil {
+		return Version{}, false, err
+	}
+	if s.keep > 0 && len(seqs)+1 > s.keep {
+		for _, seq := range seqs[:len(seqs)+1-s.keep] {
+			_ = os.Remove(s.path(preset, seq))
+		}
+	}
+	return rec.Version, true, nil
+}
+
+// List returns the versions of preset, newest first.
+func (s *Store) List(preset string) ([]Version, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	seqs, err := s.seqs(preset)
+	if err != nil {
+		return nil, err
+	}
+	out := make([]Version, 0, len(seqs))
+	for i := len(seqs) - 1; i >= 0; i-- {
+		rec, err := s.read(preset, seqs[i])
+		if err != nil {
+			return nil, err
+		}
+		out = append(out, rec.Version)
+	}
+	return out, nil
+}
+
+// Get returns one version and its script.
+func (s *Store) Get(preset string, seq int) (Version, string, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	rec, err := s.read(preset, seq)
+	if err != nil {
+		return Version{}, "", err
+	}
+	return rec.Version, rec.Script, nil
+}
+
+// Latest returns the newest version of preset.
+func (s *Store) Latest(preset string) (Version, string, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	seqs, err := s.seqs(preset)
+	if err != nil {
+		return Version{}, "", err
+	}
+	if len(seqs) == 0 {
+		return Version{}, "", ErrNotFound
+	}
+	rec, err := s.read(preset, seqs[len(seqs)-1])
+	if err != nil {
+		return Version{}, "", err
+	}
+	return rec.Version, rec.Script, nil
+}
+
+// Trash returns the delete versions of presets not saved since, newest first.
+func (s *Store) Trash() ([]Version, error) {
+	entries, err := os.ReadDir(s.dir)
+	if err != nil {
+		return nil, err
+	}
+	out := []Version{}
+	for _, e := range entries {
+		if !e.IsDir() {
+			continue
+		}
+		preset, err := url.PathUnescape(e.Name())
+		if err != nil {
+			continue
+		}
+		v, _, err := s.Latest(preset)
+		if err != nil {
+			if errors.Is(err, ErrNotFound) {
+				continue
+			}
+			return nil, err
+		}
+		if v.Action == ActionDelete {
+			out = append(out, v)
+		}
+	}
+	sort.Slice(out, func(i, j int) bool { return out[i].Time.After(out[j].Time) })
+	return out, nil
+}
+
+// presetDir escapes the name so it is always one path element.
+func (s *Store) presetDir(preset string) string {
+	name := url.PathEscape(preset)
+	if name == "." || name == ".." {
+		name = strings.ReplaceAll(name, ".", "%2E")
+	}
+	return filepath.Join(s.dir, name)
+}
+
+func (s *Store) path(preset string, seq int) string {
+	return filepath.Join(s.presetDir(preset), fmt.Sprintf("%06d.json", seq))
+}
+
+// seqs lists the stored versions of preset in ascending order.
+func (s *Store) seqs(preset string) ([]int, error) {
+	entries, err := os.ReadDir(s.presetDir(preset))
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	var seqs []int
+	for _, e := range entries {
+		n, err := strconv.Atoi(strings.TrimSuffix(e.Name(), ".json"))
+		if err == nil && n > 0 && strings.HasSuffix(e.Name(), ".json") {
+			seqs = append(seqs, n)
+		}
+	}
+	sort.Ints(seqs)
+	return seqs, nil
+}
+
+func (s *Store) read(preset string, seq int) (record, error) {
+	b, err := os.ReadFile(s.path(preset, seq))
+	if errors.Is(err, os.ErrNotExist) {
+		return record{}, ErrNotFound
+	}
+	if err != nil {
+		return record{}, err
+	}
+	var rec record
+	if err := json.Unmarshal(b, &rec); err != nil {
+		return record{}, fmt.Errorf("%s: %w", s.path(preset, seq), err)
+	}
+	return rec, nil
+}
+
+// write goes through a temp file so a crash never leaves a torn version.
+func (s *Store) write(rec record) error {
+	if err := os.MkdirAll(s.presetDir(rec.Preset), 0o755); err != nil {
+		return err
+	}
+	b, err := json.MarshalIndent(rec, "", "  ")
+	if err != nil {
+		return err
+	}
+	path := s.path(rec.Preset, rec.Seq)
+	tmp := path + ".tmp"
+	if err := os.WriteFile(tmp, b, 0o644); err != nil {
+		return err
+	}
+	return os.Rename(tmp, path)
+}
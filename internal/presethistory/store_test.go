@@ -0,0 +1,93 @@
+package presethistory
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestRecordListAndPrune(t *testing.T) {
+	s, err := Open(t.TempDir(), 3)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	v, added, err := s.Record("01 clean", ActionLoad, "SetPreset 01 clean\r\n", 0)
+	if err != nil || !added || v.Seq != 1 {
+		t.Fatalf("first record: %+v added=%v err=%v", v, added, err)
+	}
+	// Loading or saving the same content again adds nothing.
+	if v, added, _ := s.Record("01 clean", ActionSave, "SetPreset 01 clean\r\n", 0); added || v.Seq != 1 {
+		t.Fatalf("duplicate save stored: %+v", v)
+	}
+	for i, script := range []string{"a", "b", "c"} {
+		v, added, err := s.Record("01 clean", ActionSave, script, 0)
+		if err != nil || !added || v.Seq != i+2 {
+			t.Fatalf("save %s: %+v added=%v err=%v", script, v, added, err)
+		}
+	}
+
+	list, err := s.List("01 clean")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(list) != 3 || list[0].Seq != 4 || list[2].Seq != 2 {
+		t.Fatalf("list after prune: %+v", list)
+	}
+	if _, _, err := s.Get("01 clean", 1); !errors.Is(err, ErrNotFound) {
+		t.Fatalf("pruned version: err=%v", err)
+	}
+	if _, script, err := s.Get("01 clean", 3); err != nil || script != "b" {
+		t.Fatalf("Get 3: %q, %v", script, err)
+	}
+	if list, err := s.List("missing"); err != nil || len(list) != 0 {
+		t.Fatalf("unknown preset: %v, %v", list, err)
+	}
+}
+
+func TestTrash(t *testing.T) {
+	s, err := Open(t.TempDir(), 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	mustRecord := func(preset, action, script string) {
+		t.Helper()
+		if _, _, err := s.Record(preset, action, script, 0); err != nil {
+			t.Fatal(err)
+		}
+	}
+	mustRecord("lead", ActionSave, "x")
+	mustRecord("lead", ActionDelete, "x")
+	mustRecord("clean", ActionSave, "y")
+	mustRecord("..", ActionDelete, "z") // escaped, stays inside the store
+
+	trash, err := s.Trash()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(trash) != 2 || trash[0].Preset != ".." || trash[1].Preset != "lead" {
+		t.Fatalf("trash = %+v", trash)
+	}
+
+	// A delete is always recorded; a restore takes the preset out of the trash.
+	mustRecord("lead", ActionRestore, "x")
+	if trash, _ := s.Trash(); len(trash) != 1 {
+		t.Fatalf("trash after restore = %+v", trash)
+	}
+}
+
+func TestObservedDoesNotHideLoad(t *testing.T) {
+	s, err := Open(t.TempDir(), 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, added, _ := s.Record("lead", ActionObserved, "x", 0); !added {
+		t.Fatal("observed version not stored")
+	}
+	if _, added, _ := s.Record("lead", ActionObserved, "x", 0); added {
+		t.Fatal("duplicate observation stored")
+	}
+	// A load of the same script confirms it is the stored content.
+	if v, added, _ := s.Record("lead", ActionLoad, "x", 0); !added || v.Seq != 2 {
+		t.Fatalf("load after observation: %+v added=%v", v, added)
+	}
+}
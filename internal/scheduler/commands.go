@@ -0,0 +1,101 @@
+package scheduler
+
+import (
+	"context"
+	"strings"
+
+	"github.com/alscos/Namnesis/internal/stompbox"
+)
+
+// Keys that serialize commands on shared Stompbox state.
+const (
+	keyPresets = "presets" // preset store: save/delete
+)
+
+func pluginKey(plugin string) string { return "plugin:" + strings.TrimSpace(plugin) }
+func chainKey(chain string) string   { return "chain:" + strings.TrimSpace(chain) }
+func slotKey(slot string) string     { return "slot:" + strings.TrimSpace(slot) }
+
+func (s *Scheduler) read(ctx context.Context, fn func(context.Context) (string, error)) (string, error) {
+	var out string
+	err := s.Do(ctx, PriorityBulk, "", func(ctx context.Context) error {
+		var err error
+		out, err = fn(ctx)
+		return err
+	})
+	return out, err
+}
+
+func (s *Scheduler) DumpConfigCtx(ctx context.Context) (string, error) {
+	return s.read(ctx, s.sb.DumpConfigCtx)
+}
+
+func (s *Scheduler) DumpConfigRawCtx(ctx context.Context) (*stompbox.DumpConfigParsed, string, error) {
+	var (
+		out *stompbox.DumpConfigParsed
+		raw string
+	)
+	err := s.Do(ctx, PriorityBulk, "", func(ctx context.Context) error {
+		var err error
+		out, raw, err = s.sb.DumpConfigRawCtx(ctx)
+		return err
+	})
+	return out, raw, err
+}
+
+func (s *Scheduler) DumpProgramCtx(ctx context.Context) (string, error) {
+	return s.read(ctx, s.sb.DumpProgramCtx)
+}
+
+func (s *Scheduler) ListPresetsCtx(ctx context.Context) (string, error) {
+	return s.read(ctx, s.sb.ListPresetsCtx)
+}
+
+// SetParamCtx treats Enabled toggles as performance operations; other params are edits.
+func (s *Scheduler) SetParamCtx(ctx context.Context, plugin, param, value string) error {
+	prio := PriorityEdit
+	if strings.TrimSpace(param) == "Enabled" {
+		prio = PriorityPerformance
+	}
+	return s.Do(ctx, prio, pluginKey(plugin), func(ctx context.Context) error {
+		return s.sb.SetParamCtx(ctx, plugin, param, value)
+	})
+}
+
+// LoadPresetCtx replaces the whole program, so it waits for the writes queued
+// before it and holds back those queued after (they target the new preset).
+func (s *Scheduler) LoadPresetCtx(ctx context.Context, name string) error {
+	return s.Do(ctx, PriorityPerformance, KeyAll, func(ctx context.Context) error {
+		return s.sb.LoadPresetCtx(ctx, name)
+	})
+}
+
+func (s *Scheduler) SavePresetCtx(ctx context.Context, name string) error {
+	return s.Do(ctx, PriorityEdit, keyPresets, func(ctx context.Context) error {
+		return s.sb.SavePresetCtx(ctx, name)
+	})
+}
+
+func (s *Scheduler) DeletePresetCtx(ctx context.Context, name string) error {
+	return s.Do(ctx, PriorityEdit, keyPresets, func(ctx context.Context) error {
+		return s.sb.DeletePresetCtx(ctx, name)
+	})
+}
+
+func (s *Scheduler) SetChainCtx(ctx context.Context, chain string, plugins []string) error {
+	return s.Do(ctx, PriorityEdit, chainKey(chain), func(ctx context.Context) error {
+		return s.sb.SetChainCtx(ctx, chain, plugins)
+	})
+}
+
+func (s *Scheduler) SetPluginSlotCtx(ctx context.Context, slot, plugin string) error {
+	return s.Do(ctx, PriorityEdit, slotKey(slot), func(ctx context.Context) error {
+		return s.sb.SetPluginSlotCtx(ctx, slot, plugin)
+	})
+}
+
+func (s *Scheduler) ReleasePluginCtx(ctx context.Context, plugin string) error {
+	return s.Do(ctx, PriorityEdit, pluginKey(plugin), func(ctx context.Context) error {
+		return s.sb.ReleasePluginCtx(ctx, plugin)
+	})
+}
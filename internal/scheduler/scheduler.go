@@ -0,0 +1,287 @@
+// Package scheduler orders Stompbox commands coming from HTTP handlers and the
+// OLED bridge before they reach stompbox.Client.
+//
+// Performance operations (preset loads, enable toggles) go ahead of edits,
+// and edits go ahead of bulk reads (dumps, preset lists). A command queued
+// longer than AgeStep moves up one class per step, so bulk reads still run
+// under a steady stream of edits. Commands that share a key (the plugin
+// instance for writes) always run in submission order, a KeyAll command is
+// ordered against every keyed one, and at most MaxInFlight commands are
+// handed to the client at once.
+package scheduler
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/alscos/Namnesis/internal/stompbox"
+)
+
+// Priority classes, lowest first.
+type Priority int
+
+const (
+	PriorityBulk        Priority = iota // Dump Config/Program, List Presets
+	PriorityEdit                        // param, chain, slot and preset-file edits
+	PriorityPerformance                 // LoadPreset, Enabled toggles
+
+	numPriorities = 3
+)
+
+// KeyAll orders a command against every keyed command: it waits for the
+// keyed commands submitted before it and holds back those submitted after.
+const KeyAll = "*"
+
+// defaultAgeStep is used when Scheduler.AgeStep is zero.
+const defaultAgeStep = time.Second
+
+func (p Priority) String() string {
+	switch p {
+	case PriorityPerformance:
+		return "performance"
+	case PriorityEdit:
+		return "edit"
+	default:
+		return "bulk"
+	}
+}
+
+type task struct {
+	seq      uint64
+	prio     Priority
+	key      string
+	enqueued time.Time
+	start    chan struct{} // closed when the task may run
+}
+
+type classStats struct {
+	dispatched uint64
+	totalWait  time.Duration
+	maxWait    time.Duration
+	lastWait   time.Duration
+}
+
+// Scheduler wraps a client. Its command methods mirror stompbox.Client's Ctx API.
+type Scheduler struct {
+	sb          *stompbox.Client
+	maxInFlight int
+
+	// AgeStep is how long a queued command waits before it competes as the
+	// next class up (zero = 1s). It bounds how long bulk reads starve.
+	AgeStep time.Duration
+
+	mu          sync.Mutex
+	seq         uint64
+	queue       []*task // submission order
+	running     int
+	runningKeys map[string]int
+	stats       [numPriorities]classStats
+}
+
+// New returns a scheduler in front of sb running at most maxInFlight commands
+// at once (values < 1 mean 1). The client has a single session, so 1 keeps
+// ordering strict; higher values only let the next commands queue inside it.
+func New(sb *stompbox.Client, maxInFlight int) *Scheduler {
+	if maxInFlight < 1 {
+		maxInFlight = 1
+	}
+	return &Scheduler{
+		sb:          sb,
+		maxInFlight: maxInFlight,
+		runningKeys: make(map[string]int),
+	}
+}
+
+// Client returns the underlying client (health, tracer).
+func (s *Scheduler) Client() *stompbox.Client { return s.sb }
+
+// Do runs fn once the scheduler grants it a slot. key orders commands that
+// must not overtake each other ("" = unordered). If ctx ends while the
+// command is still queued, it is dropped and ctx.Err() returned.
+func (s *Scheduler) Do(ctx context.Context, prio Priority, key string, fn func(context.Context) error) error {
+	t, err := s.acquire(ctx, prio, key)
+	if err != nil {
+		return err
+	}
+	defer s.release(t)
+	return fn(ctx)
+}
+
+func (s *Scheduler) acquire(ctx context.Context, prio Priority, key string) (*task, error) {
+	s.mu.Lock()
+	s.seq++
+	t := &task{
+		seq:      s.seq,
+		prio:     prio,
+		key:      key,
+		enqueued: time.Now(),
+		start:    make(chan struct{}),
+	}
+	s.queue = append(s.queue, t)
+	s.dispatchLocked()
+	s.mu.Unlock()
+
+	select {
+	case <-t.start:
+		return t, nil
+	case <-ctx.Done():
+		s.mu.Lock()
+		defer s.mu.Unlock()
+		select {
+		case <-t.start:
+			// Granted while we were giving up: hand the slot back.
+			s.finishLocked(t)
+		default:
+			s.removeLocked(t)
+		}
+		s.dispatchLocked()
+		return nil, ctx.Err()
+	}
+}
+
+func (s *Scheduler) release(t *task) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.finishLocked(t)
+	s.dispatchLocked()
+}
+
+func (s *Scheduler) finishLocked(t *task) {
+	s.running--
+	if t.key != "" {
+		if s.runningKeys[t.key]--; s.runningKeys[t.key] <= 0 {
+			delete(s.runningKeys, t.key)
+		}
+	}
+}
+
+func (s *Scheduler) removeLocked(t *task) {
+	for i, q := range s.queue {
+		if q == t {
+			s.queue = append(s.queue[:i], s.queue[i+1:]...)
+			return
+		}
+	}
+}
+
+// dispatchLocked starts queued tasks while slots are free, highest (aged)
+// priority first, oldest first within a class. A task is eligible only if no
+// earlier task with its key is queued or running, which keeps per-key
+// submission order; KeyAll counts as every key.
+func (s *Scheduler) dispatchLocked() {
+	now := time.Now()
+	for s.running < s.maxInFlight {
+		best, bestPrio := -1, Priority(0)
+		blocked := make(map[string]bool)
+		keyed, all := len(s.runningKeys) > 0, s.runningKeys[KeyAll] > 0
+		for i, t := range s.queue {
+			var eligible bool
+			switch t.key {
+			case "":
+				eligible = true
+			case KeyAll:
+				eligible = !keyed
+				all = true
+			default:
+				eligible = !all && s.runningKeys[t.key] == 0 && !blocked[t.key]
+				blocked[t.key] = true
+			}
+			if t.key != "" {
+				keyed = true
+			}
+			if !eligible {
+				continue
+			}
+			if p := s.agedLocked(t, now); best < 0 || p > bestPrio {
+				best, bestPrio = i, p
+			}
+		}
+		if best < 0 {
+			return
+		}
+
+		t := s.queue[best]
+		s.queue = append(s.queue[:best], s.queue[best+1:]...)
+		s.running++
+		if t.key != "" {
+			s.runningKeys[t.key]++
+		}
+
+		wait := time.Since(t.enqueued)
+		st := &s.stats[t.prio]
+		st.dispatched++
+		st.totalWait += wait
+		st.lastWait = wait
+		if wait > st.maxWait {
+			st.maxWait = wait
+		}
+		close(t.start)
+	}
+}
+
+// agedLocked is t's priority raised one class per AgeStep it has waited.
+func (s *Scheduler) agedLocked(t *task, now time.Time) Priority {
+	step := s.AgeStep
+	if step <= 0 {
+		step = defaultAgeStep
+	}
+	p := t.prio + Priority(now.Sub(t.enqueued)/step)
+	return min(p, PriorityPerformance)
+}
+
+// ClassStats describes one priority class.
+type ClassStats struct {
+	Queued     int     `json:"queued"`
+	Dispatched uint64  `json:"dispatched"`
+	AvgWaitMs  float64 `json:"avgWaitMs"`
+	MaxWaitMs  float64 `json:"maxWaitMs"`
+	LastWaitMs float64 `json:"lastWaitMs"`
+}
+
+// Stats is the introspection snapshot served by /api/debug/scheduler.
+type Stats struct {
+	MaxInFlight int                   `json:"maxInFlight"`
+	Running     int                   `json:"running"`
+	Queued      int                   `json:"queued"`
+	OldestWait  string                `json:"oldestWait,omitempty"`
+	Classes     map[string]ClassStats `json:"classes"`
+}
+
+// Stats returns queue depth and wait times per priority class.
+func (s *Scheduler) Stats() Stats {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	out := Stats{
+		MaxInFlight: s.maxInFlight,
+		Running:     s.running,
+		Queued:      len(s.queue),
+		Classes:     make(map[string]ClassStats, numPriorities),
+	}
+	var queued [numPriorities]int
+	for _, t := range s.queue {
+		queued[t.prio]++
+	}
+	if len(s.queue) > 0 {
+		out.OldestWait = time.Since(s.queue[0].enqueued).String()
+	}
+	for p := Priority(0); p < numPriorities; p++ {
+		st := s.stats[p]
+		cs := ClassStats{
+			Queued:     queued[p],
+			Dispatched: st.dispatched,
+			MaxWaitMs:  ms(st.maxWait),
+			LastWaitMs: ms(st.lastWait),
+		}
+		if st.dispatched > 0 {
+			cs.AvgWaitMs = ms(st.totalWait / time.Duration(st.dispatched))
+		}
+		out.Classes[p.String()] = cs
+	}
+	return out
+}
+
+func ms(d time.Duration) float64 {
+	return float64(d.Microseconds()) / 1000
+}
@@ -0,0 +1,151 @@
+package scheduler
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+)
+
+// gate blocks the single slot so the test can queue work behind it.
+func gate(t *testing.T, s *Scheduler) (release func()) {
+	t.Helper()
+	started := make(chan struct{})
+	done := make(chan struct{})
+	go func() {
+		_ = s.Do(context.Background(), PriorityBulk, "", func(context.Context) error {
+			close(started)
+			<-done
+			return nil
+		})
+	}()
+	<-started
+	return func() { close(done) }
+}
+
+func waitQueued(t *testing.T, s *Scheduler, n int) {
+	t.Helper()
+	deadline := time.Now().Add(time.Second)
+	for s.Stats().Queued < n {
+		if time.Now().After(deadline) {
+			t.Fatalf("timed out waiting for %d queued tasks", n)
+		}
+		time.Sleep(time.Millisecond)
+	}
+}
+
+// recorder submits named tasks and records the order they run in.
+type recorder struct {
+	s     *Scheduler
+	mu    sync.Mutex
+	order []string
+	wg    sync.WaitGroup
+}
+
+func (r *recorder) submit(name string, prio Priority, key string) {
+	r.wg.Add(1)
+	go func() {
+		defer r.wg.Done()
+		_ = r.s.Do(context.Background(), prio, key, func(context.Context) error {
+			r.mu.Lock()
+			r.order = append(r.order, name)
+			r.mu.Unlock()
+			return nil
+		})
+	}()
+}
+
+func (r *recorder) check(t *testing.T, want ...string) {
+	t.Helper()
+	r.wg.Wait()
+	if len(r.order) != len(want) {
+		t.Fatalf("order = %v; want %v", r.order, want)
+	}
+	for i := range want {
+		if r.order[i] != want[i] {
+			t.Fatalf("order = %v; want %v", r.order, want)
+		}
+	}
+}
+
+func TestPriorityAndKeyOrder(t *testing.T) {
+	s := New(nil, 1)
+	release := gate(t, s)
+	rec := &recorder{s: s}
+	submit := rec.submit
+
+	submit("dump", PriorityBulk, "")
+	waitQueued(t, s, 1)
+	submit("mix", PriorityEdit, "plugin:Delay_2")
+	waitQueued(t, s, 2)
+	// Same plugin as "mix": must not overtake it despite higher priority.
+	submit("enable-delay", PriorityPerformance, "plugin:Delay_2")
+	waitQueued(t, s, 3)
+	submit("load", PriorityPerformance, "")
+	waitQueued(t, s, 4)
+
+	release()
+	rec.check(t, "load", "mix", "enable-delay", "dump")
+
+	st := s.Stats()
+	if st.Classes["performance"].Dispatched != 2 || st.Queued != 0 || st.Running != 0 {
+		t.Fatalf("unexpected stats: %+v", st)
+	}
+}
+
+func TestKeyAllIsABarrier(t *testing.T) {
+	s := New(nil, 1)
+	release := gate(t, s)
+	rec := &recorder{s: s}
+
+	rec.submit("mix", PriorityEdit, "plugin:Delay_2")
+	waitQueued(t, s, 1)
+	// Must not overtake "mix": the edit belongs to the old preset.
+	rec.submit("load", PriorityPerformance, KeyAll)
+	waitQueued(t, s, 2)
+	// Must not overtake "load": the toggle belongs to the new preset.
+	rec.submit("enable-boost", PriorityPerformance, "plugin:Boost")
+	waitQueued(t, s, 3)
+	rec.submit("dump", PriorityBulk, "")
+	waitQueued(t, s, 4)
+
+	release()
+	rec.check(t, "mix", "load", "enable-boost", "dump")
+}
+
+func TestBulkAges(t *testing.T) {
+	s := New(nil, 1)
+	s.AgeStep = 10 * time.Millisecond
+	release := gate(t, s)
+	rec := &recorder{s: s}
+
+	rec.submit("dump", PriorityBulk, "")
+	waitQueued(t, s, 1)
+	time.Sleep(25 * time.Millisecond)
+	rec.submit("mix", PriorityEdit, "plugin:Delay_2")
+	waitQueued(t, s, 2)
+
+	release()
+	rec.check(t, "dump", "mix")
+}
+
+func TestCancelWhileQueued(t *testing.T) {
+	s := New(nil, 1)
+	release := gate(t, s)
+	defer release()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+
+	ran := false
+	err := s.Do(ctx, PriorityEdit, "plugin:Boost", func(context.Context) error {
+		ran = true
+		return nil
+	})
+	if err != context.DeadlineExceeded || ran {
+		t.Fatalf("Do = %v (ran=%v); want DeadlineExceeded without running", err, ran)
+	}
+	if q := s.Stats().Queued; q != 0 {
+		t.Fatalf("cancelled task still queued (%d)", q)
+	}
+}
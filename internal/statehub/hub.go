@@ -0,0 +1,316 @@
+// Package statehub polls Dump Program in one place and shares the result
+// with every consumer (OLED bridge, HTTP handlers, event streams), so they
+// all see the same snapshot instead of dumping the program on their own.
+//
+// The poll loop runs at LiveInterval while a live subscriber is connected or
+// shortly after activity (a Get, an Invalidate, a detected change), and at
+// IdleInterval otherwise.
+package statehub
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/alscos/Namnesis/internal/stompbox"
+)
+
+// Snapshot is one parsed Dump Program. It is never modified after creation.
+type Snapshot struct {
+	Raw       string
+	Program   *stompbox.Program
+	FetchedAt time.Time
+	// Revision increases each time the dump content changes.
+	Revision uint64
+}
+
+// Stats is the introspection view served by /api/debug/state-hub.
+type Stats struct {
+	Loaded    bool      `json:"loaded"`
+	Revision  uint64    `json:"revision"`
+	FetchedAt time.Time `json:"fetchedAt,omitempty"`
+	Age       string    `json:"age,omitempty"`
+	Live      bool      `json:"live"`
+	Interval  string    `json:"interval"`
+	Fetches   uint64    `json:"fetches"`
+	Hits      uint64    `json:"hits"`
+	Changes   uint64    `json:"changes"`
+	Watchers  int       `json:"watchers"`
+	LastError string    `json:"lastError,omitempty"`
+}
+
+// Hub holds the latest snapshot. The zero value is not usable; call New.
+type Hub struct {
+	// LiveInterval and IdleInterval are the poll periods with and without
+	// someone actively watching.
+	LiveInterval time.Duration
+	IdleInterval time.Duration
+	// LiveFor keeps the loop at LiveInterval this long after the last
+	// activity or change.
+	LiveFor time.Duration
+	// MaxAge is how old a snapshot Get may return without fetching.
+	MaxAge time.Duration
+
+	fetch func(context.Context) (string, error)
+	wake  chan struct{}
+
+	mu          sync.Mutex
+	cur         *Snapshot
+	dirtySince  time.Time // zero unless Invalidate was called after cur was fetched
+	inflight    *call
+	subs        map[*sub]struct{}
+	liveSubs    int
+	activeUntil time.Time
+	fetches     uint64
+	hits        uint64
+	changes     uint64
+	lastErr     string
+}
+
+type sub struct {
+	ch   chan *Snapshot
+	live bool
+}
+
+type call struct {
+	started time.Time
+	done    chan struct{}
+	snap    *Snapshot
+	err     error
+}
+
+// New returns a hub filled by fetch (typically Scheduler.DumpProgramCtx).
+func New(fetch func(context.Context) (string, error)) *Hub {
+	return &Hub{
+		LiveInterval: 250 * time.Millisecond,
+		IdleInterval: time.Second,
+		LiveFor:      10 * time.Second,
+		MaxAge:       500 * time.Millisecond,
+		fetch:        fetch,
+		wake:         make(chan struct{}, 1),
+		subs:         make(map[*sub]struct{}),
+	}
+}
+
+// Current returns the latest snapshot without fetching (nil before the first one).
+func (h *Hub) Current() *Snapshot {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	return h.cur
+}
+
+// Get returns a snapshot no older than MaxAge and taken after the last
+// Invalidate, fetching one if needed.
+func (h *Hub) Get(ctx context.Context) (*Snapshot, error) {
+	h.mu.Lock()
+	h.touchLocked()
+	if s := h.cur; s != nil && h.dirtySince.IsZero() && time.Since(s.FetchedAt) < h.MaxAge {
+		h.hits++
+		h.mu.Unlock()
+		return s, nil
+	}
+	notBefore := h.dirtySince
+	h.mu.Unlock()
+	return h.load(ctx, notBefore)
+}
+
+// Refresh fetches a snapshot started after this call, e.g. to read back a
+// change just made.
+func (h *Hub) Refresh(ctx context.Context) (*Snapshot, error) {
+	h.mu.Lock()
+	h.touchLocked()
+	h.mu.Unlock()
+	return h.load(ctx, time.Now())
+}
+
+// Invalidate makes the next Get fetch again. Call it after any command that
+// changes the program.
+func (h *Hub) Invalidate() {
+	h.mu.Lock()
+	h.dirtySince = time.Now()
+	h.touchLocked()
+	h.mu.Unlock()
+}
+
+// Subscribe returns a channel receiving every new revision, starting with
+// the current snapshot if there is one. Only the latest undelivered snapshot
+// is kept. Live subscribers keep the poll loop at LiveInterval.
+func (h *Hub) Subscribe(live bool) (<-chan *Snapshot, func()) {
+	sb := &sub{ch: make(chan *Snapshot, 1), live: live}
+	h.mu.Lock()
+	h.subs[sb] = struct{}{}
+	if live {
+		h.liveSubs++
+		h.touchLocked()
+	}
+	if h.cur != nil {
+		sb.ch <- h.cur
+	}
+	h.mu.Unlock()
+
+	var once sync.Once
+	return sb.ch, func() {
+		once.Do(func() {
+			h.mu.Lock()
+			delete(h.subs, sb)
+			if live {
+				h.liveSubs--
+			}
+			close(sb.ch)
+			h.mu.Unlock()
+		})
+	}
+}
+
+// Run polls until ctx is done.
+func (h *Hub) Run(ctx context.Context) {
+	for {
+		t := time.NewTimer(h.interval())
+		select {
+		case <-ctx.Done():
+			t.Stop()
+			return
+		case <-h.wake:
+			// Went live: recompute the interval.
+			t.Stop()
+			continue
+		case <-t.C:
+		}
+		_, _ = h.load(ctx, time.Time{})
+	}
+}
+
+// Stats returns counters and the state of the current snapshot.
+func (h *Hub) Stats() Stats {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	st := Stats{
+		Live:      h.liveLocked(),
+		Interval:  h.intervalLocked().String(),
+		Fetches:   h.fetches,
+		Hits:      h.hits,
+		Changes:   h.changes,
+		Watchers:  len(h.subs),
+		LastError: h.lastErr,
+	}
+	if s := h.cur; s != nil {
+		st.Loaded = true
+		st.Revision = s.Revision
+		st.FetchedAt = s.FetchedAt
+		st.Age = time.Since(s.FetchedAt).Round(time.Millisecond).String()
+	}
+	return st
+}
+
+func (h *Hub) interval() time.Duration {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	return h.intervalLocked()
+}
+
+func (h *Hub) intervalLocked() time.Duration {
+	d := h.IdleInterval
+	if h.liveLocked() {
+		d = h.LiveInterval
+	}
+	return max(d, minInterval)
+}
+
+func (h *Hub) liveLocked() bool {
+	return h.liveSubs > 0 || time.Now().Before(h.activeUntil)
+}
+
+// touchLocked records activity, waking the loop if it was idle.
+func (h *Hub) touchLocked() {
+	wasLive := h.liveLocked()
+	h.activeUntil = time.Now().Add(h.LiveFor)
+	if !wasLive {
+		select {
+		case h.wake <- struct{}{}:
+		default:
+		}
+	}
+}
+
+// minInterval keeps a zero or tiny configured interval from spinning.
+const minInterval = 50 * time.Millisecond
+
+// fetchTimeout bounds a detached fetch.
+const fetchTimeout = 10 * time.Second
+
+// load joins an in-flight fetch started at or after notBefore, or starts one.
+func (h *Hub) load(ctx context.Context, notBefore time.Time) (*Snapshot, error) {
+	for {
+		h.mu.Lock()
+		cl := h.inflight
+		if cl == nil {
+			cl = &call{started: time.Now(), done: make(chan struct{})}
+			h.inflight = cl
+			// Detached from the first caller: others may be waiting on the same result.
+			go h.do(context.WithoutCancel(ctx), cl)
+		}
+		h.mu.Unlock()
+
+		select {
+		case <-cl.done:
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		}
+		if !cl.started.Before(notBefore) {
+			return cl.snap, cl.err
+		}
+		// That fetch began before the state we need; start another.
+	}
+}
+
+func (h *Hub) do(ctx context.Context, cl *call) {
+	ctx, cancel := context.WithTimeout(ctx, fetchTimeout)
+	defer cancel()
+
+	raw, err := h.fetch(ctx)
+	var prog *stompbox.Program
+	if err == nil {
+		prog, err = stompbox.ParseDumpProgram(raw)
+	}
+
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.fetches++
+	h.inflight = nil
+	defer close(cl.done)
+
+	if err != nil {
+		h.lastErr = err.Error()
+		cl.err = err
+		return
+	}
+	h.lastErr = ""
+	if !cl.started.Before(h.dirtySince) {
+		h.dirtySince = time.Time{}
+	}
+
+	if h.cur != nil && h.cur.Raw == raw {
+		// Unchanged: keep the snapshot (and its revision), only its age moves.
+		s := *h.cur
+		s.FetchedAt = time.Now()
+		h.cur = &s
+		cl.snap = h.cur
+		return
+	}
+
+	s := &Snapshot{Raw: raw, Program: prog, FetchedAt: time.Now(), Revision: 1}
+	if h.cur != nil {
+		s.Revision = h.cur.Revision + 1
+		h.changes++
+		h.activeUntil = time.Now().Add(h.LiveFor)
+	}
+	h.cur = s
+	cl.snap = s
+	for sb := range h.subs {
+		select {
+		case <-sb.ch: // drop the undelivered older revision
+		default:
+		}
+		sb.ch <- s
+	}
+}
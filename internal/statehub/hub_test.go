@@ -0,0 +1,106 @@
+package statehub
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+const progA = "SetPreset 01_clean\r\nSetChain FxLoop Delay\r\nSetParam Delay Mix 0.5\r\nEndProgram\r\nOk\r\n"
+const progB = "SetPreset 02_lead\r\nSetChain FxLoop Delay\r\nSetParam Delay Mix 0.5\r\nEndProgram\r\nOk\r\n"
+
+type fakeSource struct {
+	mu    sync.Mutex
+	raw   string
+	err   error
+	calls atomic.Int32
+}
+
+func (f *fakeSource) fetch(ctx context.Context) (string, error) {
+	f.calls.Add(1)
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return f.raw, f.err
+}
+
+func (f *fakeSource) set(raw string, err error) {
+	f.mu.Lock()
+	f.raw, f.err = raw, err
+	f.mu.Unlock()
+}
+
+func TestHubGetAndInvalidate(t *testing.T) {
+	src := &fakeSource{raw: progA}
+	h := New(src.fetch)
+	h.MaxAge = time.Minute
+	ctx := context.Background()
+
+	s, err := h.Get(ctx)
+	if err != nil || s.Revision != 1 || s.Program.ActivePreset != "01_clean" {
+		t.Fatalf("first Get: %+v, %v", s, err)
+	}
+	if _, _ = h.Get(ctx); src.calls.Load() != 1 {
+		t.Fatalf("fresh snapshot refetched: %d calls", src.calls.Load())
+	}
+
+	// Same content: same revision even after an explicit refetch.
+	h.Invalidate()
+	if s, _ := h.Get(ctx); src.calls.Load() != 2 || s.Revision != 1 {
+		t.Fatalf("after Invalidate: calls=%d rev=%d", src.calls.Load(), s.Revision)
+	}
+
+	src.set(progB, nil)
+	s, err = h.Refresh(ctx)
+	if err != nil || s.Revision != 2 || s.Program.ActivePreset != "02_lead" {
+		t.Fatalf("Refresh: %+v, %v", s, err)
+	}
+
+	src.set("", errors.New("down"))
+	if _, err := h.Refresh(ctx); err == nil {
+		t.Fatal("Refresh should report the fetch error")
+	}
+	if st := h.Stats(); st.Revision != 2 || st.LastError != "down" || st.Changes != 1 {
+		t.Fatalf("stats: %+v", st)
+	}
+}
+
+func TestHubSubscribeAndAdaptiveInterval(t *testing.T) {
+	src := &fakeSource{raw: progA}
+	h := New(src.fetch)
+	h.LiveInterval = 60 * time.Millisecond
+	h.IdleInterval = time.Hour
+	h.LiveFor = 0
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	if _, err := h.Get(ctx); err != nil {
+		t.Fatal(err)
+	}
+	if h.Stats().Live {
+		t.Fatal("no watcher and LiveFor=0: hub should be idle")
+	}
+	go h.Run(ctx)
+
+	ch, stop := h.Subscribe(true)
+	defer stop()
+	if s := <-ch; s.Revision != 1 {
+		t.Fatalf("first delivery should be the current snapshot, got rev %d", s.Revision)
+	}
+	if st := h.Stats(); !st.Live || st.Interval != "60ms" {
+		t.Fatalf("live subscriber should switch to LiveInterval: %+v", st)
+	}
+
+	// The live loop picks up the change without any Get.
+	src.set(progB, nil)
+	select {
+	case s := <-ch:
+		if s.Revision != 2 || s.Program.ActivePreset != "02_lead" {
+			t.Fatalf("got rev %d preset %q", s.Revision, s.Program.ActivePreset)
+		}
+	case <-time.After(2 * time.Second):
+		t.Fatal("no revision published")
+	}
+}
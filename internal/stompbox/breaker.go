@@ -0,0 +1,193 @@
+package stompbox
+
+import (
+	"context"
+	"errors"
+	"math/rand/v2"
+	"sync"
+	"time"
+)
+
+// Breaker defaults, used when the corresponding Client field is zero.
+const (
+	defaultBreakerThreshold = 2
+	defaultBackoffMin       = 500 * time.Millisecond
+	defaultBackoffMax       = 15 * time.Second
+)
+
+// Availability is an up/down transition of the Stompbox as judged by the
+// client's circuit breaker.
+type Availability struct {
+	Up    bool      `json:"up"`
+	Since time.Time `json:"since"`
+	Error string    `json:"error,omitempty"` // failure that took it down
+}
+
+// breaker tracks consecutive outage failures (dial/read/write errors and
+// timeouts). Once BreakerThreshold is reached the Stompbox is considered down:
+// commands fail fast with *UnavailableError until the backoff expires, then a
+// single probe command is let through. Any reply, even an Error line, closes
+// the breaker; an outage failure doubles the backoff (with jitter) up to
+// BackoffMax.
+type breaker struct {
+	mu       sync.Mutex
+	failures int
+	down     bool
+	since    time.Time
+	retryAt  time.Time
+	backoff  time.Duration
+	probe    uint64 // token of the probe in flight (0 = none)
+	probes   uint64 // last token handed out
+	lastErr  error
+	subs     map[chan Availability]struct{}
+}
+
+// allow reports whether a command may be sent now. probe is non-zero for
+// the single probe let through while down; pass it back to observe.
+func (c *Client) allow() (probe uint64, err error) {
+	b := &c.brk
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if !b.down {
+		return 0, nil
+	}
+	if b.probe != 0 || time.Now().Before(b.retryAt) {
+		return 0, &UnavailableError{Addr: c.Addr, RetryAt: b.retryAt, Err: b.lastErr}
+	}
+	b.probes++
+	b.probe = b.probes
+	return b.probe, nil
+}
+
+// observe feeds the outcome of a command allowed by allow back into the
+// breaker. While down only the probe counts: any other command was let
+// through before the breaker opened and its outcome is stale.
+func (c *Client) observe(probe uint64, err error) {
+	b := &c.brk
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	wasProbe := probe != 0 && probe == b.probe
+	if wasProbe {
+		b.probe = 0
+	}
+	if b.down && !wasProbe {
+		return
+	}
+
+	switch {
+	case isOutage(err):
+		b.failures++
+		b.lastErr = err
+		if b.down {
+			// The probe failed.
+			b.backoff = min(2*b.backoff, c.backoffMax())
+			b.retryAt = time.Now().Add(jitter(b.backoff))
+			return
+		}
+		if b.failures >= c.breakerThreshold() {
+			b.down = true
+			b.since = time.Now()
+			b.backoff = c.backoffMin()
+			b.retryAt = b.since.Add(jitter(b.backoff))
+			b.publishLocked(Availability{Up: false, Since: b.since, Error: err.Error()})
+		}
+	case errors.Is(err, context.Canceled) || errors.Is(err, context.DeadlineExceeded):
+		// The caller gave up before Stompbox said anything.
+	default:
+		// Success, or a reply Stompbox did send (Error line, oversize, ...):
+		// it is reachable.
+		b.failures = 0
+		if b.down {
+			b.down = false
+			b.since = time.Now()
+			b.lastErr = nil
+			b.publishLocked(Availability{Up: true, Since: b.since})
+		}
+	}
+}
+
+// Available reports whether the breaker currently lets commands through
+// without waiting for a probe.
+func (c *Client) Available() bool {
+	c.brk.mu.Lock()
+	defer c.brk.mu.Unlock()
+	return !c.brk.down
+}
+
+// WatchAvailability returns a channel receiving every up/down transition and
+// a cancel func. Slow watchers miss transitions; use Available to resync.
+func (c *Client) WatchAvailability(buffer int) (<-chan Availability, func()) {
+	b := &c.brk
+	ch := make(chan Availability, buffer)
+	b.mu.Lock()
+	if b.subs == nil {
+		b.subs = make(map[chan Availability]struct{})
+	}
+	b.subs[ch] = struct{}{}
+	b.mu.Unlock()
+
+	var once sync.Once
+	return ch, func() {
+		once.Do(func() {
+			b.mu.Lock()
+			delete(b.subs, ch)
+			b.mu.Unlock()
+			close(ch)
+		})
+	}
+}
+
+func (b *breaker) publishLocked(a Availability) {
+	for ch := range b.subs {
+		select {
+		case ch <- a:
+		default:
+		}
+	}
+}
+
+// fillHealth copies the breaker state into h.
+func (b *breaker) fillHealth(h *Health) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	h.Up = !b.down
+	h.ConsecutiveFailures = b.failures
+	if b.down {
+		h.DownSince = b.since
+		h.RetryAt = b.retryAt
+	}
+}
+
+func (c *Client) breakerThreshold() int {
+	if c.BreakerThreshold > 0 {
+		return c.BreakerThreshold
+	}
+	return defaultBreakerThreshold
+}
+
+func (c *Client) backoffMin() time.Duration {
+	if c.BackoffMin > 0 {
+		return c.BackoffMin
+	}
+	return defaultBackoffMin
+}
+
+func (c *Client) backoffMax() time.Duration {
+	if c.BackoffMax > 0 {
+		return c.BackoffMax
+	}
+	return defaultBackoffMax
+}
+
+// jitter spreads d by ±20% so several clients don't probe in lockstep.
+func jitter(d time.Duration) time.Duration {
+	return time.Duration(float64(d) * (0.8 + 0.4*rand.Float64()))
+}
+
+// isOutage reports failures that mean Stompbox is not reachable or not answering.
+func isOutage(err error) bool {
+	var xe *TransportError
+	return errors.As(err, &xe) || isTimeout(err)
+}
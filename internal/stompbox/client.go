@@ -3,12 +3,14 @@ package stompbox
 import (
 	"bufio"
 	"bytes"
+	"context"
 	"errors"
 	"fmt"
 	"io"
 	"net"
 	"strconv"
 	"strings"
+	"sync"
 	"time"
 )
 
@@ -16,81 +18,143 @@ type Client struct {
 	Addr        string
 	DialTimeout time.Duration
 	ReadTimeout time.Duration
-	MaxBytes    int
+	// MaxBytes caps a buffered response. Dump Config is exempt: it is
+	// limited per line by MaxSectionBytes and in total by MaxConfigBytes
+	// instead (see DumpConfigCtx).
+	MaxBytes int
+	// MaxSectionBytes caps one Dump Config line (a plugin's file tree, for
+	// instance). Longer lines are cut at a token boundary, not failed.
+	MaxSectionBytes int
+	// MaxConfigBytes caps the buffered Dump Config (DumpConfigCtx). The
+	// streaming DumpConfigStreamCtx/DumpConfigParsedCtx hold one line at a
+	// time and are not capped in total.
+	MaxConfigBytes int
+	// IdleTimeout closes the shared session once it has been unused for this long,
+	// so the next command starts on a fresh connection. Zero keeps it open.
+	IdleTimeout time.Duration
+	// Tracer, when set, records every exchange (see trace.go).
+	Tracer *Tracer
+	// BreakerThreshold consecutive dial/read/write failures or timeouts mark
+	// Stompbox down; commands then fail fast with *UnavailableError and are
+	// probed again after a backoff growing from BackoffMin to BackoffMax.
+	// Zero values use the defaults in breaker.go.
+	BreakerThreshold int
+	BackoffMin       time.Duration
+	BackoffMax       time.Duration
+
+	semOnce  sync.Once
+	sem      chan struct{} // serializes request/response pairs on the session
+	conn     net.Conn
+	reader   *bufio.Reader
+	lastUsed time.Time
+
+	hmu    sync.Mutex
+	health Health
+
+	brk breaker
 }
 
-// quoteIfNeeded quotes a token only when required (spaces/tabs/quotes).
+// quoteIfNeeded quotes a token only when required (empty, spaces/tabs/quotes).
 // This mirrors the behavior used in SetParam and matches Stompbox parsing with std::quoted.
 func quoteIfNeeded(s string) string {
-	if strings.ContainsAny(s, " \t\"") {
+	if s == "" || strings.ContainsAny(s, " \t\"") {
 		return strconv.Quote(s)
 	}
 	return s
 }
 
-// return first "Error ..." line if present, even if protocol ends with "Ok"
+// return first "Error ..." line if present, even if protocol ends with "Ok".
+// The result is a *ProtocolError without Command; see protocolError.
 func firstProtocolError(resp string) error {
 	scanner := bufio.NewScanner(strings.NewReader(resp))
+	scanner.Buffer(nil, len(resp)+1)
 	for scanner.Scan() {
 		line := strings.TrimSpace(scanner.Text())
 		if strings.HasPrefix(line, "Error") {
-			return errors.New(line)
+			return &ProtocolError{Message: strings.TrimSpace(strings.TrimPrefix(line, "Error"))}
 		}
 	}
 	return nil
 }
 
 func (c *Client) SetParam(plugin, param, value string) error {
+	return c.SetParamCtx(context.Background(), plugin, param, value)
+}
+
+func (c *Client) SetParamCtx(ctx context.Context, plugin, param, value string) error {
 	plugin = strings.TrimSpace(plugin)
 	param = strings.TrimSpace(param)
 	if plugin == "" || param == "" {
 		return fmt.Errorf("missing plugin/param")
 	}
 
-	resp, err := c.SendCommand("SetParam " + plugin + " " + param + " " + quoteIfNeeded(value))
+	cmd := "SetParam " + plugin + " " + param + " " + quoteIfNeeded(value)
+	resp, err := c.SendCommandCtx(ctx, cmd)
 	if err != nil {
 		return err
 	}
-	return firstProtocolError(resp)
+	return protocolError(cmd, resp)
 }
 
 func (c *Client) DeletePreset(name string) error {
+	return c.DeletePresetCtx(context.Background(), name)
+}
+
+func (c *Client) DeletePresetCtx(ctx context.Context, name string) error {
 	n := quoteIfNeeded(name)
-	resp, err := c.SendCommand("DeletePreset " + n)
+	cmd := "DeletePreset " + n
+	resp, err := c.SendCommandCtx(ctx, cmd)
 	if err != nil {
 		return err
 	}
-	return firstProtocolError(resp)
+	return protocolError(cmd, resp)
 }
 
 func New(addr string) *Client {
 	return &Client{
-		Addr:        addr,
-		DialTimeout: 2 * time.Second,
-		ReadTimeout: 10 * time.Second,
-		MaxBytes:    2_000_000,
+		Addr:            addr,
+		DialTimeout:     2 * time.Second,
+		ReadTimeout:     10 * time.Second,
+		MaxBytes:        2_000_000,
+		MaxSectionBytes: 4_000_000,
+		MaxConfigBytes:  16_000_000,
+		IdleTimeout:     30 * time.Second,
 	}
 }
 func (c *Client) LoadPreset(name string) error {
+	return c.LoadPresetCtx(context.Background(), name)
+}
+
+func (c *Client) LoadPresetCtx(ctx context.Context, name string) error {
 	n := quoteIfNeeded(name)
-	resp, err := c.SendCommand("LoadPreset " + n)
+	cmd := "LoadPreset " + n
+	resp, err := c.SendCommandCtx(ctx, cmd)
 	if err != nil {
 		return err
 	}
-	return firstProtocolError(resp)
+	return protocolError(cmd, resp)
 }
 func (c *Client) SavePreset(name string) error {
+	return c.SavePresetCtx(context.Background(), name)
+}
+
+func (c *Client) SavePresetCtx(ctx context.Context, name string) error {
 	n := quoteIfNeeded(name)
-	resp, err := c.SendCommand("SavePreset " + n)
+	cmd := "SavePreset " + n
+	resp, err := c.SendCommandCtx(ctx, cmd)
 	if err != nil {
 		return err
 	}
-	return firstProtocolError(resp)
+	return protocolError(cmd, resp)
 }
 
 // SetChain updates a named signal chain to the provided ordered list.
 // Entries can be instance names (Delay_2) or base types (Delay).
 func (c *Client) SetChain(chain string, plugins []string) error {
+	return c.SetChainCtx(context.Background(), chain, plugins)
+}
+
+func (c *Client) SetChainCtx(ctx context.Context, chain string, plugins []string) error {
 	chain = strings.TrimSpace(chain)
 	if chain == "" {
 		return fmt.Errorf("missing chain name")
@@ -105,72 +169,258 @@ func (c *Client) SetChain(chain string, plugins []string) error {
 		parts = append(parts, quoteIfNeeded(t))
 	}
 
-	resp, err := c.SendCommand(strings.Join(parts, " "))
+	cmd := strings.Join(parts, " ")
+	resp, err := c.SendCommandCtx(ctx, cmd)
 	if err != nil {
 		return err
 	}
-	return firstProtocolError(resp)
+	return protocolError(cmd, resp)
+}
+
+// SetPluginSlot puts a plugin in a named slot (Amp, Tonestack, Cabinet).
+// Like SetChain, plugin can be an instance name (NAM_2) or a base type (NAM).
+func (c *Client) SetPluginSlot(slot, plugin string) error {
+	return c.SetPluginSlotCtx(context.Background(), slot, plugin)
+}
+
+func (c *Client) SetPluginSlotCtx(ctx context.Context, slot, plugin string) error {
+	slot = strings.TrimSpace(slot)
+	plugin = strings.TrimSpace(plugin)
+	if slot == "" || plugin == "" {
+		return fmt.Errorf("missing slot or plugin name")
+	}
+	cmd := "SetPluginSlot " + quoteIfNeeded(slot) + " " + quoteIfNeeded(plugin)
+	resp, err := c.SendCommandCtx(ctx, cmd)
+	if err != nil {
+		return err
+	}
+	return protocolError(cmd, resp)
 }
 
 // ReleasePlugin unloads/frees a plugin instance (after you removed it from the chain).
 func (c *Client) ReleasePlugin(plugin string) error {
+	return c.ReleasePluginCtx(context.Background(), plugin)
+}
+
+func (c *Client) ReleasePluginCtx(ctx context.Context, plugin string) error {
 	plugin = strings.TrimSpace(plugin)
 	if plugin == "" {
 		return fmt.Errorf("missing plugin name")
 	}
-	resp, err := c.SendCommand("ReleasePlugin " + quoteIfNeeded(plugin))
+	cmd := "ReleasePlugin " + quoteIfNeeded(plugin)
+	resp, err := c.SendCommandCtx(ctx, cmd)
 	if err != nil {
 		return err
 	}
-	return firstProtocolError(resp)
+	return protocolError(cmd, resp)
 }
 
+// stopFunc reports whether lineTrim terminates the response.
+type stopFunc func(lineTrim string, st *termState) bool
+
+// lineFunc receives each response line as read, terminator included.
+// truncated is set when the line was cut at the per-line limit (see readCappedLine).
+type lineFunc func(line string, truncated bool) error
+
 // doUntil sends a single command (must include \r\n) and reads lines until stop(line,state) returns true.
-// It refreshes read deadlines per read so large dumps don’t time out mid-stream.
-func (c *Client) doUntil(command string, stop func(lineTrim string, st *termState) bool) (string, error) {
-	conn, err := net.DialTimeout("tcp", c.Addr, c.DialTimeout)
+// The whole response is buffered and capped at MaxBytes.
+func (c *Client) doUntil(ctx context.Context, command string, stop stopFunc) (string, error) {
+	return c.collect(ctx, command, stop, 0, c.MaxBytes)
+}
+
+// collect buffers the response of command. maxLine caps each line and limit
+// the whole response (0 = no cap).
+func (c *Client) collect(ctx context.Context, command string, stop stopFunc, maxLine, limit int) (string, error) {
+	var buf bytes.Buffer
+	err := c.do(ctx, command, stop, maxLine, func(line string, _ bool) error {
+		buf.WriteString(line)
+		if limit > 0 && buf.Len() > limit {
+			return &OversizeError{Command: commandLabel(command), Limit: limit}
+		}
+		return nil
+	})
+	return buf.String(), err
+}
+
+// do sends command and hands every response line to emit until stop returns true.
+// Commands are serialized over the shared session; see session.go.
+// Cancelling ctx aborts both the wait for the session and an in-flight read.
+// While the breaker is open it returns *UnavailableError without touching the network.
+// An error returned by emit aborts the exchange (the session is dropped) and is returned as is.
+func (c *Client) do(ctx context.Context, command string, stop stopFunc, maxLine int, emit lineFunc) error {
+	probe, err := c.allow()
 	if err != nil {
-		return "", err
+		return err
+	}
+	if err := c.lock(ctx); err != nil {
+		c.observe(probe, err)
+		return err
 	}
-	defer conn.Close()
+	defer c.unlock()
+
+	// Keep just enough of the response for the tracer to store (and mark truncated).
+	var traced strings.Builder
+	tr := c.Tracer
+	start := time.Now()
+	err = c.roundTrip(ctx, command, stop, maxLine, func(line string, truncated bool) error {
+		if tr != nil && (tr.MaxResponse <= 0 || traced.Len() <= tr.MaxResponse) {
+			traced.WriteString(line)
+		}
+		return emit(line, truncated)
+	})
+	c.observe(probe, err)
+	c.recordResult(time.Since(start), err)
+	c.trace(start, command, traced.String(), err)
+	return err
+}
+
+// exchange writes command on the current session and reads lines until stop returns true.
+// It refreshes read deadlines per read so large dumps don’t time out mid-stream.
+// sent reports whether the command was written (Stompbox may have run it) and
+// n is the number of response bytes received; both decide whether a retry is safe.
+func (c *Client) exchange(ctx context.Context, command string, stop stopFunc, maxLine int, emit lineFunc) (sent bool, n int, err error) {
+	// Cancellation forces any blocked read/write to return immediately.
+	conn := c.conn
+	stopAbort := context.AfterFunc(ctx, func() {
+		_ = conn.SetDeadline(time.Unix(1, 0))
+	})
+	defer stopAbort()
 
-	_ = conn.SetWriteDeadline(time.Now().Add(2 * time.Second))
+	_ = conn.SetWriteDeadline(deadlineFor(ctx, 2*time.Second))
+	if ctx.Err() != nil {
+		return false, 0, ctx.Err()
+	}
 	if _, err := conn.Write([]byte(command)); err != nil {
-		return "", err
+		if err := ctxErr(ctx); err != nil {
+			return false, 0, err
+		}
+		return false, 0, c.wrapIOError("write", command, err)
 	}
 
-	reader := bufio.NewReader(conn)
-	var buf bytes.Buffer
 	st := &termState{}
 
 	for {
-		_ = conn.SetReadDeadline(time.Now().Add(c.ReadTimeout))
-		line, readErr := reader.ReadString('\n')
+		_ = conn.SetReadDeadline(deadlineFor(ctx, c.ReadTimeout))
+		// Re-check after arming the deadline: if ctx was cancelled before this point
+		// the AfterFunc may already have run and been overridden.
+		if ctx.Err() != nil {
+			return true, n, ctx.Err()
+		}
+		line, truncated, read, readErr := readCappedLine(c.reader, maxLine)
+		n += read
 
 		// accept partial line on EOF/no newline
 		if line != "" {
-			buf.WriteString(line)
-			if buf.Len() > c.MaxBytes {
-				return buf.String(), errors.New("response exceeded max size")
-			}
 			trim := strings.TrimSpace(line)
 			if trim != "" {
 				st.lastLine = trim
 			}
+			if err := emit(line, truncated); err != nil {
+				return true, n, err
+			}
 			if stop(trim, st) {
-				return buf.String(), nil
+				return true, n, nil
 			}
 		}
 
 		if readErr != nil {
-			if errors.Is(readErr, io.EOF) {
-				break
+			if err := ctxErr(ctx); err != nil {
+				return true, n, err
+			}
+			if errors.Is(readErr, io.EOF) && n > 0 {
+				return true, n, &IncompleteResponseError{Command: commandLabel(command), LastLine: st.lastLine}
+			}
+			return true, n, c.wrapIOError("read", command, readErr)
+		}
+	}
+}
+
+// readCappedLine reads one line. With max > 0, a longer line is cut back to
+// its last complete token (see cutToToken) and the rest is discarded, so a
+// huge line never has to fit in memory; its CRLF terminator is kept.
+// read counts every byte consumed, including discarded ones.
+func readCappedLine(r *bufio.Reader, max int) (line string, truncated bool, read int, err error) {
+	if max <= 0 {
+		line, err = r.ReadString('\n')
+		return line, false, len(line), err
+	}
+
+	var b strings.Builder
+	for {
+		frag, ferr := r.ReadSlice('\n')
+		read += len(frag)
+		if room := max - b.Len(); len(frag) > room {
+			b.Write(frag[:room])
+			truncated = true
+		} else {
+			b.Write(frag)
+		}
+		if ferr == bufio.ErrBufferFull {
+			continue
+		}
+		err = ferr
+		break
+	}
+	if !truncated {
+		return b.String(), false, read, err
+	}
+	line = cutToToken(b.String())
+	if err == nil {
+		line += "\r\n"
+	}
+	return line, true, read, err
+}
+
+// cutToToken returns s up to the end of its last complete token, following
+// the quoting rules of splitQuoted.
+func cutToToken(s string) string {
+	end := 0
+	inQuote, escaped, inToken := false, false, false
+	for i := 0; i < len(s); i++ {
+		ch := s[i]
+		switch {
+		case escaped:
+			escaped = false
+		case inQuote && ch == '\\':
+			escaped = true
+		case ch == '"':
+			if inQuote {
+				end = i + 1
 			}
-			break
+			inQuote = !inQuote
+			inToken = false
+		case inQuote:
+		case ch == ' ' || ch == '\t' || ch == '\r' || ch == '\n':
+			if inToken {
+				end = i
+			}
+			inToken = false
+		default:
+			inToken = true
 		}
 	}
+	return s[:end]
+}
 
-	return buf.String(), fmt.Errorf("incomplete response (last line=%q)", st.lastLine)
+// ctxErr is ctx.Err(), but also reports DeadlineExceeded when the context
+// deadline has passed and the conn deadline (armed from it) fired first.
+func ctxErr(ctx context.Context) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	if dl, ok := ctx.Deadline(); ok && !time.Now().Before(dl) {
+		return context.DeadlineExceeded
+	}
+	return nil
+}
+
+// deadlineFor returns now+d, or the context deadline if that comes first.
+func deadlineFor(ctx context.Context, d time.Duration) time.Time {
+	dl := time.Now().Add(d)
+	if cd, ok := ctx.Deadline(); ok && cd.Before(dl) {
+		return cd
+	}
+	return dl
 }
 
 type termState struct {
@@ -185,16 +435,86 @@ type termState struct {
 //	EndConfig
 //	Ok
 func (c *Client) DumpConfig() (string, error) {
-	return c.doUntil("Dump Config\r\n", func(line string, st *termState) bool {
-		if line == "EndConfig" {
-			st.seenEndConfig = true
-			return false
-		}
-		if st.seenEndConfig && line == "Ok" {
-			return true
+	return c.DumpConfigCtx(context.Background())
+}
+
+// The response is not capped at MaxBytes; each line is capped at
+// MaxSectionBytes instead, so a growing NAM/IR library shortens its file tree
+// rather than failing the whole dump, and the whole response at
+// MaxConfigBytes. Prefer DumpConfigParsedCtx, which doesn't buffer it.
+func (c *Client) DumpConfigCtx(ctx context.Context) (string, error) {
+	return c.collect(ctx, "Dump Config\r\n", dumpConfigStop, c.MaxSectionBytes, c.MaxConfigBytes)
+}
+
+// DumpConfigStreamCtx sends Dump Config and parses the response as it
+// arrives, calling fn for every event. Only one line is held at a time.
+// An error from fn aborts the dump and is returned.
+func (c *Client) DumpConfigStreamCtx(ctx context.Context, fn func(ConfigEvent) error) error {
+	var (
+		p      configParser
+		lineNo int
+		fnErr  error
+	)
+	return c.do(ctx, "Dump Config\r\n", dumpConfigStop, c.MaxSectionBytes, func(line string, truncated bool) error {
+		lineNo++
+		p.feed(lineNo, line, truncated, func(ev ConfigEvent) {
+			if fnErr == nil {
+				fnErr = fn(ev)
+			}
+		})
+		return fnErr
+	})
+}
+
+// DumpConfigParsedCtx is DumpConfigStreamCtx assembled into a DumpConfigParsed,
+// without ever buffering the raw response.
+func (c *Client) DumpConfigParsedCtx(ctx context.Context) (*DumpConfigParsed, error) {
+	b := newConfigBuilder()
+	err := c.DumpConfigStreamCtx(ctx, func(ev ConfigEvent) error {
+		b.apply(ev)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return b.out, nil
+}
+
+// DumpConfigRawCtx is DumpConfigParsedCtx that also returns the raw
+// response, for views that show it. The parse doesn't depend on the raw
+// text: past MaxConfigBytes it is dropped ("") and parsing goes on.
+func (c *Client) DumpConfigRawCtx(ctx context.Context) (*DumpConfigParsed, string, error) {
+	var (
+		p      configParser
+		b      = newConfigBuilder()
+		raw    strings.Builder
+		over   bool
+		lineNo int
+	)
+	err := c.do(ctx, "Dump Config\r\n", dumpConfigStop, c.MaxSectionBytes, func(line string, truncated bool) error {
+		lineNo++
+		p.feed(lineNo, line, truncated, b.apply)
+		if !over {
+			raw.WriteString(line)
+			if c.MaxConfigBytes > 0 && raw.Len() > c.MaxConfigBytes {
+				over = true
+				raw.Reset()
+			}
 		}
-		return false
+		return nil
 	})
+	if err != nil {
+		return nil, "", err
+	}
+	return b.out, raw.String(), nil
+}
+
+func dumpConfigStop(line string, st *termState) bool {
+	if line == "EndConfig" {
+		st.seenEndConfig = true
+		return false
+	}
+	return st.seenEndConfig && line == "Ok"
 }
 
 // DumpProgram reads until:
@@ -202,7 +522,11 @@ func (c *Client) DumpConfig() (string, error) {
 //	EndProgram
 //	Ok
 func (c *Client) DumpProgram() (string, error) {
-	return c.doUntil("Dump Program\r\n", func(line string, st *termState) bool {
+	return c.DumpProgramCtx(context.Background())
+}
+
+func (c *Client) DumpProgramCtx(ctx context.Context) (string, error) {
+	return c.doUntil(ctx, "Dump Program\r\n", func(line string, st *termState) bool {
 		if line == "EndProgram" {
 			st.seenEndProgram = true
 			return false
@@ -215,12 +539,16 @@ func (c *Client) DumpProgram() (string, error) {
 }
 
 func (c *Client) SendCommand(cmd string) (string, error) {
+	return c.SendCommandCtx(context.Background(), cmd)
+}
+
+func (c *Client) SendCommandCtx(ctx context.Context, cmd string) (string, error) {
 	// Ensure CRLF terminator (stompbox protocol expects \r\n)
 	if !strings.HasSuffix(cmd, "\r\n") {
 		cmd += "\r\n"
 	}
 
-	return c.doUntil(cmd, func(line string, st *termState) bool {
+	return c.doUntil(ctx, cmd, func(line string, st *termState) bool {
 		// Stop when we get the Ok terminator
 		if line == "Ok" {
 			st.seenOk = true
@@ -234,7 +562,11 @@ func (c *Client) SendCommand(cmd string) (string, error) {
 //
 //	Ok
 func (c *Client) ListPresets() (string, error) {
-	return c.doUntil("List Presets\r\n", func(line string, st *termState) bool {
+	return c.ListPresetsCtx(context.Background())
+}
+
+func (c *Client) ListPresetsCtx(ctx context.Context) (string, error) {
+	return c.doUntil(ctx, "List Presets\r\n", func(line string, st *termState) bool {
 		return line == "Ok"
 	})
 }
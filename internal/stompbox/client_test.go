@@ -1,6 +1,17 @@
 package stompbox
 
-import "testing"
+import (
+	"bufio"
+	"context"
+	"errors"
+	"net"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
 
 func TestQuoteIfNeeded(t *testing.T) {
 	cases := []struct {
@@ -12,6 +23,7 @@ func TestQuoteIfNeeded(t *testing.T) {
 		{"tab\tsep", `"tab\tsep"`},
 		{`he"llo`, `"he\"llo"`},
 		{"  spaced  ", `"  spaced  "`}, // quoting decision is based on content, not trimming
+		{"", `""`},                     // an empty File value must still be sent
 	}
 
 	for _, tc := range cases {
@@ -44,3 +56,383 @@ func TestFirstProtocolError(t *testing.T) {
 		}
 	})
 }
+
+// lineServer accepts connections and answers every CRLF command with "Ok".
+// It counts accepted connections so tests can assert session reuse.
+func lineServer(t *testing.T) (addr string, accepts *atomic.Int32, ln net.Listener) {
+	return replyServer(t, func(string) string { return "Ok\r\n" })
+}
+
+// replyServer answers every CRLF command with reply(command); an empty reply
+// closes the connection without answering.
+func replyServer(t *testing.T, reply func(cmd string) string) (addr string, accepts *atomic.Int32, ln net.Listener) {
+	t.Helper()
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("listen: %v", err)
+	}
+	t.Cleanup(func() { ln.Close() })
+
+	accepts = &atomic.Int32{}
+	go func() {
+		for {
+			conn, err := ln.Accept()
+			if err != nil {
+				return
+			}
+			accepts.Add(1)
+			go func(c net.Conn) {
+				defer c.Close()
+				r := bufio.NewReader(c)
+				for {
+					line, err := r.ReadString('\n')
+					if err != nil {
+						return
+					}
+					out := reply(strings.TrimSpace(line))
+					if out == "" {
+						return
+					}
+					_, _ = c.Write([]byte(out))
+				}
+			}(conn)
+		}
+	}()
+	return ln.Addr().String(), accepts, ln
+}
+
+func TestClientReusesSession(t *testing.T) {
+	addr, accepts, _ := lineServer(t)
+	c := New(addr)
+	defer c.Close()
+
+	for i := 0; i < 5; i++ {
+		if err := c.SetParam("Boost", "Gain", "1"); err != nil {
+			t.Fatalf("SetParam #%d: %v", i, err)
+		}
+	}
+	if n := accepts.Load(); n != 1 {
+		t.Fatalf("accepted %d connections; want 1", n)
+	}
+	if h := c.Health(); !h.Connected || h.Commands != 5 || h.Failures != 0 {
+		t.Fatalf("unexpected health: %+v", h)
+	}
+}
+
+func TestClientRedialsAfterServerClose(t *testing.T) {
+	addr, accepts, _ := lineServer(t)
+	c := New(addr)
+	defer c.Close()
+
+	if err := c.SetParam("Boost", "Gain", "1"); err != nil {
+		t.Fatalf("first SetParam: %v", err)
+	}
+
+	// Simulate Stompbox dropping the idle session from its side.
+	_ = c.lock(context.Background())
+	_ = c.conn.(*net.TCPConn).CloseRead()
+	c.unlock()
+
+	if err := c.SetParam("Boost", "Gain", "2"); err != nil {
+		t.Fatalf("SetParam after close: %v", err)
+	}
+	if n := accepts.Load(); n != 2 {
+		t.Fatalf("accepted %d connections; want 2", n)
+	}
+}
+
+func TestClientRetriesOnlyReads(t *testing.T) {
+	var (
+		mu   sync.Mutex
+		seen = map[string]int{}
+	)
+	// Stompbox takes the command, then drops the session unanswered.
+	addr, _, _ := replyServer(t, func(cmd string) string {
+		mu.Lock()
+		defer mu.Unlock()
+		seen[cmd]++
+		if cmd == "SavePreset x" || cmd == "Dump Program" && seen[cmd] == 1 {
+			return ""
+		}
+		if cmd == "Dump Program" {
+			return "SetPreset x\r\nEndProgram\r\nOk\r\n"
+		}
+		return "Ok\r\n"
+	})
+	c := New(addr)
+	defer c.Close()
+
+	if err := c.SetParam("Boost", "Gain", "1"); err != nil {
+		t.Fatal(err)
+	}
+	if err := c.SavePreset("x"); err == nil {
+		t.Fatal("SavePreset on a dropped session succeeded")
+	}
+	if err := c.SetParam("Boost", "Gain", "2"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := c.DumpProgram(); err != nil {
+		t.Fatalf("DumpProgram was not retried: %v", err)
+	}
+	mu.Lock()
+	defer mu.Unlock()
+	if seen["SavePreset x"] != 1 || seen["Dump Program"] != 2 {
+		t.Fatalf("commands received: %v", seen)
+	}
+}
+
+func TestClientIdleTimeoutRedials(t *testing.T) {
+	addr, accepts, _ := lineServer(t)
+	c := New(addr)
+	c.IdleTimeout = 10 * time.Millisecond
+	defer c.Close()
+
+	_ = c.SetParam("Boost", "Gain", "1")
+	time.Sleep(30 * time.Millisecond)
+	_ = c.SetParam("Boost", "Gain", "2")
+
+	if n := accepts.Load(); n != 2 {
+		t.Fatalf("accepted %d connections; want 2", n)
+	}
+}
+
+func TestClientCtxCancelAbortsRead(t *testing.T) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("listen: %v", err)
+	}
+	defer ln.Close()
+
+	// Accept and never answer, like a Stompbox stuck mid-dump.
+	go func() {
+		for {
+			conn, err := ln.Accept()
+			if err != nil {
+				return
+			}
+			defer conn.Close()
+		}
+	}()
+
+	c := New(ln.Addr().String())
+	defer c.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+
+	start := time.Now()
+	_, err = c.DumpConfigCtx(ctx)
+	if !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("DumpConfigCtx error = %v; want context.DeadlineExceeded", err)
+	}
+	if d := time.Since(start); d > time.Second {
+		t.Fatalf("DumpConfigCtx took %v after cancellation", d)
+	}
+	if h := c.Health(); h.Connected {
+		t.Fatalf("session should be dropped after an aborted read")
+	}
+}
+
+func TestClientTypedErrors(t *testing.T) {
+	t.Run("protocol error carries command", func(t *testing.T) {
+		addr, _, _ := replyServer(t, func(string) string { return "Error Unknown plugin\r\nOk\r\n" })
+		c := New(addr)
+		defer c.Close()
+
+		err := c.SetParam("Nope", "Gain", "1")
+		var pe *ProtocolError
+		if !errors.As(err, &pe) {
+			t.Fatalf("SetParam error = %T %v; want *ProtocolError", err, err)
+		}
+		if pe.Command != "SetParam Nope Gain 1" || pe.Message != "Unknown plugin" {
+			t.Fatalf("unexpected protocol error: %+v", pe)
+		}
+	})
+
+	t.Run("read timeout", func(t *testing.T) {
+		// Dump never reaches EndConfig/Ok.
+		addr, _, _ := replyServer(t, func(string) string { return "PluginConfig Boost\r\n" })
+		c := New(addr)
+		defer c.Close()
+
+		c.ReadTimeout = 50 * time.Millisecond
+		_, err := c.DumpConfig()
+		var te *TimeoutError
+		if !errors.As(err, &te) || te.Op != "read" {
+			t.Fatalf("DumpConfig error = %T %v; want read *TimeoutError", err, err)
+		}
+	})
+
+	t.Run("oversize", func(t *testing.T) {
+		addr, _, _ := replyServer(t, func(string) string { return strings.Repeat("x", 64) + "\r\nOk\r\n" })
+		c := New(addr)
+		c.MaxBytes = 16
+		defer c.Close()
+
+		_, err := c.ListPresets()
+		var oe *OversizeError
+		if !errors.As(err, &oe) || oe.Limit != 16 {
+			t.Fatalf("ListPresets error = %T %v; want *OversizeError", err, err)
+		}
+	})
+
+	t.Run("dial failure", func(t *testing.T) {
+		ln, err := net.Listen("tcp", "127.0.0.1:0")
+		if err != nil {
+			t.Fatalf("listen: %v", err)
+		}
+		addr := ln.Addr().String()
+		ln.Close()
+
+		c := New(addr)
+		err = c.SetParam("Boost", "Gain", "1")
+		var xe *TransportError
+		if !errors.As(err, &xe) || xe.Op != "dial" {
+			t.Fatalf("SetParam error = %T %v; want dial *TransportError", err, err)
+		}
+	})
+}
+
+func TestTracerRing(t *testing.T) {
+	tr := NewTracer(3)
+	for i := 0; i < 5; i++ {
+		tr.Record(TraceEntry{Command: "SetParam Boost Gain " + strconv.Itoa(i)})
+	}
+	got := tr.Entries()
+	if len(got) != 3 {
+		t.Fatalf("Entries() returned %d; want 3", len(got))
+	}
+	for i, e := range got {
+		if want := uint64(i + 3); e.Seq != want {
+			t.Fatalf("entry %d has Seq %d; want %d (oldest first)", i, e.Seq, want)
+		}
+	}
+}
+
+func TestClientBreakerFailsFastAndRecovers(t *testing.T) {
+	// Reserve a port, then leave it closed so dials are refused.
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("listen: %v", err)
+	}
+	addr := ln.Addr().String()
+	ln.Close()
+
+	c := New(addr)
+	c.BreakerThreshold = 2
+	c.BackoffMin = 20 * time.Millisecond
+	c.BackoffMax = 40 * time.Millisecond
+	defer c.Close()
+
+	avail, cancel := c.WatchAvailability(4)
+	defer cancel()
+
+	for i := 0; i < 2; i++ {
+		var xe *TransportError
+		if err := c.SetParam("Boost", "Gain", "1"); !errors.As(err, &xe) {
+			t.Fatalf("SetParam #%d: got %v; want *TransportError", i, err)
+		}
+	}
+	if a := <-avail; a.Up {
+		t.Fatalf("first transition %+v; want down", a)
+	}
+
+	dials := c.Health().Dials
+	var ue *UnavailableError
+	if err := c.SetParam("Boost", "Gain", "1"); !errors.As(err, &ue) {
+		t.Fatalf("while down: got %v; want *UnavailableError", err)
+	}
+	if h := c.Health(); h.Dials != dials || h.Up {
+		t.Fatalf("fail-fast should not dial: %+v", h)
+	}
+
+	// Stompbox comes back on the same port.
+	ln, err = net.Listen("tcp", addr)
+	if err != nil {
+		t.Skipf("port %s taken meanwhile: %v", addr, err)
+	}
+	defer ln.Close()
+	go func() {
+		conn, err := ln.Accept()
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+		r := bufio.NewReader(conn)
+		for {
+			if _, err := r.ReadString('\n'); err != nil {
+				return
+			}
+			_, _ = conn.Write([]byte("Ok\r\n"))
+		}
+	}()
+
+	deadline := time.Now().Add(2 * time.Second)
+	for {
+		err := c.SetParam("Boost", "Gain", "1")
+		if err == nil {
+			break
+		}
+		if !errors.As(err, &ue) || time.Now().After(deadline) {
+			t.Fatalf("recovery: %v", err)
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+	if a := <-avail; !a.Up {
+		t.Fatalf("second transition %+v; want up", a)
+	}
+	if !c.Available() {
+		t.Fatal("client still reports Stompbox down")
+	}
+}
+
+func TestClientBreakerClosesOnErrorReply(t *testing.T) {
+	c := New("127.0.0.1:1")
+	c.BreakerThreshold = 1
+	c.BackoffMin = time.Millisecond
+	c.BackoffMax = time.Millisecond
+	defer c.Close()
+
+	avail, cancel := c.WatchAvailability(4)
+	defer cancel()
+
+	// Two commands in flight; the first failure opens the breaker.
+	early, _ := c.allow()
+	c.observe(0, &TransportError{Op: "dial", Addr: c.Addr, Err: errors.New("refused")})
+	if a := <-avail; a.Up {
+		t.Fatalf("first transition %+v; want down", a)
+	}
+	// The other one, started before, answers late: it is stale.
+	c.observe(early, nil)
+	if c.Available() {
+		t.Fatal("a command started before the breaker opened closed it")
+	}
+
+	probe := func() uint64 {
+		t.Helper()
+		time.Sleep(3 * time.Millisecond)
+		p, err := c.allow()
+		if err != nil || p == 0 {
+			t.Fatalf("no probe let through: %d %v", p, err)
+		}
+		if _, err := c.allow(); err == nil {
+			t.Fatal("a second probe was let through")
+		}
+		return p
+	}
+
+	// The caller giving up says nothing about Stompbox.
+	c.observe(probe(), context.Canceled)
+	if c.Available() {
+		t.Fatal("caller cancellation closed the breaker")
+	}
+
+	// An Error line is still a reply.
+	c.observe(probe(), &ProtocolError{Command: "SetParam Boost Gain x", Message: "bad value"})
+	if a := <-avail; !a.Up {
+		t.Fatalf("second transition %+v; want up", a)
+	}
+	if h := c.Health(); !h.Up || h.ConsecutiveFailures != 0 {
+		t.Fatalf("after an Error reply: %+v", h)
+	}
+}
@@ -0,0 +1,82 @@
+package stompbox
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Severity of a parser diagnostic.
+type Severity string
+
+const (
+	SeverityInfo    Severity = "info"    // information kept but not interpreted (unknown keys)
+	SeverityWarning Severity = "warning" // input fixed up or ignored
+	SeverityError   Severity = "error"   // input dropped
+)
+
+// Diagnostic reports an anomaly found while parsing a dump, so format changes
+// in a Stompbox upgrade show up instead of being silently absorbed.
+type Diagnostic struct {
+	Line      int      `json:"line"` // 1-based line in the dump
+	Severity  Severity `json:"severity"`
+	Directive string   `json:"directive,omitempty"` // first token of the line
+	Message   string   `json:"message"`
+	Recovery  string   `json:"recovery,omitempty"` // what the parser did instead
+}
+
+func (d Diagnostic) String() string {
+	s := fmt.Sprintf("line %d: %s: %s", d.Line, d.Severity, d.Message)
+	if d.Recovery != "" {
+		s += " (" + d.Recovery + ")"
+	}
+	return s
+}
+
+// Diagnostics is the list returned alongside a parse result.
+type Diagnostics []Diagnostic
+
+func (d *Diagnostics) add(line int, sev Severity, directive, msg, recovery string) {
+	*d = append(*d, Diagnostic{Line: line, Severity: sev, Directive: directive, Message: msg, Recovery: recovery})
+}
+
+// Strict returns a *StrictError if there is any diagnostic at all.
+func (d Diagnostics) Strict() error {
+	if len(d) == 0 {
+		return nil
+	}
+	return &StrictError{Diagnostics: d}
+}
+
+// StrictError is returned by the strict parsers when a dump is not exactly
+// in the format they expect.
+type StrictError struct {
+	Diagnostics Diagnostics
+}
+
+func (e *StrictError) Error() string {
+	msgs := make([]string, 0, len(e.Diagnostics))
+	for _, d := range e.Diagnostics {
+		msgs = append(msgs, d.String())
+	}
+	return fmt.Sprintf("%d parser diagnostic(s): %s", len(msgs), strings.Join(msgs, "; "))
+}
+
+// ParseDumpConfigStrict is ParseDumpConfig that fails with *StrictError on
+// any diagnostic. The parse result is returned either way.
+func ParseDumpConfigStrict(raw string) (*DumpConfigParsed, error) {
+	p, err := ParseDumpConfig(raw)
+	if err != nil {
+		return nil, err
+	}
+	return p, p.Diagnostics.Strict()
+}
+
+// ParseProgramScriptStrict is ParseProgramScript that fails with *StrictError
+// on any diagnostic. The parse result is returned either way.
+func ParseProgramScriptStrict(raw string) (*ProgramScript, error) {
+	s, err := ParseProgramScript(raw)
+	if err != nil {
+		return nil, err
+	}
+	return s, s.Diagnostics.Strict()
+}
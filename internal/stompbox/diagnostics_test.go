@@ -0,0 +1,55 @@
+package stompbox
+
+import (
+	"errors"
+	"testing"
+)
+
+// The sample dumps are the reference format: any new diagnostic here means
+// the parsers (or the samples) drifted from what Stompbox emits.
+func TestSampleDumpsStrict(t *testing.T) {
+	if _, err := ParseProgramScriptStrict(readSample(t, "dump_program.example.txt")); err != nil {
+		t.Fatalf("sample program: %v", err)
+	}
+
+	cfg, err := ParseDumpConfigStrict(readSample(t, "dump_config.example.txt"))
+	var se *StrictError
+	if !errors.As(err, &se) {
+		t.Fatalf("sample config: got %v; want the known NAMMulti recoveries", err)
+	}
+	// NAMMulti dumps Gain and Volume without the plugin name.
+	want := []int{83, 84}
+	if len(se.Diagnostics) != len(want) {
+		t.Fatalf("diagnostics: %v", se.Diagnostics)
+	}
+	for i, d := range se.Diagnostics {
+		if d.Line != want[i] || d.Severity != SeverityWarning || d.Directive != "ParameterConfig" {
+			t.Fatalf("diagnostic %d: %+v", i, d)
+		}
+	}
+	if cfg.Plugins["Gain"] != nil || cfg.Plugins["NAMMulti"].Params["Volume"] == nil {
+		t.Fatalf("NAMMulti recovery attached params to the wrong plugin: %v", cfg.Order)
+	}
+}
+
+func TestProgramDiagnostics(t *testing.T) {
+	s, err := ParseProgramScript("SetPreset x\r\nSetChain\r\nMidiMap 1 2\r\nSetParam Boost Gain 1\r\nEndProgram\r\nOk\r\n")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(s.Diagnostics) != 2 {
+		t.Fatalf("diagnostics: %v", s.Diagnostics)
+	}
+	if d := s.Diagnostics[0]; d.Line != 2 || d.Severity != SeverityError {
+		t.Fatalf("malformed SetChain: %+v", d)
+	}
+	if d := s.Diagnostics[1]; d.Line != 3 || d.Severity != SeverityWarning || d.Directive != "MidiMap" {
+		t.Fatalf("unknown directive: %+v", d)
+	}
+	if len(s.Lines) != 4 || s.Lines[1].Known {
+		t.Fatalf("malformed line should be kept but not interpreted: %+v", s.Lines)
+	}
+	if _, err := ParseProgramScriptStrict(s.Serialize()); err == nil {
+		t.Fatal("strict parse should fail")
+	}
+}
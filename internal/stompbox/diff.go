@@ -0,0 +1,243 @@
+package stompbox
+
+import (
+	"fmt"
+	"slices"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// Kinds of change reported for a param.
+const (
+	ChangeAdded   = "added"
+	ChangeRemoved = "removed"
+	ChangeValue   = "changed"
+)
+
+// ProgramDiff is what changed from program A to program B.
+type ProgramDiff struct {
+	Preset *PresetChange `json:"preset,omitempty"`
+	// AddedPlugins and RemovedPlugins are instances referenced only by B / only by A.
+	AddedPlugins   []string      `json:"addedPlugins,omitempty"`
+	RemovedPlugins []string      `json:"removedPlugins,omitempty"`
+	Chains         []ChainChange `json:"chains,omitempty"`
+	Slots          []SlotChange  `json:"slots,omitempty"`
+	// Params only covers instances present in both programs.
+	Params []ParamChange `json:"params,omitempty"`
+}
+
+type PresetChange struct {
+	From string `json:"from"`
+	To   string `json:"to"`
+}
+
+type ChainChange struct {
+	Chain   string   `json:"chain"`
+	From    []string `json:"from"`
+	To      []string `json:"to"`
+	Added   []string `json:"added,omitempty"`
+	Removed []string `json:"removed,omitempty"`
+	// Reordered is set when the instances kept in the chain changed order.
+	Reordered bool `json:"reordered,omitempty"`
+}
+
+type SlotChange struct {
+	Slot string `json:"slot"`
+	From string `json:"from"`
+	To   string `json:"to"`
+}
+
+type ParamChange struct {
+	Plugin string `json:"plugin"`
+	Param  string `json:"param"`
+	Change string `json:"change"` // ChangeAdded, ChangeRemoved or ChangeValue
+	From   string `json:"from,omitempty"`
+	To     string `json:"to,omitempty"`
+	Type   string `json:"type,omitempty"` // ParamDef type when known
+	// Delta is To-From for numeric params; DeltaRange is Delta as a
+	// fraction of the ParamDef Min..Max range.
+	Delta      *float64 `json:"delta,omitempty"`
+	DeltaRange *float64 `json:"deltaRange,omitempty"`
+}
+
+// Empty reports whether the programs are equivalent.
+func (d *ProgramDiff) Empty() bool {
+	return d.Preset == nil && len(d.AddedPlugins) == 0 && len(d.RemovedPlugins) == 0 &&
+		len(d.Chains) == 0 && len(d.Slots) == 0 && len(d.Params) == 0
+}
+
+// DiffPrograms compares two programs. cfg is optional: with it, param types
+// come from ParamDef, File params are compared as text and deltas are also
+// given relative to the param range. Numeric values that parse to the same
+// number ("0.5" and "0.500000") are equal.
+func DiffPrograms(a, b *Program, cfg *DumpConfigParsed) *ProgramDiff {
+	d := &ProgramDiff{}
+	if a.ActivePreset != b.ActivePreset {
+		d.Preset = &PresetChange{From: a.ActivePreset, To: b.ActivePreset}
+	}
+
+	inA := setOf(a.instanceNames())
+	inB := setOf(b.instanceNames())
+	for name := range inB {
+		if _, ok := inA[name]; !ok {
+			d.AddedPlugins = append(d.AddedPlugins, name)
+		}
+	}
+	for name := range inA {
+		if _, ok := inB[name]; !ok {
+			d.RemovedPlugins = append(d.RemovedPlugins, name)
+		}
+	}
+	sort.Strings(d.AddedPlugins)
+	sort.Strings(d.RemovedPlugins)
+
+	for _, chain := range unionKeys(a.Chains, b.Chains) {
+		from, to := a.Chains[chain], b.Chains[chain]
+		if slices.Equal(from, to) {
+			continue
+		}
+		c := ChainChange{Chain: chain, From: from, To: to}
+		c.Added = missingFrom(to, from)
+		c.Removed = missingFrom(from, to)
+		c.Reordered = !slices.Equal(keepOnly(from, to), keepOnly(to, from))
+		d.Chains = append(d.Chains, c)
+	}
+
+	for _, slot := range unionKeys(a.Slots, b.Slots) {
+		if a.Slots[slot] != b.Slots[slot] {
+			d.Slots = append(d.Slots, SlotChange{Slot: slot, From: a.Slots[slot], To: b.Slots[slot]})
+		}
+	}
+
+	for _, plugin := range unionKeys(a.Params, b.Params) {
+		pa, okA := a.Params[plugin]
+		pb, okB := b.Params[plugin]
+		if !okA || !okB {
+			continue // whole instance added or removed
+		}
+		for _, param := range unionKeys(pa, pb) {
+			if c, changed := diffParam(cfg, plugin, param, pa, pb); changed {
+				d.Params = append(d.Params, c)
+			}
+		}
+	}
+	return d
+}
+
+func diffParam(cfg *DumpConfigParsed, plugin, param string, pa, pb map[string]string) (ParamChange, bool) {
+	from, okA := pa[param]
+	to, okB := pb[param]
+	c := ParamChange{Plugin: plugin, Param: param, From: from, To: to}
+	def, _ := cfg.ResolveParam(plugin, param)
+	if def != nil {
+		c.Type = def.Type
+	}
+
+	switch {
+	case !okA:
+		c.Change = ChangeAdded
+		return c, true
+	case !okB:
+		c.Change = ChangeRemoved
+		return c, true
+	}
+	c.Change = ChangeValue
+
+	if c.Type != "File" {
+		fa, errA := strconv.ParseFloat(from, 64)
+		fb, errB := strconv.ParseFloat(to, 64)
+		if errA == nil && errB == nil {
+			if fa == fb {
+				return c, false
+			}
+			delta := fb - fa
+			c.Delta = &delta
+			if def != nil && def.MinValue != nil && def.MaxValue != nil && *def.MaxValue > *def.MinValue {
+				rel := delta / (*def.MaxValue - *def.MinValue)
+				c.DeltaRange = &rel
+			}
+			return c, true
+		}
+	}
+	return c, from != to
+}
+
+// String renders the diff one change per line, for logs and support tickets.
+func (d *ProgramDiff) String() string {
+	var b strings.Builder
+	if d.Preset != nil {
+		fmt.Fprintf(&b, "preset: %q -> %q\n", d.Preset.From, d.Preset.To)
+	}
+	for _, p := range d.AddedPlugins {
+		fmt.Fprintf(&b, "+ plugin %s\n", p)
+	}
+	for _, p := range d.RemovedPlugins {
+		fmt.Fprintf(&b, "- plugin %s\n", p)
+	}
+	for _, c := range d.Chains {
+		fmt.Fprintf(&b, "chain %s: %s -> %s\n", c.Chain, strings.Join(c.From, " "), strings.Join(c.To, " "))
+	}
+	for _, c := range d.Slots {
+		fmt.Fprintf(&b, "slot %s: %s -> %s\n", c.Slot, c.From, c.To)
+	}
+	for _, c := range d.Params {
+		switch c.Change {
+		case ChangeAdded:
+			fmt.Fprintf(&b, "+ %s.%s = %s\n", c.Plugin, c.Param, c.To)
+		case ChangeRemoved:
+			fmt.Fprintf(&b, "- %s.%s (was %s)\n", c.Plugin, c.Param, c.From)
+		default:
+			fmt.Fprintf(&b, "%s.%s: %s -> %s", c.Plugin, c.Param, c.From, c.To)
+			if c.Delta != nil {
+				fmt.Fprintf(&b, " (%+g)", *c.Delta)
+			}
+			b.WriteByte('\n')
+		}
+	}
+	return b.String()
+}
+
+func setOf(names []string) map[string]struct{} {
+	m := make(map[string]struct{}, len(names))
+	for _, n := range names {
+		m[n] = struct{}{}
+	}
+	return m
+}
+
+// missingFrom returns the entries of xs that are not in ys.
+func missingFrom(xs, ys []string) []string {
+	var out []string
+	for _, x := range xs {
+		if !slices.Contains(ys, x) {
+			out = append(out, x)
+		}
+	}
+	return out
+}
+
+// keepOnly returns xs without the entries missing from ys, order preserved.
+func keepOnly(xs, ys []string) []string {
+	out := make([]string, 0, len(xs))
+	for _, x := range xs {
+		if slices.Contains(ys, x) {
+			out = append(out, x)
+		}
+	}
+	return out
+}
+
+func unionKeys[V any](a, b map[string]V) []string {
+	keys := make([]string, 0, len(a)+len(b))
+	for k := range a {
+		keys = append(keys, k)
+	}
+	for k := range b {
+		if _, ok := a[k]; !ok {
+			keys = append(keys, k)
+		}
+	}
+	sort.Strings(keys)
+	return keys
+}
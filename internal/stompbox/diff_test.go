@@ -0,0 +1,62 @@
+package stompbox
+
+import (
+	"math"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestDiffPrograms(t *testing.T) {
+	a, err := ParseDumpProgram(readSample(t, "dump_program.example.txt"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	cfg, err := ParseDumpConfig(readSample(t, "dump_config.example.txt"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if d := DiffPrograms(a, a, cfg); !d.Empty() {
+		t.Fatalf("self diff not empty:\n%s", d)
+	}
+
+	s, err := ParseProgramScript(readSample(t, "dump_program.example.txt"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	s.SetParamValue("Delay_2", "Mix", "0.75")
+	s.SetParamValue("Delay_2", "FBack", "0.5") // same number as "0.500000"
+	script := s.Serialize()
+	script = strings.Replace(script, "SetPreset 05_weel_placed_rvb", "SetPreset 06_copy", 1)
+	script = strings.Replace(script, "SetChain FxLoop Phaser_2 Chorus_2", "SetChain FxLoop Chorus_2 Phaser_2 Delay_3", 1)
+	script = strings.Replace(script, "SetPluginSlot Amp NAM", "SetPluginSlot Amp NAMMulti", 1)
+	b, err := ParseDumpProgram(script)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	d := DiffPrograms(a, b, cfg)
+	if d.Preset == nil || d.Preset.To != "06_copy" {
+		t.Fatalf("preset change: %+v", d.Preset)
+	}
+	if !reflect.DeepEqual(d.AddedPlugins, []string{"Delay_3", "NAMMulti"}) {
+		t.Fatalf("added = %v", d.AddedPlugins)
+	}
+	if len(d.Chains) != 1 || !d.Chains[0].Reordered || !reflect.DeepEqual(d.Chains[0].Added, []string{"Delay_3"}) {
+		t.Fatalf("chains = %+v", d.Chains)
+	}
+	if len(d.Slots) != 1 || d.Slots[0].From != "NAM" || d.Slots[0].To != "NAMMulti" {
+		t.Fatalf("slots = %+v", d.Slots)
+	}
+	if len(d.Params) != 1 {
+		t.Fatalf("params = %+v", d.Params)
+	}
+	p := d.Params[0]
+	if p.Plugin != "Delay_2" || p.Param != "Mix" || p.Change != ChangeValue || p.Delta == nil || *p.Delta != 0.25 {
+		t.Fatalf("param change = %+v", p)
+	}
+	// Mix is 0..1.2 in the sample config.
+	if p.DeltaRange == nil || math.Abs(*p.DeltaRange-0.25/1.2) > 1e-9 {
+		t.Fatalf("DeltaRange = %v", p.DeltaRange)
+	}
+}
@@ -0,0 +1,130 @@
+package stompbox
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"strings"
+	"time"
+)
+
+// ProtocolError is an "Error ..." line returned by Stompbox for a command.
+// The command itself reached Stompbox and was rejected.
+type ProtocolError struct {
+	Command string // command line as sent, without CRLF (empty if unknown)
+	Message string // text after the "Error" keyword
+}
+
+func (e *ProtocolError) Error() string {
+	if e.Command == "" {
+		return "Error " + e.Message
+	}
+	return fmt.Sprintf("%s: Error %s", e.Command, e.Message)
+}
+
+// TransportError is a network failure while talking to Stompbox.
+// Op is "dial", "write" or "read".
+type TransportError struct {
+	Op   string
+	Addr string
+	Err  error
+}
+
+func (e *TransportError) Error() string {
+	return fmt.Sprintf("stompbox %s %s: %v", e.Op, e.Addr, e.Err)
+}
+
+func (e *TransportError) Unwrap() error { return e.Err }
+
+// TimeoutError reports that Stompbox did not answer within the configured
+// DialTimeout/ReadTimeout. Context deadlines are returned as ctx.Err() instead.
+type TimeoutError struct {
+	Op      string
+	Command string
+	After   time.Duration
+	Err     error
+}
+
+func (e *TimeoutError) Error() string {
+	if e.Command == "" {
+		return fmt.Sprintf("stompbox %s timeout after %s", e.Op, e.After)
+	}
+	return fmt.Sprintf("stompbox %s timeout after %s (%s)", e.Op, e.After, e.Command)
+}
+
+func (e *TimeoutError) Unwrap() error { return e.Err }
+
+// Timeout makes TimeoutError satisfy the net.Error-style Timeout check.
+func (e *TimeoutError) Timeout() bool { return true }
+
+// OversizeError reports a response that grew beyond Client.MaxBytes (or
+// MaxConfigBytes for Dump Config).
+type OversizeError struct {
+	Command string
+	Limit   int
+}
+
+func (e *OversizeError) Error() string {
+	return fmt.Sprintf("response exceeded max size (%d bytes) for %s", e.Limit, e.Command)
+}
+
+// IncompleteResponseError reports a session that closed before the
+// response terminator was seen.
+type IncompleteResponseError struct {
+	Command  string
+	LastLine string
+}
+
+func (e *IncompleteResponseError) Error() string {
+	return fmt.Sprintf("incomplete response (last line=%q)", e.LastLine)
+}
+
+// UnavailableError is returned without contacting Stompbox while the client's
+// circuit breaker considers it down (see breaker.go). Err is the failure that
+// last kept it down.
+type UnavailableError struct {
+	Addr    string
+	RetryAt time.Time
+	Err     error
+}
+
+func (e *UnavailableError) Error() string {
+	msg := "stompbox unavailable at " + e.Addr
+	if wait := time.Until(e.RetryAt); wait > 0 {
+		msg += fmt.Sprintf(" (retry in %s)", wait.Round(100*time.Millisecond))
+	}
+	if e.Err != nil {
+		msg += ": " + e.Err.Error()
+	}
+	return msg
+}
+
+func (e *UnavailableError) Unwrap() error { return e.Err }
+
+// commandLabel trims the CRLF terminator for use in error values.
+func commandLabel(command string) string {
+	return strings.TrimRight(command, "\r\n")
+}
+
+// wrapIOError classifies a raw network error from dial/read/write.
+func (c *Client) wrapIOError(op, command string, err error) error {
+	var ne net.Error
+	if errors.As(err, &ne) && ne.Timeout() {
+		after := c.ReadTimeout
+		if op == "dial" {
+			after = c.DialTimeout
+		}
+		return &TimeoutError{Op: op, Command: commandLabel(command), After: after, Err: err}
+	}
+	return &TransportError{Op: op, Addr: c.Addr, Err: err}
+}
+
+// protocolError returns a *ProtocolError for the first "Error ..." line in resp, if any.
+func protocolError(command, resp string) error {
+	err := firstProtocolError(resp)
+	var pe *ProtocolError
+	if errors.As(err, &pe) {
+		pe.Command = commandLabel(command)
+	}
+	return err
+}
@@ -0,0 +1,135 @@
+package stompbox
+
+import (
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// Plugin instances are named after their type. The first instance of a type
+// is usually the bare type name ("NAM", "EQ-7"); further instances get a
+// "_N" suffix ("Delay_2"). DumpConfig describes types, Dump Program and
+// SetParam/SetChain refer to instances.
+
+// SplitInstance splits "Delay_2" into ("Delay", 2). Names without a positive
+// numeric suffix are returned unchanged with n == 0.
+func SplitInstance(instance string) (typ string, n int) {
+	instance = strings.TrimSpace(instance)
+	i := strings.LastIndexByte(instance, '_')
+	if i <= 0 {
+		return instance, 0
+	}
+	n, err := strconv.Atoi(instance[i+1:])
+	if err != nil || n <= 0 {
+		return instance, 0
+	}
+	return instance[:i], n
+}
+
+// PluginType returns the plugin type of an instance name.
+func PluginType(instance string) string {
+	typ, _ := SplitInstance(instance)
+	return typ
+}
+
+// Resolve returns the PluginDef for an instance name. An exact match wins,
+// so types whose own name ends in "_N" still resolve; otherwise the
+// instance suffix is stripped.
+func (cfg *DumpConfigParsed) Resolve(instance string) (*PluginDef, bool) {
+	if cfg == nil {
+		return nil, false
+	}
+	if p := cfg.Plugins[instance]; p != nil {
+		return p, true
+	}
+	p := cfg.Plugins[PluginType(instance)]
+	return p, p != nil
+}
+
+// ResolveParam returns the ParamDef of param on an instance.
+func (cfg *DumpConfigParsed) ResolveParam(instance, param string) (*ParamDef, bool) {
+	p, ok := cfg.Resolve(instance)
+	if !ok {
+		return nil, false
+	}
+	d := p.Params[param]
+	return d, d != nil
+}
+
+// Instances returns the instances of typ referenced anywhere in the program
+// (chains, slots or params), ordered by instance number.
+func (p *Program) Instances(typ string) []string {
+	if p == nil {
+		return nil
+	}
+	var out []string
+	for _, name := range p.instanceNames() {
+		if PluginType(name) == typ {
+			out = append(out, name)
+		}
+	}
+	sort.Slice(out, func(i, j int) bool {
+		_, a := SplitInstance(out[i])
+		_, b := SplitInstance(out[j])
+		return a < b
+	})
+	return out
+}
+
+// NextInstanceName predicts the name Stompbox assigns when typ is added to a
+// chain of this program. See NextInstanceName.
+func (p *Program) NextInstanceName(typ string) string {
+	if p == nil {
+		return typ
+	}
+	return NextInstanceName(typ, p.instanceNames())
+}
+
+// instanceNames lists every distinct instance the program refers to.
+func (p *Program) instanceNames() []string {
+	seen := make(map[string]struct{})
+	var out []string
+	add := func(name string) {
+		if name == "" {
+			return
+		}
+		if _, ok := seen[name]; ok {
+			return
+		}
+		seen[name] = struct{}{}
+		out = append(out, name)
+	}
+	for _, plugins := range p.Chains {
+		for _, name := range plugins {
+			add(name)
+		}
+	}
+	for _, name := range p.Slots {
+		add(name)
+	}
+	for name := range p.Params {
+		add(name)
+	}
+	return out
+}
+
+// NextInstanceName returns the name Stompbox gives a new instance of typ
+// given the existing instance names: the bare type if none exists, otherwise
+// "typ_N" one past the highest number in use (a bare name counts as 1).
+func NextInstanceName(typ string, existing []string) string {
+	highest := 0
+	for _, name := range existing {
+		t, n := SplitInstance(name)
+		if t != typ {
+			continue
+		}
+		if n == 0 {
+			n = 1
+		}
+		highest = max(highest, n)
+	}
+	if highest == 0 {
+		return typ
+	}
+	return typ + "_" + strconv.Itoa(highest+1)
+}
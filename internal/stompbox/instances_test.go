@@ -0,0 +1,60 @@
+package stompbox
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestSplitInstance(t *testing.T) {
+	cases := []struct {
+		in  string
+		typ string
+		n   int
+	}{
+		{"Delay_2", "Delay", 2},
+		{"NAM", "NAM", 0},
+		{"EQ-7", "EQ-7", 0},
+		{"EQ-7_3", "EQ-7", 3},
+		{"Foo_Bar", "Foo_Bar", 0},
+		{"Delay_0", "Delay_0", 0},
+		{"_2", "_2", 0},
+	}
+	for _, c := range cases {
+		if typ, n := SplitInstance(c.in); typ != c.typ || n != c.n {
+			t.Errorf("SplitInstance(%q) = %q, %d; want %q, %d", c.in, typ, n, c.typ, c.n)
+		}
+	}
+}
+
+func TestResolveAndInstances(t *testing.T) {
+	cfg := &DumpConfigParsed{Plugins: map[string]*PluginDef{
+		"Delay":   {Name: "Delay", Params: map[string]*ParamDef{"Delay": {Name: "Delay"}}},
+		"NAM":     {Name: "NAM"},
+		"Amp_800": {Name: "Amp_800"},
+	}}
+	for inst, want := range map[string]string{"Delay_2": "Delay", "Delay": "Delay", "NAM": "NAM", "Amp_800": "Amp_800"} {
+		p, ok := cfg.Resolve(inst)
+		if !ok || p.Name != want {
+			t.Errorf("Resolve(%q) = %v, %v; want %s", inst, p, ok, want)
+		}
+	}
+	if _, ok := cfg.Resolve("Reverb_2"); ok {
+		t.Error("Resolve(Reverb_2) should miss")
+	}
+	if _, ok := cfg.ResolveParam("Delay_3", "Delay"); !ok {
+		t.Error("ResolveParam(Delay_3, Delay) should hit")
+	}
+
+	prog, err := ParseDumpProgram("SetChain Chain1 Delay_3 NAM\r\nSetPluginSlot Amp NAM\r\nSetParam Delay Mix 0.5\r\nEndProgram\r\nOk\r\n")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, want := prog.Instances("Delay"), []string{"Delay", "Delay_3"}; !reflect.DeepEqual(got, want) {
+		t.Fatalf("Instances(Delay) = %v, want %v", got, want)
+	}
+	for typ, want := range map[string]string{"Delay": "Delay_4", "NAM": "NAM_2", "Reverb": "Reverb"} {
+		if got := prog.NextInstanceName(typ); got != want {
+			t.Errorf("NextInstanceName(%s) = %s, want %s", typ, got, want)
+		}
+	}
+}
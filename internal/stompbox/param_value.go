@@ -0,0 +1,167 @@
+package stompbox
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+)
+
+// ParamDef types reported by Dump Config.
+const (
+	ParamKnob    = "Knob"
+	ParamVSlider = "VSlider"
+	ParamPower   = "Power"
+	ParamBool    = "Bool"
+	ParamInt     = "Int"
+	ParamEnum    = "Enum"
+	ParamFile    = "File"
+)
+
+// EnabledParam describes the Enabled switch every plugin has but Dump
+// Config doesn't list.
+var EnabledParam = &ParamDef{Name: "Enabled", Type: ParamBool, MinValue: floatPtr(0), MaxValue: floatPtr(1)}
+
+// RangeError reports a value outside a ParamDef's MinValue..MaxValue.
+type RangeError struct {
+	Param    string
+	Value    float64
+	Min, Max float64
+}
+
+func (e *RangeError) Error() string {
+	return fmt.Sprintf("%s = %s is out of range %s..%s", e.Param,
+		FormatParamValue(e.Value), FormatParamValue(e.Min), FormatParamValue(e.Max))
+}
+
+// IsBool reports whether the param is an on/off switch.
+func (d *ParamDef) IsBool() bool { return d.Type == ParamBool }
+
+// IsIntegral reports whether the param only takes whole numbers.
+func (d *ParamDef) IsIntegral() bool { return d.Type == ParamInt || d.Type == ParamEnum }
+
+// EnumValue maps the name of an Enum value (case-insensitive) to the number
+// SetParam takes: MinValue plus its index in EnumValues.
+func (d *ParamDef) EnumValue(name string) (float64, bool) {
+	name = strings.TrimSpace(name)
+	for i, v := range d.EnumValues {
+		if strings.EqualFold(v, name) {
+			base := 0.0
+			if d.MinValue != nil {
+				base = *d.MinValue
+			}
+			return base + float64(i), true
+		}
+	}
+	return 0, false
+}
+
+// CheckValue validates x for the param and returns the value to send. Bool
+// params are coerced to 0/1 (any non-zero value is on) and integral params
+// rounded. A value outside MinValue..MaxValue is a *RangeError, or with
+// clamp is pulled to the nearest bound (clamped reports it).
+func (d *ParamDef) CheckValue(x float64, clamp bool) (v float64, clamped bool, err error) {
+	if math.IsNaN(x) || math.IsInf(x, 0) {
+		return 0, false, fmt.Errorf("%s: invalid number", d.Name)
+	}
+	switch {
+	case d.IsBool():
+		if x != 0 {
+			return 1, false, nil
+		}
+		return 0, false, nil
+	case d.IsIntegral():
+		x = math.Round(x)
+	}
+	if d.MinValue != nil && d.MaxValue != nil && *d.MinValue <= *d.MaxValue {
+		lo, hi := *d.MinValue, *d.MaxValue
+		if x < lo || x > hi {
+			if !clamp {
+				return x, false, &RangeError{Param: d.Name, Value: x, Min: lo, Max: hi}
+			}
+			return math.Min(math.Max(x, lo), hi), true, nil
+		}
+	}
+	return x, false, nil
+}
+
+// FormatParamValue renders a number as a SetParam token: plain decimal,
+// no exponent, no trailing zeros.
+func FormatParamValue(x float64) string {
+	return strconv.FormatFloat(x, 'f', -1, 64)
+}
+
+func floatPtr(f float64) *float64 { return &f }
+
+// ErrNoRange means a param has no usable MinValue..MaxValue.
+var ErrNoRange = errors.New("param has no value range")
+
+// rangeOf returns the param's bounds and curve exponent (RangePower,
+// defaulting to 1).
+func (d *ParamDef) rangeOf() (lo, hi, power float64, err error) {
+	if d.Type == ParamFile || d.MinValue == nil || d.MaxValue == nil || *d.MinValue >= *d.MaxValue {
+		return 0, 0, 0, fmt.Errorf("%s: %w", d.Name, ErrNoRange)
+	}
+	power = 1
+	if d.RangePower != nil && *d.RangePower > 0 {
+		power = *d.RangePower
+	}
+	return *d.MinValue, *d.MaxValue, power, nil
+}
+
+// Denormalize maps a control position in 0..1 to a param value the way the
+// Stompbox dials do: MinValue + (MaxValue-MinValue) * pos^RangePower. pos is
+// clamped to 0..1; Bool params switch at 0.5.
+func (d *ParamDef) Denormalize(pos float64) (float64, error) {
+	lo, hi, power, err := d.rangeOf()
+	if err != nil {
+		return 0, err
+	}
+	if math.IsNaN(pos) || math.IsInf(pos, 0) {
+		return 0, fmt.Errorf("%s: invalid position", d.Name)
+	}
+	pos = math.Min(math.Max(pos, 0), 1)
+	if d.IsBool() {
+		pos = math.Round(pos)
+	}
+	return lo + (hi-lo)*math.Pow(pos, power), nil
+}
+
+// Normalize is the inverse of Denormalize: the 0..1 position of value x.
+// Values outside the range map to the nearest end.
+func (d *ParamDef) Normalize(x float64) (float64, error) {
+	lo, hi, power, err := d.rangeOf()
+	if err != nil {
+		return 0, err
+	}
+	if math.IsNaN(x) || math.IsInf(x, 0) {
+		return 0, fmt.Errorf("%s: invalid number", d.Name)
+	}
+	t := math.Min(math.Max((x-lo)/(hi-lo), 0), 1)
+	return math.Pow(t, 1/power), nil
+}
+
+// Resolution is the smallest meaningful change of the param: 1 for Bool,
+// Int and Enum params, else the precision of ValueFormat ("{0:0.00}" is
+// 0.01). Returns 0 when the format doesn't say.
+func (d *ParamDef) Resolution() float64 {
+	if d.IsBool() || d.IsIntegral() {
+		return 1
+	}
+	// ValueFormat is a .NET format string: "{0:0.0}dB".
+	f := d.ValueFormat
+	i := strings.Index(f, ":")
+	j := strings.Index(f, "}")
+	if i < 0 || j < i {
+		return 0
+	}
+	spec := f[i+1 : j]
+	if k := strings.IndexByte(spec, '.'); k >= 0 {
+		return math.Pow(10, -float64(strings.Count(spec[k+1:], "0")+strings.Count(spec[k+1:], "#")))
+	}
+	if strings.ContainsAny(spec, "0#") {
+		return 1
+	}
+	return 0
+}
@@ -0,0 +1,113 @@
+package stompbox
+
+import (
+	"errors"
+	"math"
+	"testing"
+)
+
+func TestParamDefCheckValue(t *testing.T) {
+	cfg, err := ParseDumpConfig(readSample(t, "dump_config.example.txt"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	gain, ok := cfg.ResolveParam("Boost_2", "Gain") // Knob 0..20
+	if !ok {
+		t.Fatal("Boost.Gain missing from sample")
+	}
+
+	if v, clamped, err := gain.CheckValue(12.5, false); err != nil || clamped || v != 12.5 {
+		t.Fatalf("in range: %v %v %v", v, clamped, err)
+	}
+	var re *RangeError
+	if _, _, err := gain.CheckValue(500, false); !errors.As(err, &re) || re.Max != 20 {
+		t.Fatalf("out of range: %v", err)
+	}
+	if v, clamped, err := gain.CheckValue(500, true); err != nil || !clamped || v != 20 {
+		t.Fatalf("clamp high: %v %v %v", v, clamped, err)
+	}
+	if v, clamped, _ := gain.CheckValue(-3, true); !clamped || v != 0 {
+		t.Fatalf("clamp low: %v %v", v, clamped)
+	}
+
+	octave, _ := cfg.ResolveParam("Fuzz", "Octave") // Bool
+	if v, _, err := octave.CheckValue(0.7, false); err != nil || v != 1 {
+		t.Fatalf("bool coerce: %v %v", v, err)
+	}
+	if v, _, _ := EnabledParam.CheckValue(0, false); v != 0 {
+		t.Fatalf("Enabled off: %v", v)
+	}
+
+	steps := &ParamDef{Name: "Steps", Type: ParamInt, MinValue: floatPtr(1), MaxValue: floatPtr(8)}
+	if v, _, err := steps.CheckValue(2.6, false); err != nil || v != 3 {
+		t.Fatalf("int round: %v %v", v, err)
+	}
+	lfo, err := ParseDumpConfig("PluginConfig LFO Description \"LFO\"\r\n" +
+		"ParameterConfig LFO Wave Type Enum MinValue 0 MaxValue 2 EnumValues Sine Triangle \"Square Wave\" Description \"Shape\"\r\n" +
+		"EndConfig\r\n")
+	if err != nil {
+		t.Fatal(err)
+	}
+	wave, _ := lfo.ResolveParam("LFO", "Wave")
+	if wave == nil || len(wave.EnumValues) != 3 || wave.Description != "Shape" {
+		t.Fatalf("EnumValues not parsed: %+v", wave)
+	}
+	if v, ok := wave.EnumValue("square wave"); !ok || v != 2 {
+		t.Fatalf("EnumValue(square wave) = %v %v", v, ok)
+	}
+	if _, ok := wave.EnumValue("Saw"); ok {
+		t.Fatal("EnumValue(Saw) found")
+	}
+	if got := FormatParamValue(3); got != "3" {
+		t.Fatalf("FormatParamValue(3) = %q", got)
+	}
+}
+
+func TestParamDefNormalize(t *testing.T) {
+	cfg, err := ParseDumpConfig(readSample(t, "dump_config.example.txt"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	freq, ok := cfg.ResolveParam("NAMMulti", "Freq") // 10..1000, RangePower 3
+	if !ok {
+		t.Fatal("NAMMulti.Freq missing from sample")
+	}
+	if v, err := freq.Denormalize(0.5); err != nil || v != 133.75 {
+		t.Fatalf("Denormalize(0.5) = %v, %v", v, err)
+	}
+	if p, err := freq.Normalize(133.75); err != nil || math.Abs(p-0.5) > 1e-9 {
+		t.Fatalf("Normalize(133.75) = %v, %v", p, err)
+	}
+	if p, _ := freq.Normalize(5000); p != 1 {
+		t.Fatalf("Normalize above max = %v", p)
+	}
+
+	gain, _ := cfg.ResolveParam("Boost", "Gain") // 0..20, linear
+	if v, _ := gain.Denormalize(0.25); v != 5 {
+		t.Fatalf("linear Denormalize(0.25) = %v", v)
+	}
+	if v, _ := EnabledParam.Denormalize(0.4); v != 0 {
+		t.Fatalf("bool Denormalize(0.4) = %v", v)
+	}
+	if _, err := (&ParamDef{Name: "Model", Type: ParamFile}).Normalize(0); !errors.Is(err, ErrNoRange) {
+		t.Fatalf("File param: %v", err)
+	}
+}
+
+func TestParamDefResolution(t *testing.T) {
+	for _, tc := range []struct {
+		def  ParamDef
+		want float64
+	}{
+		{ParamDef{Type: ParamKnob, ValueFormat: "{0:0.0}dB"}, 0.1},
+		{ParamDef{Type: ParamKnob, ValueFormat: "{0:0.00}"}, 0.01},
+		{ParamDef{Type: ParamKnob, ValueFormat: "{0:0}hz"}, 1},
+		{ParamDef{Type: ParamKnob}, 0},
+		{ParamDef{Type: ParamEnum}, 1},
+		{ParamDef{Type: ParamBool, ValueFormat: "{0:0.00}"}, 1},
+	} {
+		if got := tc.def.Resolution(); math.Abs(got-tc.want) > 1e-12 {
+			t.Errorf("%q %s: Resolution() = %v, want %v", tc.def.ValueFormat, tc.def.Type, got, tc.want)
+		}
+	}
+}
@@ -8,8 +8,9 @@ import (
 )
 
 type DumpConfigParsed struct {
-	Plugins map[string]*PluginDef `json:"plugins"`
-	Order   []string              `json:"order,omitempty"` // optional stable order if you want
+	Plugins     map[string]*PluginDef `json:"plugins"`
+	Order       []string              `json:"order,omitempty"` // optional stable order if you want
+	Diagnostics Diagnostics           `json:"diagnostics,omitempty"`
 }
 
 type PluginDef struct {
@@ -19,6 +20,7 @@ type PluginDef struct {
 	IsUserSelectable *bool                   `json:"isUserSelectable,omitempty"`
 	Description      string                  `json:"description,omitempty"`
 	Params           map[string]*ParamDef    `json:"params,omitempty"`
+	ParamOrder       []string                `json:"paramOrder,omitempty"` // ParameterConfig order as dumped
 	FileTrees        map[string]*FileTreeDef `json:"fileTrees,omitempty"`
 }
 
@@ -35,126 +37,24 @@ type ParamDef struct {
 	IsAdvanced       *bool             `json:"isAdvanced,omitempty"`
 	IsOutput         *bool             `json:"isOutput,omitempty"`
 	Description      string            `json:"description,omitempty"`
-	RawKV            map[string]string `json:"rawKV,omitempty"` // keeps unknown keys without losing info
+	EnumValues       []string          `json:"enumValues,omitempty"` // names of Enum values, from MinValue up
+	RawKV            map[string]string `json:"rawKV,omitempty"`      // keeps unknown keys without losing info
 }
 
+// ParseDumpConfig parses a buffered Dump Config response. For responses too
+// large to buffer use ConfigDecoder or Client.DumpConfigStreamCtx.
 func ParseDumpConfig(raw string) (*DumpConfigParsed, error) {
-	out := &DumpConfigParsed{
-		Plugins: make(map[string]*PluginDef),
-	}
-
-	var currentPlugin string // used for recovery when lines omit the plugin name (NAMMulti case)
-
-	lines := strings.Split(raw, "\n")
-	for _, line := range lines {
-		line = strings.TrimSpace(line)
-		if line == "" {
-			continue
-		}
-		if line == "Ok" {
+	var (
+		p configParser
+		b = newConfigBuilder()
+	)
+	for i, line := range strings.Split(raw, "\n") {
+		p.feed(i+1, line, false, b.apply)
+		if p.done {
 			break
 		}
-
-		toks := splitQuoted(line)
-		if len(toks) == 0 {
-			continue
-		}
-
-		switch toks[0] {
-
-		case "PluginConfig":
-			// PluginConfig <Plugin> BackgroundColor #... ForegroundColor #... IsUserSelectable 1 Description "..."
-			if len(toks) < 2 {
-				// malformed, ignore
-				continue
-			}
-			pname := toks[1]
-			currentPlugin = pname
-			p := ensurePlugin(out, pname)
-			applyPluginKV(p, toks[2:])
-
-		case "ParameterConfig":
-			// ParameterConfig <Plugin> <Param> Type Knob MinValue ... Description "..."
-			//
-			// BUT: you have malformed lines like:
-			// ParameterConfig  Gain Type Knob ...
-			// (plugin omitted) -> recover using currentPlugin, and treat first token after ParameterConfig as Param
-			if len(toks) < 3 {
-				// too short to be meaningful
-				continue
-			}
-
-			var pname, param string
-			startKV := 0
-
-			// Normal case: ParameterConfig <Plugin> <Param> ...
-			if toks[1] != "" && toks[2] != "" && toks[1] != "Type" {
-				pname = toks[1]
-				param = toks[2]
-				startKV = 3
-			} else {
-				// Recovery case: assume toks[1] is param and plugin is currentPlugin
-				pname = currentPlugin
-				param = toks[1]
-				startKV = 2
-			}
-
-			if pname == "" || param == "" {
-				// can't safely attach
-				continue
-			}
-
-			p := ensurePlugin(out, pname)
-			if p.Params == nil {
-				p.Params = make(map[string]*ParamDef)
-			}
-			def := &ParamDef{
-				Plugin: pname,
-				Name:   param,
-				RawKV:  make(map[string]string),
-			}
-			applyParamKV(def, toks[startKV:])
-			p.Params[param] = def
-
-		case "ParameterFileTree":
-			if len(toks) < 4 {
-				continue
-			}
-			pname := toks[1]
-			param := toks[2]
-			category := toks[3]
-			currentPlugin = pname
-
-			p := ensurePlugin(out, pname)
-			if p.FileTrees == nil {
-				p.FileTrees = make(map[string]*FileTreeDef)
-			}
-
-			items := []string{}
-			if len(toks) > 4 {
-				items = toks[4:]
-			}
-
-			tree := &FileTreeDef{
-				Plugin:   pname,
-				Param:    param,
-				Category: category,
-				Items:    items,
-				Options:  fileOptionsFromItems(items),
-			}
-			p.FileTrees[param] = tree
-
-		case "EndConfig":
-			// end of plugin block (we keep currentPlugin as last plugin for recovery)
-			continue
-
-		default:
-			// ignore unknown lines
-			continue
-		}
 	}
-
-	return out, nil
+	return b.out, nil
 }
 
 func ensurePlugin(out *DumpConfigParsed, name string) *PluginDef {
@@ -179,10 +79,14 @@ func fileOptionsFromItems(items []string) []FileOption {
 	return opts
 }
 
-func applyPluginKV(p *PluginDef, kv []string) {
+// kvReport receives anomalies found in a key/value list (see Diagnostics).
+type kvReport func(sev Severity, msg, recovery string)
+
+func applyPluginKV(p *PluginDef, kv []string, report kvReport) {
 	for i := 0; i < len(kv); i++ {
 		k := kv[i]
 		if i+1 >= len(kv) {
+			report(SeverityWarning, "key "+k+" without value", "key ignored")
 			break
 		}
 		v := kv[i+1]
@@ -203,15 +107,32 @@ func applyPluginKV(p *PluginDef, kv []string) {
 			i++
 		default:
 			// unknown plugin-level keys are ignored for now
+			report(SeverityInfo, "unknown PluginConfig key "+k, "key ignored")
 			i++
 		}
 	}
 }
 
-func applyParamKV(p *ParamDef, kv []string) {
+// paramKeys are the ParameterConfig keys applyParamKV knows; they end an
+// EnumValues list.
+var paramKeys = map[string]bool{
+	"Type": true, "MinValue": true, "MaxValue": true, "DefaultValue": true,
+	"RangePower": true, "ValueFormat": true, "CanSyncToHostBPM": true,
+	"IsAdvanced": true, "IsOutput": true, "Description": true, "EnumValues": true,
+}
+
+func applyParamKV(p *ParamDef, kv []string, report kvReport) {
+	num := func(k, v string) *float64 {
+		f := parseFloatPtr(v)
+		if f == nil {
+			report(SeverityWarning, fmt.Sprintf("%s %s: %s %q is not a number", p.Plugin, p.Name, k, v), "value left unset")
+		}
+		return f
+	}
 	for i := 0; i < len(kv); i++ {
 		k := kv[i]
 		if i+1 >= len(kv) {
+			report(SeverityWarning, "key "+k+" without value", "key ignored")
 			break
 		}
 		v := kv[i+1]
@@ -221,16 +142,16 @@ func applyParamKV(p *ParamDef, kv []string) {
 			p.Type = v
 			i++
 		case "MinValue":
-			p.MinValue = parseFloatPtr(v)
+			p.MinValue = num(k, v)
 			i++
 		case "MaxValue":
-			p.MaxValue = parseFloatPtr(v)
+			p.MaxValue = num(k, v)
 			i++
 		case "DefaultValue":
-			p.DefaultValue = parseFloatPtr(v)
+			p.DefaultValue = num(k, v)
 			i++
 		case "RangePower":
-			p.RangePower = parseFloatPtr(v)
+			p.RangePower = num(k, v)
 			i++
 		case "ValueFormat":
 			p.ValueFormat = v
@@ -250,8 +171,16 @@ func applyParamKV(p *ParamDef, kv []string) {
 		case "Description":
 			p.Description = v
 			i++
+		case "EnumValues":
+			// EnumValues Sine Triangle "Square Wave" ... up to the next key.
+			p.EnumValues = p.EnumValues[:0]
+			for i+1 < len(kv) && !paramKeys[kv[i+1]] {
+				p.EnumValues = append(p.EnumValues, kv[i+1])
+				i++
+			}
 		default:
 			// preserve unhandled keys for future UI/debug
+			report(SeverityInfo, "unknown ParameterConfig key "+k, "kept in rawKV")
 			if p.RawKV == nil {
 				p.RawKV = make(map[string]string)
 			}
@@ -278,8 +207,16 @@ func parseBool01(s string) bool {
 	}
 }
 
+// Tokenize splits a protocol line into tokens the way Stompbox reads them (std::quoted):
+// whitespace separates tokens, double quotes group them and are removed.
+func Tokenize(line string) []string {
+	return splitQuoted(line)
+}
+
 // splitQuoted splits a line into tokens while preserving quoted strings (without quotes).
 // Example: Description "Clean boost effect" -> ["Description", "Clean boost effect"]
+// A quoted empty string is a token of its own: SetParam NAM Model "" has an
+// empty value, not a missing one.
 func splitQuoted(s string) []string {
 	var out []string
 	var cur strings.Builder
@@ -308,9 +245,10 @@ func splitQuoted(s string) []string {
 
 		if r == '"' {
 			if inQuote {
-				// closing quote
+				// closing quote: keep the token even if empty
 				inQuote = false
-				flush()
+				out = append(out, cur.String())
+				cur.Reset()
 			} else {
 				// opening quote; flush token built so far
 				flush()
@@ -327,15 +265,7 @@ func splitQuoted(s string) []string {
 		cur.WriteRune(r)
 	}
 	flush()
-
-	// normalize: remove any empty tokens
-	n := out[:0]
-	for _, t := range out {
-		if strings.TrimSpace(t) != "" {
-			n = append(n, t)
-		}
-	}
-	return n
+	return out
 }
 
 func (p *ParamDef) Validate() error {
@@ -0,0 +1,270 @@
+package stompbox
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"strings"
+)
+
+// ConfigEvent is one item of a streamed Dump Config. Exactly one of Plugin,
+// Param, FileTree or Diagnostic is set.
+type ConfigEvent struct {
+	Line       int
+	Plugin     *PluginDef // PluginConfig keys only; params and file trees arrive as their own events
+	Param      *ParamDef
+	FileTree   *FileTreeDef
+	Diagnostic *Diagnostic
+}
+
+// ConfigDecoder parses Dump Config from an io.Reader one line at a time,
+// so only the current section (one directive line) is held in memory.
+type ConfigDecoder struct {
+	// MaxSectionBytes caps a single line (0 = unlimited). A longer
+	// ParameterFileTree keeps its leading items and is marked Truncated;
+	// other long lines are dropped. Both raise a diagnostic.
+	MaxSectionBytes int
+
+	r       *bufio.Reader
+	p       configParser
+	line    int
+	pending []ConfigEvent
+	err     error
+}
+
+// NewConfigDecoder returns a decoder reading from r.
+func NewConfigDecoder(r io.Reader) *ConfigDecoder {
+	return &ConfigDecoder{r: bufio.NewReader(r)}
+}
+
+// Next returns the next event, or io.EOF after the final Ok (or end of input).
+func (d *ConfigDecoder) Next() (ConfigEvent, error) {
+	for len(d.pending) == 0 {
+		if d.err != nil {
+			return ConfigEvent{}, d.err
+		}
+		if d.p.done {
+			d.err = io.EOF
+			continue
+		}
+		line, truncated, _, err := readCappedLine(d.r, d.MaxSectionBytes)
+		if line != "" {
+			d.line++
+			d.p.feed(d.line, line, truncated, func(ev ConfigEvent) {
+				d.pending = append(d.pending, ev)
+			})
+		}
+		if err != nil {
+			d.err = err
+		}
+	}
+	ev := d.pending[0]
+	d.pending = d.pending[1:]
+	return ev, nil
+}
+
+// configParser turns Dump Config lines into events. It only keeps the state
+// needed across lines: the current plugin (for the NAMMulti recovery) and
+// the params seen so far (for duplicate detection).
+type configParser struct {
+	currentPlugin string
+	seen          map[string]bool // plugin + "\x00" + param
+	done          bool            // final Ok seen
+}
+
+// feed parses one line. truncated reports that readCappedLine cut it.
+func (p *configParser) feed(lineNo int, line string, truncated bool, emit func(ConfigEvent)) {
+	if p.done {
+		return
+	}
+	line = strings.TrimSpace(line)
+	if line == "" {
+		return
+	}
+	if line == "Ok" {
+		p.done = true
+		return
+	}
+
+	toks := splitQuoted(line)
+	if len(toks) == 0 {
+		return
+	}
+	directive := toks[0]
+	report := func(sev Severity, msg, recovery string) {
+		emit(ConfigEvent{Line: lineNo, Diagnostic: &Diagnostic{
+			Line: lineNo, Severity: sev, Directive: directive, Message: msg, Recovery: recovery,
+		}})
+	}
+
+	if truncated && directive != "ParameterFileTree" {
+		report(SeverityError, directive+" line exceeds the section size limit", "line skipped")
+		return
+	}
+
+	switch directive {
+
+	case "PluginConfig":
+		// PluginConfig <Plugin> BackgroundColor #... ForegroundColor #... IsUserSelectable 1 Description "..."
+		if len(toks) < 2 {
+			report(SeverityError, "PluginConfig without plugin name", "line skipped")
+			return
+		}
+		pname := toks[1]
+		p.currentPlugin = pname
+		def := &PluginDef{Name: pname}
+		applyPluginKV(def, toks[2:], report)
+		emit(ConfigEvent{Line: lineNo, Plugin: def})
+
+	case "ParameterConfig":
+		// ParameterConfig <Plugin> <Param> Type Knob MinValue ... Description "..."
+		//
+		// BUT: you have malformed lines like:
+		// ParameterConfig  Gain Type Knob ...
+		// (plugin omitted) -> recover using currentPlugin, and treat first token after ParameterConfig as Param
+		if len(toks) < 3 {
+			report(SeverityError, "ParameterConfig too short", "line skipped")
+			return
+		}
+
+		var pname, param string
+		startKV := 0
+
+		// splitQuoted drops the empty plugin token, so the omitted-plugin
+		// form shows up as the key list starting one token early.
+		if toks[2] != "Type" {
+			pname = toks[1]
+			param = toks[2]
+			startKV = 3
+		} else {
+			// Recovery case: assume toks[1] is param and plugin is currentPlugin
+			pname = p.currentPlugin
+			param = toks[1]
+			startKV = 2
+			if pname != "" {
+				report(SeverityWarning, "ParameterConfig without plugin name for "+param, "attached to preceding plugin "+pname)
+			}
+		}
+
+		if pname == "" || param == "" {
+			report(SeverityError, "ParameterConfig without plugin name and no preceding PluginConfig", "line skipped")
+			return
+		}
+
+		def := &ParamDef{
+			Plugin: pname,
+			Name:   param,
+			RawKV:  make(map[string]string),
+		}
+		applyParamKV(def, toks[startKV:], report)
+
+		if p.seen == nil {
+			p.seen = make(map[string]bool)
+		}
+		if key := pname + "\x00" + param; p.seen[key] {
+			report(SeverityWarning, "duplicate ParameterConfig "+pname+" "+param, "later definition wins")
+		} else {
+			p.seen[key] = true
+		}
+		emit(ConfigEvent{Line: lineNo, Param: def})
+
+	case "ParameterFileTree":
+		if len(toks) < 4 {
+			report(SeverityError, "ParameterFileTree too short", "line skipped")
+			return
+		}
+		pname := toks[1]
+		param := toks[2]
+		category := toks[3]
+		p.currentPlugin = pname
+
+		items := []string{}
+		if len(toks) > 4 {
+			items = toks[4:]
+		}
+		if truncated {
+			report(SeverityWarning, fmt.Sprintf("file tree %s %s exceeds the section size limit", pname, param),
+				fmt.Sprintf("kept the first %d items", len(items)))
+		}
+
+		emit(ConfigEvent{Line: lineNo, FileTree: &FileTreeDef{
+			Plugin:    pname,
+			Param:     param,
+			Category:  category,
+			Items:     items,
+			Options:   fileOptionsFromItems(items),
+			Truncated: truncated,
+		}})
+
+	case "EndConfig":
+		// end of plugin block (we keep currentPlugin as last plugin for recovery)
+
+	default:
+		report(SeverityWarning, "unknown directive "+directive, "line ignored")
+	}
+}
+
+// configBuilder assembles events into a DumpConfigParsed.
+type configBuilder struct {
+	out *DumpConfigParsed
+}
+
+func newConfigBuilder() *configBuilder {
+	return &configBuilder{out: &DumpConfigParsed{Plugins: make(map[string]*PluginDef)}}
+}
+
+func (b *configBuilder) apply(ev ConfigEvent) {
+	switch {
+	case ev.Plugin != nil:
+		p := ensurePlugin(b.out, ev.Plugin.Name)
+		if ev.Plugin.BackgroundColor != "" {
+			p.BackgroundColor = ev.Plugin.BackgroundColor
+		}
+		if ev.Plugin.ForegroundColor != "" {
+			p.ForegroundColor = ev.Plugin.ForegroundColor
+		}
+		if ev.Plugin.IsUserSelectable != nil {
+			p.IsUserSelectable = ev.Plugin.IsUserSelectable
+		}
+		if ev.Plugin.Description != "" {
+			p.Description = ev.Plugin.Description
+		}
+
+	case ev.Param != nil:
+		p := ensurePlugin(b.out, ev.Param.Plugin)
+		if p.Params == nil {
+			p.Params = make(map[string]*ParamDef)
+		}
+		if _, dup := p.Params[ev.Param.Name]; !dup {
+			p.ParamOrder = append(p.ParamOrder, ev.Param.Name)
+		}
+		p.Params[ev.Param.Name] = ev.Param
+
+	case ev.FileTree != nil:
+		p := ensurePlugin(b.out, ev.FileTree.Plugin)
+		if p.FileTrees == nil {
+			p.FileTrees = make(map[string]*FileTreeDef)
+		}
+		p.FileTrees[ev.FileTree.Param] = ev.FileTree
+
+	case ev.Diagnostic != nil:
+		b.out.Diagnostics = append(b.out.Diagnostics, *ev.Diagnostic)
+	}
+}
+
+// ReadDumpConfig parses a whole Dump Config from r with a ConfigDecoder.
+func ReadDumpConfig(r io.Reader, maxSectionBytes int) (*DumpConfigParsed, error) {
+	d := NewConfigDecoder(r)
+	d.MaxSectionBytes = maxSectionBytes
+	b := newConfigBuilder()
+	for {
+		ev, err := d.Next()
+		if err == io.EOF {
+			return b.out, nil
+		}
+		if err != nil {
+			return nil, err
+		}
+		b.apply(ev)
+	}
+}
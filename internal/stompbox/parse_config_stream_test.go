@@ -0,0 +1,99 @@
+package stompbox
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestConfigDecoderMatchesParse(t *testing.T) {
+	raw := readSample(t, "dump_config.example.txt")
+	want, err := ParseDumpConfig(raw)
+	if err != nil {
+		t.Fatal(err)
+	}
+	got, err := ReadDumpConfig(strings.NewReader(raw), 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Fatal("streamed parse differs from ParseDumpConfig")
+	}
+}
+
+// bigFileTree returns a Dump Config whose NAM file tree has n quoted items.
+func bigFileTree(n int) string {
+	var b strings.Builder
+	b.WriteString("PluginConfig NAM IsUserSelectable 1\r\n")
+	b.WriteString("ParameterConfig NAM Model Type File\r\n")
+	b.WriteString("ParameterFileTree NAM Model NAM")
+	for i := 0; i < n; i++ {
+		fmt.Fprintf(&b, " \"model %04d\"", i)
+	}
+	b.WriteString("\r\nEndConfig\r\nOk\r\n")
+	return b.String()
+}
+
+func TestConfigDecoderSectionLimit(t *testing.T) {
+	cfg, err := ReadDumpConfig(strings.NewReader(bigFileTree(1000)), 1000)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tree := cfg.Plugins["NAM"].FileTrees["Model"]
+	if !tree.Truncated || len(tree.Items) == 0 || len(tree.Items) >= 1000 {
+		t.Fatalf("tree: truncated=%v items=%d", tree.Truncated, len(tree.Items))
+	}
+	for i, it := range tree.Items {
+		if it != fmt.Sprintf("model %04d", i) {
+			t.Fatalf("item %d = %q: cut mid-token", i, it)
+		}
+	}
+	if len(cfg.Diagnostics) != 1 || cfg.Diagnostics[0].Line != 3 {
+		t.Fatalf("diagnostics: %v", cfg.Diagnostics)
+	}
+	// Parsing continues after the truncated line.
+	if cfg.Plugins["NAM"].Params["Model"] == nil {
+		t.Fatal("lost the ParameterConfig")
+	}
+}
+
+func TestClientDumpConfigStream(t *testing.T) {
+	dump := bigFileTree(5000)
+	addr, _, _ := replyServer(t, func(cmd string) string { return dump })
+	c := New(addr)
+	c.MaxBytes = 1000 // would fail a buffered dump
+	c.MaxSectionBytes = 10_000
+	defer c.Close()
+
+	cfg, err := c.DumpConfigParsedCtx(context.Background())
+	if err != nil {
+		t.Fatalf("DumpConfigParsedCtx: %v", err)
+	}
+	if tree := cfg.Plugins["NAM"].FileTrees["Model"]; !tree.Truncated || len(tree.Items) == 0 {
+		t.Fatalf("tree not truncated: %d items", len(tree.Items))
+	}
+
+	raw, err := c.DumpConfigCtx(context.Background())
+	if err != nil {
+		t.Fatalf("DumpConfigCtx: %v", err)
+	}
+	if !strings.HasSuffix(raw, "EndConfig\r\nOk\r\n") || len(raw) > 10_200 {
+		t.Fatalf("raw dump: %d bytes", len(raw))
+	}
+	if again, _ := ParseDumpConfig(raw); len(again.Plugins["NAM"].FileTrees["Model"].Items) == 0 {
+		t.Fatal("truncated raw dump no longer parses")
+	}
+
+	// The buffered dump is capped in total; the stream is not.
+	c.MaxConfigBytes = 5000
+	var oe *OversizeError
+	if _, err := c.DumpConfigCtx(context.Background()); !errors.As(err, &oe) {
+		t.Fatalf("DumpConfigCtx over MaxConfigBytes: %v", err)
+	}
+	if _, err := c.DumpConfigParsedCtx(context.Background()); err != nil {
+		t.Fatalf("DumpConfigParsedCtx after a capped dump: %v", err)
+	}
+}
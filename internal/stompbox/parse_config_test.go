@@ -0,0 +1,43 @@
+package stompbox
+
+import (
+	"slices"
+	"testing"
+)
+
+func TestTokenizeEmptyQuoted(t *testing.T) {
+	cases := []struct {
+		in   string
+		want []string
+	}{
+		{`SetParam NAM_1 Model ""`, []string{"SetParam", "NAM_1", "Model", ""}},
+		{`SetParam NAM_1 Model "  "`, []string{"SetParam", "NAM_1", "Model", "  "}},
+		{`Description "" IsAdvanced 0`, []string{"Description", "", "IsAdvanced", "0"}},
+		{`a "" "" b`, []string{"a", "", "", "b"}},
+		// Runs of unquoted whitespace still separate without empty tokens.
+		{"  SetChain   Input  Boost_1 \t ", []string{"SetChain", "Input", "Boost_1"}},
+		{`Description "Clean boost effect"`, []string{"Description", "Clean boost effect"}},
+	}
+	for _, tc := range cases {
+		if got := Tokenize(tc.in); !slices.Equal(got, tc.want) {
+			t.Errorf("Tokenize(%q) = %q; want %q", tc.in, got, tc.want)
+		}
+	}
+}
+
+func TestParseDumpConfigEmptyDescription(t *testing.T) {
+	cfg, err := ParseDumpConfig("PluginConfig Boost Description \"\" IsUserSelectable 1\r\n" +
+		"ParameterConfig Boost Gain Type Knob Description \"\" MinValue 0 MaxValue 20\r\n" +
+		"EndConfig\r\nOk\r\n")
+	if err != nil {
+		t.Fatal(err)
+	}
+	// An empty value keeps its key paired, so the keys after it still parse.
+	gain, ok := cfg.ResolveParam("Boost", "Gain")
+	if !ok || gain.Description != "" || gain.MaxValue == nil || *gain.MaxValue != 20 {
+		t.Fatalf("Boost.Gain = %+v", gain)
+	}
+	if p := cfg.Plugins["Boost"]; p == nil || p.IsUserSelectable == nil || !*p.IsUserSelectable {
+		t.Fatalf("Boost = %+v", p)
+	}
+}
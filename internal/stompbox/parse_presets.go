@@ -0,0 +1,73 @@
+package stompbox
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+// Preset is one entry of a "List Presets" response.
+type Preset struct {
+	Name        string `json:"name"`             // as stored by Stompbox, e.g. "05_weel_placed_rvb"
+	Number      string `json:"number,omitempty"` // numeric prefix ("05"), empty if none
+	DisplayName string `json:"displayName"`      // "weel placed rvb"
+	Active      bool   `json:"active"`
+}
+
+// ParsePresetList parses a "List Presets" response:
+//
+//	Presets 01_clean 05_weel_placed_rvb "my preset"
+//	Ok
+//
+// Order is preserved. Active is left false; compare with Program.ActivePreset.
+func ParsePresetList(raw string) ([]Preset, error) {
+	if err := firstProtocolError(raw); err != nil {
+		return nil, err
+	}
+
+	var out []Preset
+	found := false
+	for _, line := range strings.Split(raw, "\n") {
+		line = strings.TrimSpace(line)
+		if line == "" || line == "Ok" {
+			continue
+		}
+		toks := splitQuoted(line)
+		if toks[0] != "Presets" {
+			return nil, fmt.Errorf("unexpected preset list line: %q", line)
+		}
+		found = true
+		for _, name := range toks[1:] {
+			num, display := SplitPresetName(name)
+			out = append(out, Preset{Name: name, Number: num, DisplayName: display})
+		}
+	}
+	if !found {
+		return nil, fmt.Errorf("missing Presets line")
+	}
+	return out, nil
+}
+
+var rePresetNumName = regexp.MustCompile(`^\s*(\d+)\s*[_\-\s]+\s*(.+?)\s*$`)
+
+// SplitPresetName splits the "NN_name" preset naming convention into the
+// numeric prefix and a display name with underscores turned into spaces.
+// Names without a prefix return num == "".
+func SplitPresetName(token string) (num, name string) {
+	t := strings.TrimSpace(token)
+	t = strings.Trim(t, "\"")
+	if t == "" {
+		return "", ""
+	}
+
+	if m := rePresetNumName.FindStringSubmatch(t); len(m) == 3 {
+		num = strings.TrimSpace(m[1])
+		name = strings.TrimSpace(m[2])
+	} else {
+		name = t
+	}
+
+	name = strings.ReplaceAll(name, "_", " ")
+	name = strings.TrimSpace(name)
+	return num, name
+}
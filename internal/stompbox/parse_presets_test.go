@@ -0,0 +1,35 @@
+package stompbox
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+)
+
+func TestParsePresetList(t *testing.T) {
+	got, err := ParsePresetList("Presets 01_clean 05_weel_placed_rvb \"my lead\" Ambient\r\nOk\r\n")
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []Preset{
+		{Name: "01_clean", Number: "01", DisplayName: "clean"},
+		{Name: "05_weel_placed_rvb", Number: "05", DisplayName: "weel placed rvb"},
+		{Name: "my lead", DisplayName: "my lead"},
+		{Name: "Ambient", DisplayName: "Ambient"},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Fatalf("got %+v\nwant %+v", got, want)
+	}
+
+	if got, err := ParsePresetList("Presets\r\nOk\r\n"); err != nil || len(got) != 0 {
+		t.Fatalf("empty list: %v, %v", got, err)
+	}
+
+	var pe *ProtocolError
+	if _, err := ParsePresetList("Error no preset dir\r\nOk\r\n"); !errors.As(err, &pe) {
+		t.Fatalf("want *ProtocolError, got %v", err)
+	}
+	if _, err := ParsePresetList("Ok\r\n"); err == nil {
+		t.Fatal("missing Presets line should fail")
+	}
+}
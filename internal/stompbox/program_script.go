@@ -0,0 +1,247 @@
+package stompbox
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Program script directives understood by the gateway. Anything else is kept
+// as an unknown line and serialized unchanged.
+const (
+	OpSetPreset     = "SetPreset"
+	OpSetChain      = "SetChain"
+	OpSetPluginSlot = "SetPluginSlot"
+	OpSetParam      = "SetParam"
+)
+
+// ProgramLine is one line of a program script. Raw is the line exactly as
+// dumped (without the line terminator); Op and Args are its quote-aware
+// reading. Editing methods on ProgramScript re-render Raw.
+type ProgramLine struct {
+	Raw   string   `json:"raw"`
+	Op    string   `json:"op,omitempty"`   // first token, "" for blank lines
+	Args  []string `json:"args,omitempty"` // remaining tokens, quotes removed
+	Known bool     `json:"known"`          // Op is one of the Op* directives
+
+	// SetParam typing from DumpConfig, filled by ApplyConfig.
+	ParamType string   `json:"paramType,omitempty"`
+	Number    *float64 `json:"number,omitempty"` // numeric value for non-File params
+}
+
+// Plugin returns the plugin instance of a SetParam or SetPluginSlot line.
+func (l *ProgramLine) Plugin() string {
+	switch l.Op {
+	case OpSetParam:
+		return l.arg(0)
+	case OpSetPluginSlot:
+		return l.arg(1)
+	}
+	return ""
+}
+
+// Param returns the parameter name of a SetParam line.
+func (l *ProgramLine) Param() string {
+	if l.Op != OpSetParam {
+		return ""
+	}
+	return l.arg(1)
+}
+
+// Value returns the unquoted value of a SetParam line ("" if omitted).
+func (l *ProgramLine) Value() string {
+	if l.Op != OpSetParam || len(l.Args) < 3 {
+		return ""
+	}
+	return strings.Join(l.Args[2:], " ")
+}
+
+func (l *ProgramLine) arg(i int) string {
+	if i < len(l.Args) {
+		return l.Args[i]
+	}
+	return ""
+}
+
+// ProgramScript is the ordered, lossless form of a Dump Program response.
+// Serialize returns the script part byte-for-byte as long as no line was edited.
+type ProgramScript struct {
+	Lines       []ProgramLine `json:"lines"`
+	Diagnostics Diagnostics   `json:"diagnostics,omitempty"`
+	// EOL is the line terminator seen in the dump ("\r\n" or "\n").
+	EOL string `json:"-"`
+}
+
+// ParseProgramScript parses a Dump Program response or a bare program script.
+// The EndProgram/Ok framing is dropped; every other line is kept in order,
+// including blank and unknown ones. Unknown and malformed directives are kept
+// verbatim (Known == false) and reported in Diagnostics.
+func ParseProgramScript(raw string) (*ProgramScript, error) {
+	if err := firstProtocolError(raw); err != nil {
+		return nil, err
+	}
+
+	s := &ProgramScript{EOL: "\n"}
+	if strings.Contains(raw, "\r\n") {
+		s.EOL = "\r\n"
+	}
+
+	lines := strings.Split(raw, "\n")
+	if lines[len(lines)-1] == "" {
+		lines = lines[:len(lines)-1]
+	}
+	for i, text := range lines {
+		text = strings.TrimSuffix(text, "\r")
+		if t := strings.TrimSpace(text); t == "EndProgram" || t == "Ok" {
+			break
+		}
+
+		l := ProgramLine{Raw: text}
+		if toks := splitQuoted(text); len(toks) > 0 {
+			l.Op, l.Args = toks[0], toks[1:]
+		}
+		switch err := l.classify(); {
+		case err != nil:
+			s.Diagnostics.add(i+1, SeverityError, l.Op, err.Error(), "kept verbatim, not interpreted")
+		case !l.Known && l.Op != "":
+			s.Diagnostics.add(i+1, SeverityWarning, l.Op, "unknown directive "+l.Op, "kept verbatim, ignored")
+		}
+		s.Lines = append(s.Lines, l)
+	}
+	return s, nil
+}
+
+// classify marks known directives and checks their arity.
+func (l *ProgramLine) classify() error {
+	want := 0
+	switch l.Op {
+	case OpSetPreset:
+		// "SetPreset" alone is valid: no preset loaded.
+	case OpSetChain:
+		want = 1
+	case OpSetPluginSlot:
+		want = 2
+	case OpSetParam:
+		// Value may be omitted; treat as empty string.
+		want = 2
+	default:
+		return nil
+	}
+	if len(l.Args) < want {
+		return fmt.Errorf("malformed %s: %q", l.Op, l.Raw)
+	}
+	l.Known = true
+	return nil
+}
+
+// Serialize renders the script with the dump's line terminator. The result
+// is a valid program (no EndProgram/Ok framing) that Stompbox can replay.
+func (s *ProgramScript) Serialize() string {
+	eol := s.EOL
+	if eol == "" {
+		eol = "\r\n"
+	}
+	var b strings.Builder
+	for _, l := range s.Lines {
+		b.WriteString(l.Raw)
+		b.WriteString(eol)
+	}
+	return b.String()
+}
+
+// ActivePreset returns the name from the last SetPreset line.
+func (s *ProgramScript) ActivePreset() string {
+	name := ""
+	for _, l := range s.Lines {
+		if l.Op == OpSetPreset && l.Known {
+			name = strings.Join(l.Args, " ")
+		}
+	}
+	return name
+}
+
+// Param returns the value the script leaves param at (the last SetParam wins).
+func (s *ProgramScript) Param(plugin, param string) (string, bool) {
+	if l := s.lastParam(plugin, param); l != nil {
+		return l.Value(), true
+	}
+	return "", false
+}
+
+// SetParamValue rewrites the effective SetParam line for plugin/param.
+// It returns false if the script never sets that param.
+func (s *ProgramScript) SetParamValue(plugin, param, value string) bool {
+	l := s.lastParam(plugin, param)
+	if l == nil {
+		return false
+	}
+	l.Args = []string{plugin, param, value}
+	l.Raw = OpSetParam + " " + plugin + " " + param + " " + quoteIfNeeded(value)
+	l.Number = nil
+	if l.ParamType != "" && l.ParamType != "File" {
+		l.Number = parseFloatPtr(value)
+	}
+	return true
+}
+
+func (s *ProgramScript) lastParam(plugin, param string) *ProgramLine {
+	for i := len(s.Lines) - 1; i >= 0; i-- {
+		l := &s.Lines[i]
+		if l.Op == OpSetParam && l.Known && l.Plugin() == plugin && l.Param() == param {
+			return l
+		}
+	}
+	return nil
+}
+
+// ApplyConfig types every SetParam line from its DumpConfig ParameterConfig.
+// Instances (Delay_2) are resolved to their plugin type (Delay).
+func (s *ProgramScript) ApplyConfig(cfg *DumpConfigParsed) {
+	if cfg == nil {
+		return
+	}
+	for i := range s.Lines {
+		l := &s.Lines[i]
+		if l.Op != OpSetParam || !l.Known {
+			continue
+		}
+		def, ok := cfg.ResolveParam(l.Plugin(), l.Param())
+		if !ok {
+			continue
+		}
+		l.ParamType = def.Type
+		l.Number = nil
+		if def.Type != "File" {
+			l.Number = parseFloatPtr(l.Value())
+		}
+	}
+}
+
+// Program returns the map view used by the older handlers.
+func (s *ProgramScript) Program() *Program {
+	p := &Program{
+		Chains: make(map[string][]string),
+		Slots:  make(map[string]string),
+		Params: make(map[string]map[string]string),
+	}
+	for _, l := range s.Lines {
+		if !l.Known {
+			continue
+		}
+		switch l.Op {
+		case OpSetPreset:
+			p.ActivePreset = strings.Join(l.Args, " ")
+		case OpSetChain:
+			p.Chains[l.Args[0]] = append([]string{}, l.Args[1:]...)
+		case OpSetPluginSlot:
+			p.Slots[l.Args[0]] = l.Args[1]
+		case OpSetParam:
+			plugin := l.Plugin()
+			if _, ok := p.Params[plugin]; !ok {
+				p.Params[plugin] = make(map[string]string)
+			}
+			p.Params[plugin][l.Param()] = l.Value()
+		}
+	}
+	p.Diagnostics = s.Diagnostics
+	return p
+}
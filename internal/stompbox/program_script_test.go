@@ -0,0 +1,98 @@
+package stompbox
+
+import (
+	"os"
+	"strings"
+	"testing"
+)
+
+func readSample(t *testing.T, name string) string {
+	t.Helper()
+	b, err := os.ReadFile("../../docs/samples/" + name)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return string(b)
+}
+
+func TestProgramScriptRoundTrip(t *testing.T) {
+	raw := readSample(t, "dump_program.example.txt")
+	s, err := ParseProgramScript(raw)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	script := strings.TrimSuffix(raw, "EndProgram\r\nOk\r\n")
+	if got := s.Serialize(); got != script {
+		t.Fatalf("serialize is not byte-for-byte")
+	}
+	again, err := ParseProgramScript(s.Serialize())
+	if err != nil || again.Serialize() != script {
+		t.Fatalf("re-parse round trip failed: %v", err)
+	}
+
+	if got := s.ActivePreset(); got != "05_weel_placed_rvb" {
+		t.Fatalf("ActivePreset = %q", got)
+	}
+	// NAM Level is set twice; the last one wins.
+	if v, _ := s.Param("NAM", "Level"); v != "0.000064" {
+		t.Fatalf("NAM Level = %q", v)
+	}
+}
+
+func TestProgramScriptQuotesAndUnknownLines(t *testing.T) {
+	raw := "SetPreset \"my  preset\"\r\n" +
+		"SetParam NAM Model \"fender  bassman 50\"\r\n" +
+		"FutureDirective a b\r\n" +
+		"\r\n" +
+		"SetParam Boost_2 Gain 10.000000\r\n" +
+		"EndProgram\r\nOk\r\n"
+	s, err := ParseProgramScript(raw)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(s.Lines) != 5 {
+		t.Fatalf("got %d lines; want 5", len(s.Lines))
+	}
+	if got := s.ActivePreset(); got != "my  preset" {
+		t.Fatalf("ActivePreset = %q", got)
+	}
+	if v, _ := s.Param("NAM", "Model"); v != "fender  bassman 50" {
+		t.Fatalf("Model = %q", v)
+	}
+	if l := s.Lines[2]; l.Known || l.Op != "FutureDirective" {
+		t.Fatalf("unknown line: %+v", l)
+	}
+
+	cfg, err := ParseDumpConfig(readSample(t, "dump_config.example.txt"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	s.ApplyConfig(cfg)
+	gain := s.Lines[4]
+	if gain.ParamType != "Knob" || gain.Number == nil || *gain.Number != 10 {
+		t.Fatalf("Boost_2 Gain typing: %+v", gain)
+	}
+
+	if !s.SetParamValue("NAM", "Model", "new model") {
+		t.Fatal("SetParamValue: param not found")
+	}
+	want := strings.Replace(strings.TrimSuffix(raw, "EndProgram\r\nOk\r\n"),
+		`SetParam NAM Model "fender  bassman 50"`, `SetParam NAM Model "new model"`, 1)
+	if got := s.Serialize(); got != want {
+		t.Fatalf("after edit:\n%q\nwant\n%q", got, want)
+	}
+}
+
+func TestProgramScriptEmptyValue(t *testing.T) {
+	s, err := ParseProgramScript("SetParam NAM Model \"\"\r\nEndProgram\r\nOk\r\n")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if l := s.Lines[0]; len(l.Args) != 3 || l.Value() != "" {
+		t.Fatalf("empty quoted value: %+v", l)
+	}
+	if !s.SetParamValue("NAM", "Model", "") || s.Lines[0].Raw != `SetParam NAM Model ""` {
+		t.Fatalf("SetParamValue(\"\") = %q", s.Lines[0].Raw)
+	}
+}
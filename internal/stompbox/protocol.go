@@ -1,7 +1,7 @@
 package stompbox
 
 import (
-	"errors"
+	"context"
 	"strings"
 )
 
@@ -13,37 +13,32 @@ func normalizeCmd(cmd string) string {
 	return cmd
 }
 
-func isErrorLine(line string) bool {
-	return strings.HasPrefix(line, "Error ")
-}
 func (c *Client) SendOk(cmd string) error {
-	resp, err := c.SendCommand(normalizeCmd(cmd))
+	return c.SendOkCtx(context.Background(), cmd)
+}
+
+func (c *Client) SendOkCtx(ctx context.Context, cmd string) error {
+	cmd = normalizeCmd(cmd)
+	resp, err := c.SendCommandCtx(ctx, cmd)
 	if err != nil {
 		return err
 	}
 
 	// defensive: stompbox usually ends with Ok, but catch protocol errors
-	for _, line := range strings.Split(resp, "\n") {
-		line = strings.TrimSpace(line)
-		if isErrorLine(line) {
-			return errors.New(line)
-		}
-	}
-
-	return nil
+	return protocolError(cmd, resp)
 }
 func (c *Client) SendAndRead(cmd string) (string, error) {
-	resp, err := c.SendCommand(normalizeCmd(cmd))
+	return c.SendAndReadCtx(context.Background(), cmd)
+}
+
+func (c *Client) SendAndReadCtx(ctx context.Context, cmd string) (string, error) {
+	cmd = normalizeCmd(cmd)
+	resp, err := c.SendCommandCtx(ctx, cmd)
 	if err != nil {
 		return "", err
 	}
-
-	for _, line := range strings.Split(resp, "\n") {
-		line = strings.TrimSpace(line)
-		if isErrorLine(line) {
-			return "", errors.New(line)
-		}
+	if err := protocolError(cmd, resp); err != nil {
+		return "", err
 	}
-
 	return resp, nil
 }
@@ -0,0 +1,195 @@
+package stompbox
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"net"
+	"strings"
+	"time"
+)
+
+// Health describes the shared Stompbox session as seen by the client.
+type Health struct {
+	Addr        string    `json:"addr"`
+	Connected   bool      `json:"connected"`
+	ConnectedAt time.Time `json:"connectedAt,omitempty"`
+	LastOK      time.Time `json:"lastOk,omitempty"`
+	LastError   string    `json:"lastError,omitempty"`
+	LastErrorAt time.Time `json:"lastErrorAt,omitempty"`
+	LastLatency string    `json:"lastLatency,omitempty"`
+	Dials       uint64    `json:"dials"`
+	Reconnects  uint64    `json:"reconnects"`
+	Commands    uint64    `json:"commands"`
+	Failures    uint64    `json:"failures"`
+
+	// Circuit breaker (see breaker.go).
+	Up                  bool      `json:"up"`
+	ConsecutiveFailures int       `json:"consecutiveFailures"`
+	DownSince           time.Time `json:"downSince,omitempty"`
+	RetryAt             time.Time `json:"retryAt,omitempty"`
+}
+
+// Health returns a snapshot of the session state and counters.
+func (c *Client) Health() Health {
+	c.hmu.Lock()
+	defer c.hmu.Unlock()
+	h := c.health
+	h.Addr = c.Addr
+	c.brk.fillHealth(&h)
+	return h
+}
+
+// Close tears down the shared session. The next command dials again.
+func (c *Client) Close() error {
+	_ = c.lock(context.Background())
+	defer c.unlock()
+	return c.closeConn()
+}
+
+// lock acquires the session, giving up if ctx is done first.
+func (c *Client) lock(ctx context.Context) error {
+	c.semOnce.Do(func() { c.sem = make(chan struct{}, 1) })
+	select {
+	case c.sem <- struct{}{}:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+func (c *Client) unlock() { <-c.sem }
+
+// roundTrip runs one command on the session, dialing if needed.
+// A reused session that fails before any response bytes arrive was most likely
+// closed by Stompbox while idle, so the command is retried once on a fresh
+// connection, but only if it was never written or only reads (Dump, List):
+// a written edit may already have run, and SavePreset or SetChain must not
+// run twice. Timeouts are never retried: the command may still be executing.
+// Caller must hold the session lock.
+func (c *Client) roundTrip(ctx context.Context, command string, stop stopFunc, maxLine int, emit lineFunc) error {
+	for attempt := 0; ; attempt++ {
+		reused, err := c.ensureConn(ctx)
+		if err != nil {
+			return err
+		}
+
+		sent, n, err := c.exchange(ctx, command, stop, maxLine, emit)
+		if err == nil {
+			c.lastUsed = time.Now()
+			return nil
+		}
+
+		// The stream position is unknown after any failure; never reuse it.
+		_ = c.closeConn()
+
+		safe := !sent || n == 0 && readOnly(command)
+		if reused && safe && attempt == 0 && !isTimeout(err) && ctx.Err() == nil {
+			c.hmu.Lock()
+			c.health.Reconnects++
+			c.hmu.Unlock()
+			continue
+		}
+		return err
+	}
+}
+
+// readOnly reports commands that are safe to send twice.
+func readOnly(command string) bool {
+	return strings.HasPrefix(command, "Dump ") || strings.HasPrefix(command, "List ")
+}
+
+// ensureConn returns whether an existing session is being reused.
+// Caller must hold the session lock.
+func (c *Client) ensureConn(ctx context.Context) (bool, error) {
+	if c.conn != nil && c.IdleTimeout > 0 && time.Since(c.lastUsed) > c.IdleTimeout {
+		_ = c.closeConn()
+	}
+	if c.conn != nil && !c.connAlive() {
+		// Closed by Stompbox while idle: redial before writing, since an
+		// edit written into a dead session can't be retried safely.
+		_ = c.closeConn()
+		c.hmu.Lock()
+		c.health.Reconnects++
+		c.hmu.Unlock()
+	}
+	if c.conn != nil {
+		return true, nil
+	}
+
+	c.hmu.Lock()
+	c.health.Dials++
+	c.hmu.Unlock()
+
+	d := net.Dialer{Timeout: c.DialTimeout}
+	conn, err := d.DialContext(ctx, "tcp", c.Addr)
+	if err != nil {
+		if ctx.Err() != nil {
+			return false, ctx.Err()
+		}
+		return false, c.wrapIOError("dial", "", err)
+	}
+	if tcp, ok := conn.(*net.TCPConn); ok {
+		_ = tcp.SetKeepAlive(true)
+		_ = tcp.SetNoDelay(true)
+	}
+
+	c.conn = conn
+	c.reader = bufio.NewReader(conn)
+	c.lastUsed = time.Now()
+
+	c.hmu.Lock()
+	c.health.Connected = true
+	c.health.ConnectedAt = c.lastUsed
+	c.hmu.Unlock()
+	return false, nil
+}
+
+// aliveProbe is how long connAlive waits for a read. A deadline already in
+// the past would skip the read altogether.
+const aliveProbe = 100 * time.Microsecond
+
+// connAlive checks an idle session almost without blocking: nothing to read
+// yet means it is open; EOF, an error or unexpected bytes mean it can't be
+// used. Caller must hold the session lock.
+func (c *Client) connAlive() bool {
+	_ = c.conn.SetReadDeadline(time.Now().Add(aliveProbe))
+	_, err := c.reader.Peek(1)
+	_ = c.conn.SetReadDeadline(time.Time{})
+	var ne net.Error
+	return errors.As(err, &ne) && ne.Timeout()
+}
+
+// closeConn drops the session. Caller must hold the session lock.
+func (c *Client) closeConn() error {
+	if c.conn == nil {
+		return nil
+	}
+	err := c.conn.Close()
+	c.conn = nil
+	c.reader = nil
+
+	c.hmu.Lock()
+	c.health.Connected = false
+	c.hmu.Unlock()
+	return err
+}
+
+func (c *Client) recordResult(d time.Duration, err error) {
+	c.hmu.Lock()
+	defer c.hmu.Unlock()
+	c.health.Commands++
+	c.health.LastLatency = d.String()
+	if err != nil {
+		c.health.Failures++
+		c.health.LastError = err.Error()
+		c.health.LastErrorAt = time.Now()
+		return
+	}
+	c.health.LastOK = time.Now()
+}
+
+func isTimeout(err error) bool {
+	var te *TimeoutError
+	return errors.As(err, &te)
+}
@@ -0,0 +1,155 @@
+package stompbox
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"sync"
+	"time"
+)
+
+// TraceEntry is one command/response exchange as seen by the client.
+type TraceEntry struct {
+	Seq        uint64    `json:"seq"`
+	Time       time.Time `json:"time"`
+	Command    string    `json:"command"`
+	Response   string    `json:"response"`
+	Truncated  bool      `json:"truncated,omitempty"` // Response was cut at Tracer.MaxResponse
+	DurationMs float64   `json:"durationMs"`
+	Error      string    `json:"error,omitempty"`
+}
+
+// Tracer keeps the most recent exchanges in a ring buffer and fans new ones
+// out to live subscribers. Attach it with Client.Tracer.
+type Tracer struct {
+	// MaxResponse caps the stored response bytes per entry (0 = unlimited),
+	// so a handful of Dump Config entries can't pin megabytes.
+	MaxResponse int
+
+	mu   sync.Mutex
+	ring []TraceEntry
+	next int
+	full bool
+	seq  uint64
+	subs map[chan TraceEntry]struct{}
+}
+
+// NewTracer returns a tracer holding the last size entries.
+func NewTracer(size int) *Tracer {
+	if size <= 0 {
+		size = 1
+	}
+	return &Tracer{
+		MaxResponse: 1 << 20,
+		ring:        make([]TraceEntry, size),
+		subs:        make(map[chan TraceEntry]struct{}),
+	}
+}
+
+// Record stores e (assigning Seq) and notifies subscribers. Slow subscribers miss entries.
+func (t *Tracer) Record(e TraceEntry) {
+	if t.MaxResponse > 0 && len(e.Response) > t.MaxResponse {
+		e.Response = e.Response[:t.MaxResponse]
+		e.Truncated = true
+	}
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	t.seq++
+	e.Seq = t.seq
+	t.ring[t.next] = e
+	t.next = (t.next + 1) % len(t.ring)
+	if t.next == 0 {
+		t.full = true
+	}
+
+	for ch := range t.subs {
+		select {
+		case ch <- e:
+		default:
+		}
+	}
+}
+
+// Entries returns the buffered entries, oldest first.
+func (t *Tracer) Entries() []TraceEntry {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	if !t.full {
+		return append([]TraceEntry(nil), t.ring[:t.next]...)
+	}
+	out := make([]TraceEntry, 0, len(t.ring))
+	out = append(out, t.ring[t.next:]...)
+	return append(out, t.ring[:t.next]...)
+}
+
+// Subscribe returns a channel receiving every new entry and a cancel func.
+func (t *Tracer) Subscribe(buffer int) (<-chan TraceEntry, func()) {
+	ch := make(chan TraceEntry, buffer)
+	t.mu.Lock()
+	t.subs[ch] = struct{}{}
+	t.mu.Unlock()
+
+	var once sync.Once
+	return ch, func() {
+		once.Do(func() {
+			t.mu.Lock()
+			delete(t.subs, ch)
+			t.mu.Unlock()
+			close(ch)
+		})
+	}
+}
+
+func (c *Client) trace(start time.Time, command, resp string, err error) {
+	if c.Tracer == nil {
+		return
+	}
+	e := TraceEntry{
+		Time:       start,
+		Command:    commandLabel(command),
+		Response:   resp,
+		DurationMs: float64(time.Since(start).Microseconds()) / 1000,
+	}
+	if err != nil {
+		e.Error = err.Error()
+	}
+	c.Tracer.Record(e)
+}
+
+// TranscriptVersion is the current transcript file format.
+const TranscriptVersion = 1
+
+// Transcript is the exportable form of a trace, replayable by stompboxtest.
+type Transcript struct {
+	Version   int          `json:"version"`
+	Addr      string       `json:"addr,omitempty"`
+	CreatedAt time.Time    `json:"createdAt"`
+	Entries   []TraceEntry `json:"entries"`
+}
+
+// WriteTranscript encodes entries as an indented transcript document.
+func WriteTranscript(w io.Writer, addr string, entries []TraceEntry) error {
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	return enc.Encode(Transcript{
+		Version:   TranscriptVersion,
+		Addr:      addr,
+		CreatedAt: time.Now(),
+		Entries:   entries,
+	})
+}
+
+// ReadTranscript decodes a transcript written by WriteTranscript.
+func ReadTranscript(r io.Reader) (*Transcript, error) {
+	var tr Transcript
+	if err := json.NewDecoder(r).Decode(&tr); err != nil {
+		return nil, err
+	}
+	if tr.Version != TranscriptVersion {
+		return nil, fmt.Errorf("unsupported transcript version %d", tr.Version)
+	}
+	return &tr, nil
+}
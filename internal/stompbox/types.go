@@ -11,4 +11,7 @@ type FileTreeDef struct {
 	Category string       `json:"category,omitempty"`
 	Items    []string     `json:"items,omitempty"`
 	Options  []FileOption `json:"options,omitempty"`
+	// Truncated is set when the dump line exceeded the per-section limit
+	// and only the leading Items were kept.
+	Truncated bool `json:"truncated,omitempty"`
 }
@@ -0,0 +1,56 @@
+package stompboxtest
+
+import (
+	"net"
+	"strings"
+
+	"github.com/alscos/Namnesis/internal/stompbox"
+)
+
+// NewReplayServer returns a server that answers commands from a recorded
+// transcript instead of live state, so a field trace can be replayed against
+// the gateway. Each command gets the next recorded response for the same
+// command line; once those run out the last one is repeated (polling loops).
+// Commands never recorded get an "Error" line. Server.Do is a no-op.
+func NewReplayServer(tr *stompbox.Transcript) *Server {
+	return &Server{
+		replay: &replayer{
+			entries: tr.Entries,
+			used:    make([]bool, len(tr.Entries)),
+			last:    make(map[string]int),
+		},
+		conns: make(map[net.Conn]struct{}),
+	}
+}
+
+// replayer is only touched under Server.mu.
+type replayer struct {
+	entries []stompbox.TraceEntry
+	used    []bool
+	last    map[string]int // command -> index of the last entry served
+}
+
+func (r *replayer) respond(line string) (string, bool) {
+	for i, e := range r.entries {
+		if r.used[i] || strings.TrimSpace(e.Command) != line {
+			continue
+		}
+		r.used[i] = true
+		r.last[line] = i
+		return replayEntry(e)
+	}
+	if i, ok := r.last[line]; ok {
+		return replayEntry(r.entries[i])
+	}
+	return "Error command not in transcript: " + line + "\r\nOk\r\n", true
+}
+
+// replayEntry reproduces the recorded outcome: responses that never reached
+// their terminator (transport failure, truncation) close the session again.
+func replayEntry(e stompbox.TraceEntry) (string, bool) {
+	if e.Error == "" {
+		return e.Response, true
+	}
+	complete := strings.HasSuffix(strings.TrimSpace(e.Response), "Ok")
+	return e.Response, complete && !e.Truncated
+}
@@ -0,0 +1,64 @@
+package stompboxtest
+
+import (
+	"bytes"
+	"testing"
+	"time"
+
+	"github.com/alscos/Namnesis/internal/stompbox"
+)
+
+func TestRecordAndReplay(t *testing.T) {
+	_, c := startSample(t)
+	c.Tracer = stompbox.NewTracer(16)
+
+	if err := c.SetParam("Delay_2", "Mix", "0.75"); err != nil {
+		t.Fatalf("SetParam: %v", err)
+	}
+	recorded, err := c.DumpProgram()
+	if err != nil {
+		t.Fatalf("DumpProgram: %v", err)
+	}
+	if err := c.LoadPreset("missing"); err == nil {
+		t.Fatalf("LoadPreset of an unknown preset should fail")
+	}
+
+	var buf bytes.Buffer
+	if err := stompbox.WriteTranscript(&buf, c.Addr, c.Tracer.Entries()); err != nil {
+		t.Fatalf("WriteTranscript: %v", err)
+	}
+	tr, err := stompbox.ReadTranscript(&buf)
+	if err != nil {
+		t.Fatalf("ReadTranscript: %v", err)
+	}
+	if len(tr.Entries) != 3 {
+		t.Fatalf("transcript has %d entries; want 3", len(tr.Entries))
+	}
+
+	srv := NewReplayServer(tr)
+	if err := srv.Listen("127.0.0.1:0"); err != nil {
+		t.Fatalf("Listen: %v", err)
+	}
+	defer srv.Close()
+
+	rc := stompbox.New(srv.Addr())
+	rc.ReadTimeout = time.Second
+	defer rc.Close()
+
+	if err := rc.SetParam("Delay_2", "Mix", "0.75"); err != nil {
+		t.Fatalf("replayed SetParam: %v", err)
+	}
+	// Polled twice: the last recorded response is repeated.
+	for i := 0; i < 2; i++ {
+		got, err := rc.DumpProgram()
+		if err != nil || got != recorded {
+			t.Fatalf("replayed DumpProgram #%d differs (err=%v)", i, err)
+		}
+	}
+	if err := rc.LoadPreset("missing"); err == nil {
+		t.Fatalf("replayed LoadPreset should reproduce the protocol error")
+	}
+	if err := rc.SetParam("Boost_2", "Gain", "1"); err == nil {
+		t.Fatalf("command outside the transcript should fail")
+	}
+}
@@ -0,0 +1,281 @@
+// Package stompboxtest runs an in-process fake Stompbox that speaks the CRLF
+// control protocol over TCP. It keeps real mutable state (chains, slots,
+// params, presets) and can inject faults, so the gateway can be exercised
+// without the pedal.
+package stompboxtest
+
+import (
+	"bufio"
+	"errors"
+	"net"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/alscos/Namnesis/internal/stompbox"
+)
+
+// Faults configures misbehaviour. The zero value is a well-behaved Stompbox.
+type Faults struct {
+	// Latency delays every response.
+	Latency time.Duration
+	// Errors maps a command verb ("SetParam", "LoadPreset", "Dump", ...), or
+	// a verb and its first argument ("SetParam Delay_2"), to the message of
+	// an "Error" line sent instead of executing the command.
+	Errors map[string]string
+	// TruncateDumps cuts Dump responses after this many bytes and closes the
+	// connection (0 = off).
+	TruncateDumps int
+	// DisconnectEvery closes the connection without answering every Nth
+	// command (0 = off).
+	DisconnectEvery int
+}
+
+// Server is a fake Stompbox bound to a TCP listener.
+type Server struct {
+	mu       sync.Mutex
+	st       *State
+	replay   *replayer // set by NewReplayServer instead of st
+	faults   Faults
+	commands int
+
+	ln    net.Listener
+	conns map[net.Conn]struct{}
+	wg    sync.WaitGroup
+}
+
+// NewServer wraps st. Call Listen (or Serve) to start accepting connections.
+func NewServer(st *State) *Server {
+	return &Server{
+		st:    st,
+		conns: make(map[net.Conn]struct{}),
+	}
+}
+
+// Listen binds addr (use "127.0.0.1:0" for an ephemeral port) and serves in the background.
+func (s *Server) Listen(addr string) error {
+	ln, err := net.Listen("tcp", addr)
+	if err != nil {
+		return err
+	}
+	s.setListener(ln)
+	go s.serve(ln)
+	return nil
+}
+
+// Serve accepts connections on ln until Close is called.
+func (s *Server) Serve(ln net.Listener) error {
+	s.setListener(ln)
+	return s.serve(ln)
+}
+
+func (s *Server) setListener(ln net.Listener) {
+	s.mu.Lock()
+	s.ln = ln
+	s.mu.Unlock()
+}
+
+func (s *Server) serve(ln net.Listener) error {
+	for {
+		conn, err := ln.Accept()
+		if err != nil {
+			if errors.Is(err, net.ErrClosed) {
+				return nil
+			}
+			return err
+		}
+		s.mu.Lock()
+		s.conns[conn] = struct{}{}
+		s.mu.Unlock()
+
+		s.wg.Add(1)
+		go s.handle(conn)
+	}
+}
+
+// Addr returns the listening address, or "" before Listen/Serve.
+func (s *Server) Addr() string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.ln == nil {
+		return ""
+	}
+	return s.ln.Addr().String()
+}
+
+// Close stops the listener and drops every open session.
+func (s *Server) Close() error {
+	s.mu.Lock()
+	var err error
+	if s.ln != nil {
+		err = s.ln.Close()
+	}
+	for c := range s.conns {
+		_ = c.Close()
+	}
+	s.mu.Unlock()
+
+	s.wg.Wait()
+	return err
+}
+
+// SetFaults replaces the fault configuration; it applies to the next command.
+func (s *Server) SetFaults(f Faults) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.faults = f
+}
+
+// DropConnections closes every open session, as a Stompbox restart would.
+func (s *Server) DropConnections() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for c := range s.conns {
+		_ = c.Close()
+	}
+}
+
+// Do runs fn with exclusive access to the state (for test setup and assertions).
+func (s *Server) Do(fn func(st *State)) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.st != nil {
+		fn(s.st)
+	}
+}
+
+func (s *Server) handle(conn net.Conn) {
+	defer s.wg.Done()
+	defer func() {
+		s.mu.Lock()
+		delete(s.conns, conn)
+		s.mu.Unlock()
+		_ = conn.Close()
+	}()
+
+	r := bufio.NewReader(conn)
+	for {
+		line, err := r.ReadString('\n')
+		if err != nil {
+			return
+		}
+		line = strings.TrimSpace(line)
+		if line == "" {
+			continue
+		}
+
+		resp, keep := s.respond(line)
+		if resp != "" {
+			if _, err := conn.Write([]byte(resp)); err != nil {
+				return
+			}
+		}
+		if !keep {
+			return
+		}
+	}
+}
+
+// respond executes one command line and returns the raw response and
+// whether the connection should stay open.
+func (s *Server) respond(line string) (string, bool) {
+	s.mu.Lock()
+	f := s.faults
+	s.commands++
+	n := s.commands
+	s.mu.Unlock()
+
+	if f.Latency > 0 {
+		time.Sleep(f.Latency)
+	}
+	if f.DisconnectEvery > 0 && n%f.DisconnectEvery == 0 {
+		return "", false
+	}
+
+	toks := stompbox.Tokenize(line)
+	if len(toks) == 0 {
+		return "Error empty command\r\nOk\r\n", true
+	}
+	verb := toks[0]
+	if msg, ok := f.Errors[verb]; ok {
+		return "Error " + msg + "\r\nOk\r\n", true
+	}
+	if len(toks) > 1 {
+		if msg, ok := f.Errors[verb+" "+toks[1]]; ok {
+			return "Error " + msg + "\r\nOk\r\n", true
+		}
+	}
+
+	s.mu.Lock()
+	if s.replay != nil {
+		resp, keep := s.replay.respond(line)
+		s.mu.Unlock()
+		return resp, keep
+	}
+	resp := s.execute(toks, line)
+	s.mu.Unlock()
+
+	if verb == "Dump" && f.TruncateDumps > 0 && len(resp) > f.TruncateDumps {
+		return resp[:f.TruncateDumps], false
+	}
+	return resp, true
+}
+
+// execute runs a tokenized command against the state. Caller holds s.mu.
+func (s *Server) execute(toks []string, line string) string {
+	st := s.st
+	arg := func(i int) string {
+		if i < len(toks) {
+			return toks[i]
+		}
+		return ""
+	}
+
+	var err error
+	switch toks[0] {
+	case "Dump":
+		switch arg(1) {
+		case "Config":
+			return ensureCRLF(st.configRaw)
+		case "Program":
+			return st.programScript() + "EndProgram\r\nOk\r\n"
+		}
+		err = errors.New("unknown dump " + arg(1))
+	case "List":
+		if arg(1) == "Presets" {
+			return "Presets " + strings.Join(st.Presets(), " ") + "\r\nOk\r\n"
+		}
+		err = errors.New("unknown list " + arg(1))
+	case "SetParam":
+		err = st.setParam(toks[1:], true)
+	case "SetChain":
+		err = st.setChain(toks[1:])
+	case "SetPluginSlot":
+		err = st.setSlot(toks[1:])
+	case "ReleasePlugin":
+		err = st.releasePlugin(arg(1))
+	case "LoadPreset":
+		err = st.loadPreset(arg(1))
+	case "SavePreset":
+		if arg(1) == "" {
+			err = errors.New("missing preset name")
+		} else {
+			st.savePreset(arg(1))
+		}
+	case "DeletePreset":
+		err = st.deletePreset(arg(1))
+	default:
+		err = errors.New("unknown command " + line)
+	}
+	if err != nil {
+		return "Error " + err.Error() + "\r\nOk\r\n"
+	}
+	return "Ok\r\n"
+}
+
+// ensureCRLF normalizes sample files (often saved with LF) to protocol line endings.
+func ensureCRLF(raw string) string {
+	raw = strings.ReplaceAll(raw, "\r\n", "\n")
+	raw = strings.TrimRight(raw, "\n")
+	return strings.ReplaceAll(raw, "\n", "\r\n") + "\r\n"
+}
@@ -0,0 +1,153 @@
+package stompboxtest
+
+import (
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/alscos/Namnesis/internal/stompbox"
+)
+
+func startSample(t *testing.T) (*Server, *stompbox.Client) {
+	t.Helper()
+	st, err := LoadStateDir("../../docs/samples")
+	if err != nil {
+		t.Fatalf("LoadStateDir: %v", err)
+	}
+	srv := NewServer(st)
+	if err := srv.Listen("127.0.0.1:0"); err != nil {
+		t.Fatalf("Listen: %v", err)
+	}
+	t.Cleanup(func() { srv.Close() })
+
+	c := stompbox.New(srv.Addr())
+	c.ReadTimeout = time.Second
+	t.Cleanup(func() { c.Close() })
+	return srv, c
+}
+
+func dumpProgram(t *testing.T, c *stompbox.Client) *stompbox.Program {
+	t.Helper()
+	raw, err := c.DumpProgram()
+	if err != nil {
+		t.Fatalf("DumpProgram: %v", err)
+	}
+	p, err := stompbox.ParseDumpProgram(raw)
+	if err != nil {
+		t.Fatalf("ParseDumpProgram: %v", err)
+	}
+	return p
+}
+
+func TestSampleRoundTrip(t *testing.T) {
+	_, c := startSample(t)
+
+	raw, err := c.DumpConfig()
+	if err != nil {
+		t.Fatalf("DumpConfig: %v", err)
+	}
+	cfg, err := stompbox.ParseDumpConfig(raw)
+	if err != nil || cfg.Plugins["NAM"] == nil {
+		t.Fatalf("ParseDumpConfig: %v (NAM missing?)", err)
+	}
+
+	p := dumpProgram(t, c)
+	if p.ActivePreset != "05_weel_placed_rvb" {
+		t.Fatalf("ActivePreset = %q", p.ActivePreset)
+	}
+	if got := strings.Join(p.Chains["Output"], " "); got != "BEQ-7_2 HighLow_2 ConvoReverb_2 Reverb" {
+		t.Fatalf("Output chain = %q", got)
+	}
+	if p.Slots["Amp"] != "NAM" {
+		t.Fatalf("Amp slot = %q", p.Slots["Amp"])
+	}
+
+	presets, err := c.ListPresets()
+	if err != nil || !strings.Contains(presets, "05_weel_placed_rvb") {
+		t.Fatalf("ListPresets = %q, %v", presets, err)
+	}
+}
+
+func TestMutations(t *testing.T) {
+	_, c := startSample(t)
+
+	if err := c.SetParam("Delay_2", "Mix", "0.75"); err != nil {
+		t.Fatalf("SetParam: %v", err)
+	}
+	if err := c.SetChain("FxLoop", []string{"Delay_2", "Delay"}); err != nil {
+		t.Fatalf("SetChain: %v", err)
+	}
+	p := dumpProgram(t, c)
+	if p.Params["Delay_2"]["Mix"] != "0.750000" {
+		t.Fatalf("Delay_2 Mix = %q", p.Params["Delay_2"]["Mix"])
+	}
+	if got := strings.Join(p.Chains["FxLoop"], " "); got != "Delay_2 Delay_3" {
+		t.Fatalf("FxLoop = %q; want new instance Delay_3", got)
+	}
+	if p.Params["Delay_3"]["Delay"] != "250.000000" {
+		t.Fatalf("Delay_3 defaults missing: %v", p.Params["Delay_3"])
+	}
+
+	// Released plugins must not be wired anywhere.
+	if err := c.ReleasePlugin("Delay_3"); err == nil {
+		t.Fatalf("ReleasePlugin of an in-use instance should fail")
+	}
+
+	if err := c.SetPluginSlot("Amp", "NAMMulti"); err != nil {
+		t.Fatalf("SetPluginSlot: %v", err)
+	}
+	if p := dumpProgram(t, c); p.Slots["Amp"] != "NAMMulti" {
+		t.Fatalf("Amp slot = %q; want NAMMulti", p.Slots["Amp"])
+	}
+	if err := c.ReleasePlugin("NAM"); err != nil {
+		t.Fatalf("ReleasePlugin of the replaced slot plugin: %v", err)
+	}
+
+	if err := c.SavePreset("06_test"); err != nil {
+		t.Fatalf("SavePreset: %v", err)
+	}
+	if err := c.LoadPreset("05_weel_placed_rvb"); err != nil {
+		t.Fatalf("LoadPreset: %v", err)
+	}
+	if p := dumpProgram(t, c); p.Params["Delay_2"]["Mix"] != "0.500000" {
+		t.Fatalf("LoadPreset did not restore Mix: %q", p.Params["Delay_2"]["Mix"])
+	}
+	if err := c.DeletePreset("06_test"); err != nil {
+		t.Fatalf("DeletePreset: %v", err)
+	}
+	if err := c.LoadPreset("06_test"); err == nil {
+		t.Fatalf("LoadPreset of a deleted preset should fail")
+	}
+}
+
+func TestFaults(t *testing.T) {
+	srv, c := startSample(t)
+
+	srv.SetFaults(Faults{Errors: map[string]string{"SetParam": "injected"}})
+	err := c.SetParam("Delay_2", "Mix", "0.75")
+	var pe *stompbox.ProtocolError
+	if !errors.As(err, &pe) || pe.Message != "injected" {
+		t.Fatalf("SetParam error = %v; want injected ProtocolError", err)
+	}
+
+	srv.SetFaults(Faults{Errors: map[string]string{"SetParam Delay_2": "injected"}})
+	if err := c.SetParam("Delay_2", "Mix", "0.75"); !errors.As(err, &pe) {
+		t.Fatalf("SetParam Delay_2 error = %v; want injected ProtocolError", err)
+	}
+	if err := c.SetParam("Boost_2", "Gain", "12"); err != nil {
+		t.Fatalf("SetParam Boost_2 with a Delay_2 fault: %v", err)
+	}
+
+	srv.SetFaults(Faults{TruncateDumps: 64})
+	_, err = c.DumpProgram()
+	var ie *stompbox.IncompleteResponseError
+	if !errors.As(err, &ie) {
+		t.Fatalf("DumpProgram error = %T %v; want *IncompleteResponseError", err, err)
+	}
+
+	srv.SetFaults(Faults{})
+	if _, err := c.DumpProgram(); err != nil {
+		t.Fatalf("DumpProgram after clearing faults: %v", err)
+	}
+}
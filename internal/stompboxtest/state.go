@@ -0,0 +1,349 @@
+package stompboxtest
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/alscos/Namnesis/internal/stompbox"
+)
+
+// Sample file names inside a samples directory (see docs/samples).
+const (
+	SampleConfigFile  = "dump_config.example.txt"
+	SampleProgramFile = "dump_program.example.txt"
+)
+
+// State is the mutable engine state behind a fake Stompbox.
+// It is not safe for concurrent use; Server serializes access.
+type State struct {
+	configRaw string
+	catalog   *stompbox.DumpConfigParsed
+
+	preset  string
+	layout  []section           // program order of chains and slots
+	chains  map[string][]string // chain -> instances
+	slots   map[string]string   // slot -> instance
+	plugins map[string]*plugin  // loaded instances, including ones not wired anywhere
+	presets map[string]string   // name -> program script
+}
+
+type section struct {
+	slot bool
+	name string
+}
+
+type plugin struct {
+	typ    string
+	order  []string
+	params map[string]string
+}
+
+// LoadState builds a state from a Dump Config response and a Dump Program
+// response. The program is also stored as a preset under its SetPreset name.
+func LoadState(configRaw, programRaw string) (*State, error) {
+	cat, err := stompbox.ParseDumpConfig(configRaw)
+	if err != nil {
+		return nil, err
+	}
+	st := &State{
+		configRaw: configRaw,
+		catalog:   cat,
+		plugins:   make(map[string]*plugin),
+		presets:   make(map[string]string),
+	}
+	st.resetProgram()
+
+	if err := st.applyScript(programRaw); err != nil {
+		return nil, err
+	}
+	if st.preset != "" {
+		st.presets[st.preset] = st.programScript()
+	}
+	return st, nil
+}
+
+// LoadStateDir reads SampleConfigFile and SampleProgramFile from dir.
+func LoadStateDir(dir string) (*State, error) {
+	cfg, err := os.ReadFile(filepath.Join(dir, SampleConfigFile))
+	if err != nil {
+		return nil, err
+	}
+	prog, err := os.ReadFile(filepath.Join(dir, SampleProgramFile))
+	if err != nil {
+		return nil, err
+	}
+	return LoadState(string(cfg), string(prog))
+}
+
+// Presets returns the stored preset names, sorted.
+func (st *State) Presets() []string {
+	out := make([]string, 0, len(st.presets))
+	for n := range st.presets {
+		out = append(out, n)
+	}
+	sort.Strings(out)
+	return out
+}
+
+// AddPreset stores a program script under name.
+func (st *State) AddPreset(name, script string) {
+	st.presets[name] = script
+}
+
+func (st *State) resetProgram() {
+	st.preset = ""
+	st.layout = nil
+	st.chains = make(map[string][]string)
+	st.slots = make(map[string]string)
+}
+
+// applyScript replays program lines (SetPreset/SetChain/SetPluginSlot/SetParam).
+// Terminators and unknown lines are ignored, like a preset replay in Stompbox.
+func (st *State) applyScript(script string) error {
+	for _, line := range strings.Split(script, "\n") {
+		toks := stompbox.Tokenize(strings.TrimSpace(line))
+		if len(toks) == 0 {
+			continue
+		}
+		var err error
+		switch toks[0] {
+		case "SetPreset":
+			st.preset = strings.Join(toks[1:], " ")
+		case "SetChain":
+			err = st.setChain(toks[1:])
+		case "SetPluginSlot":
+			err = st.setSlot(toks[1:])
+		case "SetParam":
+			err = st.setParam(toks[1:], false)
+		}
+		if err != nil {
+			return fmt.Errorf("%s: %w", strings.TrimSpace(line), err)
+		}
+	}
+	return nil
+}
+
+func (st *State) setChain(args []string) error {
+	if len(args) < 1 {
+		return fmt.Errorf("missing chain name")
+	}
+	name := args[0]
+	instances := make([]string, 0, len(args)-1)
+	for _, id := range args[1:] {
+		inst, err := st.instance(id)
+		if err != nil {
+			return err
+		}
+		instances = append(instances, inst)
+	}
+	if _, ok := st.chains[name]; !ok {
+		st.layout = append(st.layout, section{name: name})
+	}
+	st.chains[name] = instances
+	return nil
+}
+
+func (st *State) setSlot(args []string) error {
+	if len(args) < 2 {
+		return fmt.Errorf("usage: SetPluginSlot <slot> <plugin>")
+	}
+	inst, err := st.instance(args[1])
+	if err != nil {
+		return err
+	}
+	if _, ok := st.slots[args[0]]; !ok {
+		st.layout = append(st.layout, section{slot: true, name: args[0]})
+	}
+	st.slots[args[0]] = inst
+	return nil
+}
+
+// setParam stores a value. Dumps carry params DumpConfig doesn't describe
+// (e.g. NAM Level), so script replay keeps those verbatim; strict mode,
+// used for client commands, rejects them.
+func (st *State) setParam(args []string, strict bool) error {
+	// A missing value is taken as empty, like an empty File value ("").
+	if len(args) < 2 {
+		return fmt.Errorf("usage: SetParam <plugin> <param> <value>")
+	}
+	p, ok := st.plugins[args[0]]
+	if !ok {
+		return fmt.Errorf("unknown plugin %s", args[0])
+	}
+	name, value := args[1], strings.Join(args[2:], " ")
+
+	def := st.paramDef(p.typ, name)
+	v := value
+	switch {
+	case def != nil || name == "Enabled":
+		var err error
+		if v, err = formatValue(def, name, value); err != nil {
+			return err
+		}
+	case strict:
+		return fmt.Errorf("unknown parameter %s for %s", name, args[0])
+	}
+	if _, ok := p.params[name]; !ok {
+		p.order = append(p.order, name)
+	}
+	p.params[name] = v
+	return nil
+}
+
+func (st *State) releasePlugin(inst string) error {
+	if _, ok := st.plugins[inst]; !ok {
+		return fmt.Errorf("unknown plugin %s", inst)
+	}
+	for chain, list := range st.chains {
+		for _, p := range list {
+			if p == inst {
+				return fmt.Errorf("plugin %s is in use by chain %s", inst, chain)
+			}
+		}
+	}
+	for slot, p := range st.slots {
+		if p == inst {
+			return fmt.Errorf("plugin %s is in use by slot %s", inst, slot)
+		}
+	}
+	delete(st.plugins, inst)
+	return nil
+}
+
+func (st *State) loadPreset(name string) error {
+	script, ok := st.presets[name]
+	if !ok {
+		return fmt.Errorf("unknown preset %s", name)
+	}
+	st.resetProgram()
+	if err := st.applyScript(script); err != nil {
+		return err
+	}
+	st.preset = name
+	return nil
+}
+
+func (st *State) savePreset(name string) {
+	st.preset = name
+	st.presets[name] = st.programScript()
+}
+
+func (st *State) deletePreset(name string) error {
+	if _, ok := st.presets[name]; !ok {
+		return fmt.Errorf("unknown preset %s", name)
+	}
+	delete(st.presets, name)
+	return nil
+}
+
+// instance resolves id to a loaded instance, creating one when id names a
+// plugin type. An exact instance name is reused; a bare type that is not
+// loaded under that name gets the next free "_N" suffix.
+func (st *State) instance(id string) (string, error) {
+	if _, ok := st.plugins[id]; ok {
+		return id, nil
+	}
+
+	typ, n := stompbox.SplitInstance(id)
+	if _, ok := st.catalog.Plugins[typ]; !ok {
+		return "", fmt.Errorf("unknown plugin type %s", typ)
+	}
+
+	name := id
+	if n == 0 {
+		name = st.nextInstanceName(typ)
+	}
+	st.plugins[name] = st.newPlugin(typ)
+	return name, nil
+}
+
+func (st *State) nextInstanceName(typ string) string {
+	names := make([]string, 0, len(st.plugins))
+	for name := range st.plugins {
+		names = append(names, name)
+	}
+	return stompbox.NextInstanceName(typ, names)
+}
+
+func (st *State) newPlugin(typ string) *plugin {
+	p := &plugin{
+		typ:    typ,
+		order:  []string{"Enabled"},
+		params: map[string]string{"Enabled": "1"},
+	}
+	def := st.catalog.Plugins[typ]
+	for _, name := range def.ParamOrder {
+		pd := def.Params[name]
+		v := ""
+		if pd.DefaultValue != nil {
+			v, _ = formatValue(pd, name, strconv.FormatFloat(*pd.DefaultValue, 'f', -1, 64))
+		}
+		if pd.Type == "File" {
+			v = `""`
+		}
+		p.order = append(p.order, name)
+		p.params[name] = v
+	}
+	return p
+}
+
+func (st *State) paramDef(typ, name string) *stompbox.ParamDef {
+	def, ok := st.catalog.Plugins[typ]
+	if !ok || def.Params == nil {
+		return nil
+	}
+	return def.Params[name]
+}
+
+// programScript renders the program the way Dump Program does (without terminators).
+func (st *State) programScript() string {
+	var b strings.Builder
+	b.WriteString("SetPreset " + st.preset + "\r\n")
+	for _, sec := range st.layout {
+		var insts []string
+		if sec.slot {
+			b.WriteString("SetPluginSlot " + sec.name + " " + st.slots[sec.name] + " \r\n")
+			insts = []string{st.slots[sec.name]}
+		} else {
+			b.WriteString("SetChain " + sec.name + " ")
+			for _, inst := range st.chains[sec.name] {
+				b.WriteString(inst + " ")
+			}
+			b.WriteString("\r\n")
+			insts = st.chains[sec.name]
+		}
+		for _, inst := range insts {
+			p := st.plugins[inst]
+			if p == nil {
+				continue
+			}
+			for _, name := range p.order {
+				b.WriteString("SetParam " + inst + " " + name + " " + p.params[name] + "\r\n")
+			}
+		}
+	}
+	return b.String()
+}
+
+// formatValue normalizes a value the way Stompbox prints it back:
+// File params quoted, Bool/Enabled as 0/1, everything else with six decimals.
+func formatValue(def *stompbox.ParamDef, name, value string) (string, error) {
+	if def != nil && def.Type == "File" {
+		return strconv.Quote(strings.Trim(value, `"`)), nil
+	}
+	f, err := strconv.ParseFloat(strings.TrimSpace(value), 64)
+	if err != nil {
+		return "", fmt.Errorf("invalid value %q for %s", value, name)
+	}
+	if name == "Enabled" || (def != nil && def.Type == "Bool") {
+		if f != 0 {
+			return "1", nil
+		}
+		return "0", nil
+	}
+	return strconv.FormatFloat(f, 'f', 6, 64), nil
+}
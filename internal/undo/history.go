@@ -0,0 +1,197 @@
+// Package undo keeps per-session undo/redo stacks of live edits.
+//
+// Each step holds two baseline.Plans: the inverse commands, built from the
+// program as it was before the edit, and the forward commands to redo it.
+// Steps with the same coalescing key recorded within Coalesce of each other
+// (the moves of one knob drag) merge into one: the first step's undo is kept
+// and the last step's redo wins.
+package undo
+
+import (
+	"errors"
+	"sync"
+	"time"
+
+	"github.com/alscos/Namnesis/internal/baseline"
+)
+
+// ErrEmpty is returned by Undo/Redo when there is nothing to replay.
+var ErrEmpty = errors.New("nothing to replay")
+
+// Step is one undoable edit.
+type Step struct {
+	ID    int       `json:"id"`
+	Label string    `json:"label"`
+	At    time.Time `json:"at"`
+	// Key merges consecutive steps on the same target ("" = never merge).
+	Key  string         `json:"-"`
+	Undo *baseline.Plan `json:"-"`
+	Redo *baseline.Plan `json:"-"`
+}
+
+// History holds the stacks of every session. The zero value is not usable;
+// call New.
+type History struct {
+	// Limit caps undo steps per session; the oldest are dropped.
+	Limit int
+	// Coalesce is how close in time same-key steps must be to merge.
+	Coalesce time.Duration
+	// MaxSessions caps tracked sessions; the least recently used is dropped.
+	MaxSessions int
+
+	mu       sync.Mutex
+	sessions map[string]*session
+	nextID   int
+}
+
+type session struct {
+	mu       sync.Mutex
+	undo     []*Step
+	redo     []*Step
+	noMerge  bool // the last action was an undo/redo
+	lastUsed time.Time
+}
+
+func New(limit int, coalesce time.Duration) *History {
+	return &History{
+		Limit:       limit,
+		Coalesce:    coalesce,
+		MaxSessions: 32,
+		sessions:    make(map[string]*session),
+	}
+}
+
+func (h *History) session(id string) *session {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	s, ok := h.sessions[id]
+	if !ok {
+		if len(h.sessions) >= h.MaxSessions {
+			h.evictLocked()
+		}
+		s = &session{}
+		h.sessions[id] = s
+	}
+	s.lastUsed = time.Now()
+	return s
+}
+
+func (h *History) evictLocked() {
+	var oldest string
+	var at time.Time
+	for id, s := range h.sessions {
+		if oldest == "" || s.lastUsed.Before(at) {
+			oldest, at = id, s.lastUsed
+		}
+	}
+	delete(h.sessions, oldest)
+}
+
+// Merges reports whether a step with key recorded now would merge into the
+// newest one, in which case its undo plan is not needed.
+func (h *History) Merges(sessionID, key string) bool {
+	s := h.session(sessionID)
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return h.mergeTarget(s, key, time.Now()) != nil
+}
+
+func (h *History) mergeTarget(s *session, key string, now time.Time) *Step {
+	if key == "" || s.noMerge || len(s.undo) == 0 {
+		return nil
+	}
+	top := s.undo[len(s.undo)-1]
+	if top.Key != key || now.Sub(top.At) > h.Coalesce {
+		return nil
+	}
+	return top
+}
+
+// sameKeyTop is mergeTarget without the time limit, for a step whose undo
+// plan was skipped because Merges said it would merge.
+func sameKeyTop(s *session, key string) *Step {
+	if key == "" || s.noMerge || len(s.undo) == 0 || s.undo[len(s.undo)-1].Key != key {
+		return nil
+	}
+	return s.undo[len(s.undo)-1]
+}
+
+// Record pushes step (or merges it into the newest one) and clears the redo
+// stack. step.Undo may be nil when Merges returned true; such a step is
+// dropped if it can no longer merge.
+func (h *History) Record(sessionID string, step *Step) {
+	s := h.session(sessionID)
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	now := time.Now()
+	s.redo = nil
+	top := h.mergeTarget(s, step.Key, now)
+	if top == nil && step.Undo == nil {
+		top = sameKeyTop(s, step.Key)
+	}
+	if top != nil {
+		top.Redo = step.Redo
+		top.At = now
+		return
+	}
+	if step.Undo == nil {
+		return
+	}
+	s.noMerge = false
+
+	h.mu.Lock()
+	h.nextID++
+	step.ID = h.nextID
+	h.mu.Unlock()
+	step.At = now
+	s.undo = append(s.undo, step)
+	if h.Limit > 0 && len(s.undo) > h.Limit {
+		s.undo = s.undo[len(s.undo)-h.Limit:]
+	}
+}
+
+// Undo replays the newest step's inverse with apply and moves it to the
+// redo stack. If apply fails the step stays where it was.
+func (h *History) Undo(sessionID string, apply func(*baseline.Plan) error) (*Step, error) {
+	s := h.session(sessionID)
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return move(s, &s.undo, &s.redo, func(st *Step) error { return apply(st.Undo) })
+}
+
+// Redo replays the newest undone step and moves it back to the undo stack.
+func (h *History) Redo(sessionID string, apply func(*baseline.Plan) error) (*Step, error) {
+	s := h.session(sessionID)
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return move(s, &s.redo, &s.undo, func(st *Step) error { return apply(st.Redo) })
+}
+
+func move(s *session, from, to *[]*Step, apply func(*Step) error) (*Step, error) {
+	if len(*from) == 0 {
+		return nil, ErrEmpty
+	}
+	st := (*from)[len(*from)-1]
+	if err := apply(st); err != nil {
+		return nil, err
+	}
+	*from = (*from)[:len(*from)-1]
+	*to = append(*to, st)
+	s.noMerge = true
+	return st, nil
+}
+
+// Stacks returns copies of a session's undo and redo stacks, newest last.
+func (h *History) Stacks(sessionID string) (undo, redo []Step) {
+	s := h.session(sessionID)
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for _, st := range s.undo {
+		undo = append(undo, *st)
+	}
+	for _, st := range s.redo {
+		redo = append(redo, *st)
+	}
+	return undo, redo
+}
@@ -0,0 +1,80 @@
+package undo
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/alscos/Namnesis/internal/baseline"
+)
+
+func setParam(value string) *baseline.Plan {
+	return &baseline.Plan{Params: []baseline.ParamSet{{Plugin: "Delay", Param: "Mix", Value: value}}}
+}
+
+func valueOf(p *baseline.Plan) string { return p.Params[0].Value }
+
+func TestCoalesceUndoRedo(t *testing.T) {
+	h := New(10, time.Minute)
+	const sess = "a"
+
+	// A drag: 0.5 -> 0.6 -> 0.7 -> 0.8 is one step.
+	h.Record(sess, &Step{Label: "Delay.Mix", Key: "Delay.Mix", Undo: setParam("0.5"), Redo: setParam("0.6")})
+	for _, v := range []string{"0.7", "0.8"} {
+		if !h.Merges(sess, "Delay.Mix") {
+			t.Fatal("same key within the window should merge")
+		}
+		h.Record(sess, &Step{Key: "Delay.Mix", Redo: setParam(v)})
+	}
+	h.Record(sess, &Step{Label: "Delay.Enabled", Undo: setParam("1"), Redo: setParam("0")})
+
+	undo, _ := h.Stacks(sess)
+	if len(undo) != 2 {
+		t.Fatalf("undo stack = %+v", undo)
+	}
+
+	var sent []string
+	apply := func(p *baseline.Plan) error { sent = append(sent, valueOf(p)); return nil }
+	for i := 0; i < 2; i++ {
+		if _, err := h.Undo(sess, apply); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if _, err := h.Undo(sess, apply); !errors.Is(err, ErrEmpty) {
+		t.Fatalf("third undo: %v", err)
+	}
+	if st, err := h.Redo(sess, apply); err != nil || st.Label != "Delay.Mix" {
+		t.Fatalf("redo: %+v, %v", st, err)
+	}
+	if want := []string{"1", "0.5", "0.8"}; len(sent) != 3 || sent[0] != want[0] || sent[1] != want[1] || sent[2] != want[2] {
+		t.Fatalf("sent %v, want %v", sent, want)
+	}
+
+	// After a redo the next move starts a new step, and clears the redo stack.
+	if h.Merges(sess, "Delay.Mix") {
+		t.Fatal("a move after redo must not merge into the redone step")
+	}
+	h.Record(sess, &Step{Key: "Delay.Mix", Undo: setParam("0.8"), Redo: setParam("0.9")})
+	if undo, redo := h.Stacks(sess); len(undo) != 2 || len(redo) != 0 {
+		t.Fatalf("stacks: undo=%d redo=%d", len(undo), len(redo))
+	}
+}
+
+func TestFailedUndoKeepsStep(t *testing.T) {
+	h := New(2, 0)
+	for _, v := range []string{"1", "2", "3"} {
+		h.Record("s", &Step{Undo: setParam(v), Redo: setParam(v)})
+	}
+	if undo, _ := h.Stacks("s"); len(undo) != 2 || valueOf(undo[0].Undo) != "2" {
+		t.Fatalf("limit: %+v", undo)
+	}
+	if _, err := h.Undo("s", func(*baseline.Plan) error { return errors.New("down") }); err == nil {
+		t.Fatal("apply error not returned")
+	}
+	if undo, redo := h.Stacks("s"); len(undo) != 2 || len(redo) != 0 {
+		t.Fatalf("failed undo moved the step: undo=%d redo=%d", len(undo), len(redo))
+	}
+	if undo, _ := h.Stacks("other"); len(undo) != 0 {
+		t.Fatal("sessions are separate")
+	}
+}
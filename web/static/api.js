@@ -4,11 +4,12 @@
     let setParamBusy = false;
     let queuedSet = null; // { plugin, param, value }
 
+    // Knob values are clamped into the param range by the gateway.
     A.setNumericParam = async function setNumericParam(plugin, param, value) {
         const res = await fetch('/api/param/set', {
             method: 'POST',
             headers: { 'Content-Type': 'application/json' },
-            body: JSON.stringify({ plugin, param, value })
+            body: JSON.stringify({ plugin, param, value, clamp: true })
         });
         if (!res.ok) {
             const txt = await res.text();
@@ -33,6 +34,48 @@
         }
     };
 
+    // Set a param from a 0..1 control position (RangePower curve applied
+    // by the gateway). Resolves with { value, position }.
+    A.setParamNormalized = async function setParamNormalized(plugin, param, position) {
+        const res = await fetch('/api/param/set-normalized', {
+            method: 'POST',
+            headers: { 'Content-Type': 'application/json' },
+            body: JSON.stringify({ plugin, param, position, clamp: true })
+        });
+        if (!res.ok) {
+            const txt = await res.text();
+            throw new Error(txt || `HTTP ${res.status}`);
+        }
+        return res.json();
+    };
+
+    // Current values and 0..1 positions: one param, or all of the plugin.
+    A.getParamNormalized = async function getParamNormalized(plugin, param) {
+        const q = new URLSearchParams({ plugin });
+        if (param) q.set('param', param);
+        const res = await fetch(`/api/param/normalized?${q}`);
+        if (!res.ok) {
+            const txt = await res.text();
+            throw new Error(txt || `HTTP ${res.status}`);
+        }
+        return res.json();
+    };
+
+    // Move a param relative to its value: { steps } (1% of the travel
+    // each) or { percent }. Resolves with { previous, value, position }.
+    A.nudgeParam = async function nudgeParam(plugin, param, { steps, percent } = {}) {
+        const res = await fetch('/api/param/nudge', {
+            method: 'POST',
+            headers: { 'Content-Type': 'application/json' },
+            body: JSON.stringify({ plugin, param, steps, percent })
+        });
+        if (!res.ok) {
+            const txt = await res.text();
+            throw new Error(txt || `HTTP ${res.status}`);
+        }
+        return res.json();
+    };
+
     A.setFileParam = async function setFileParam(plugin, param, value) {
         const res = await fetch('/api/param/file', {
             method: 'POST',
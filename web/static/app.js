@@ -23,6 +23,8 @@
     refreshMode: localStorage.getItem("namnesis.refreshMode") || "live", // "live" | "research"
     systemPollTimer: null,
     presetWatchTimer: null,
+    events: null, // EventSource on /api/events (live mode)
+    eventRefreshTimer: null,
     lastPresetSeen: null, // used later for midi-triggered preset sync
     isRefreshingUI: false,
     isCheckingPreset: false,
@@ -58,7 +60,10 @@
     });
     if (!res.ok) {
       const msg = await res.text().catch(() => '');
-      throw new Error(msg || `HTTP ${res.status}`);
+      const err = new Error(msg || `HTTP ${res.status}`);
+      err.status = res.status;
+      err.code = res.headers.get('X-Error-Code') || '';
+      throw err;
     }
     const txt = await res.text().catch(() => '');
     if (!txt) return {};
@@ -335,17 +340,27 @@
         return;
       }
 
-      if (!window.confirm(`Delete preset "${preset}"? This cannot be undone.`)) {
+      if (!window.confirm(`Delete preset "${preset}"? It can be restored from the trash.`)) {
         return;
       }
 
       try {
         disableBtn(deletePresetBtn);
 
-        await postJSON("/api/preset/delete", { name: preset });
+        let out;
+        try {
+          out = await postJSON("/api/preset/delete", { name: preset });
+        } catch (err) {
+          // The gateway has never seen this preset's content: no trash copy.
+          if (err.code !== "no_history") throw err;
+          if (!window.confirm(`"${preset}" has never been loaded here, so no copy can go to the trash and it cannot be restored once deleted. Delete anyway?`)) {
+            return;
+          }
+          out = await postJSON("/api/preset/delete", { name: preset, force: true });
+        }
         await refreshUI();
 
-        elStatus.textContent = `Deleted ${preset}`;
+        elStatus.textContent = out.trashed ? `Deleted ${preset} (moved to trash)` : `Deleted ${preset}`;
       } catch (err) {
         console.error(err);
         elStatus.textContent = `Delete failed: ${err.message || err}`;
@@ -357,6 +372,24 @@
   }
 
 
+  // Ctrl/Cmd+Z undoes the last edit of this browser session, Shift redoes it.
+  document.addEventListener("keydown", async (ev) => {
+    if (!(ev.ctrlKey || ev.metaKey) || ev.key.toLowerCase() !== "z") return;
+    const tag = (ev.target?.tagName || "").toLowerCase();
+    if (tag === "input" || tag === "textarea" || tag === "select") return;
+    ev.preventDefault();
+
+    const what = ev.shiftKey ? "redo" : "undo";
+    try {
+      const out = await postJSON(`/api/${what}`, {});
+      await refreshUI();
+      elStatus.textContent = `${what === "undo" ? "Undid" : "Redid"} ${out.step?.label || "edit"}`;
+    } catch (err) {
+      elStatus.textContent = err.status === 409 ? `Nothing to ${what}` : `${what} failed: ${err.message || err}`;
+    }
+  });
+
+
   function fmtDb(x) {
     const n = Number(x);
     if (!Number.isFinite(n)) return String(x);
@@ -740,8 +773,14 @@
   async function refreshSystemStrip() {
     try {
       const r = await fetch("/api/system");
-      const s = await r.json();
+      renderSystemStrip(await r.json());
+    } catch (e) {
+      document.getElementById("sysReady").textContent = "FAIL";
+    }
+  }
 
+  function renderSystemStrip(s) {
+    try {
       // READY / WARN / FAIL
       let state = "READY";
       let cls = "text-emerald-400";
@@ -817,6 +856,14 @@
     }
   }
   function stopLivePolling() {
+    if (state.events) {
+      state.events.close();
+      state.events = null;
+    }
+    if (state.eventRefreshTimer) {
+      clearTimeout(state.eventRefreshTimer);
+      state.eventRefreshTimer = null;
+    }
     if (state.systemPollTimer) {
       clearInterval(state.systemPollTimer);
       state.systemPollTimer = null;
@@ -853,8 +900,57 @@
     applyRefreshModeUI();
   }
 
+  // Coalesces bursts of chain/slot/param events into one refresh.
+  function scheduleEventRefresh() {
+    if (state.eventRefreshTimer) return;
+    state.eventRefreshTimer = setTimeout(() => {
+      state.eventRefreshTimer = null;
+      refreshUI();
+    }, 150);
+  }
+
+  function startEventStream() {
+    const es = new EventSource("/api/events");
+    const data = (ev) => { try { return JSON.parse(ev.data).data; } catch (_) { return null; } };
+
+    es.addEventListener("preset", (ev) => {
+      const d = data(ev);
+      if (!d) return;
+      state.lastPresetSeen = d.preset;
+      const elPreset = document.getElementById("presetSelect");
+      if (elPreset) {
+        state.isProgrammaticPresetUpdate = true;
+        elPreset.value = d.preset;
+        state.isProgrammaticPresetUpdate = false;
+      }
+      refreshUI();
+    });
+    es.addEventListener("param", (ev) => {
+      const d = data(ev);
+      // Our own edit echoing back: the input already shows it.
+      if (d && state.editing.has(`${d.plugin}::${d.param}`)) return;
+      scheduleEventRefresh();
+    });
+    es.addEventListener("chain", scheduleEventRefresh);
+    es.addEventListener("slot", scheduleEventRefresh);
+    es.addEventListener("engine", (ev) => {
+      const d = data(ev);
+      if (d && d.up === false) elStatus.textContent = 'ENGINE OFFLINE';
+      else scheduleEventRefresh();
+    });
+    es.addEventListener("system", (ev) => {
+      const d = data(ev);
+      if (d) renderSystemStrip(d);
+    });
+    state.events = es;
+  }
+
   function startLivePolling() {
     stopLivePolling();
+    if (window.EventSource) {
+      startEventStream();
+      return;
+    }
     state.systemPollTimer = setInterval(() => {
       refreshSystemStrip();
     }, 750);
@@ -871,6 +967,7 @@
 
       elNow.textContent = data?.meta?.now || '(no time)';
       elStatus.textContent = res.ok ? 'ok' : ('http ' + res.status);
+      if (data?.meta && data.meta.engineUp === false) elStatus.textContent = 'ENGINE OFFLINE';
 
       const presetList = data?.presets?.error ? [] : P.parsePresets(data?.presets?.raw || '');
       const pluginMetaMap = data?.dumpConfig?.error ? {} : P.parseDumpConfig(data?.dumpConfig?.raw || '');